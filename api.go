@@ -0,0 +1,285 @@
+package broadcasts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError is returned by APIClient when the broadcasts service responds with an error
+// that doesn't correspond to one of our sentinel errors
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("got response %d from broadcasts API: %s", e.StatusCode, e.Message)
+}
+
+// APIClient makes requests to the broadcasts service's HTTP API. Where the service
+// responds with an error that indicates why broadcast state couldn't be modified, the
+// corresponding Err* sentinel error is returned, so callers can use errors.Is.
+type APIClient struct {
+	broadcastsUrl string
+	httpClient    *http.Client
+	accessToken   string
+	maxAttempts   int
+	retryDelay    time.Duration
+	timeout       time.Duration
+}
+
+// APIClientOption configures an APIClient
+type APIClientOption func(c *APIClient)
+
+// WithHTTPClient configures an APIClient to make requests using the given http.Client,
+// rather than http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) APIClientOption {
+	return func(c *APIClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAccessToken configures an APIClient to authenticate its requests with the given
+// access token, which is required in order to call admin methods
+func WithAccessToken(accessToken string) APIClientOption {
+	return func(c *APIClient) {
+		c.accessToken = accessToken
+	}
+}
+
+// WithRetries configures an APIClient to make up to maxAttempts attempts at any request
+// that can be safely retried, if the service is unreachable or responds with a 5xx
+// error: the delay between attempts increases by retryDelay after each attempt
+func WithRetries(maxAttempts int, retryDelay time.Duration) APIClientOption {
+	return func(c *APIClient) {
+		c.maxAttempts = maxAttempts
+		c.retryDelay = retryDelay
+	}
+}
+
+// WithTimeout configures an APIClient to abandon any single attempt at a request that
+// takes longer than the given duration
+func WithTimeout(timeout time.Duration) APIClientOption {
+	return func(c *APIClient) {
+		c.timeout = timeout
+	}
+}
+
+// NewAPIClient initializes an APIClient that will make requests to the broadcasts
+// service at the given URL. By default, requests are made once, using
+// http.DefaultClient, with a timeout of 10 seconds.
+func NewAPIClient(broadcastsUrl string, opts ...APIClientOption) *APIClient {
+	c := &APIClient{
+		broadcastsUrl: strings.TrimSuffix(broadcastsUrl, "/"),
+		httpClient:    http.DefaultClient,
+		maxAttempts:   1,
+		timeout:       10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetState returns the current broadcast state
+func (c *APIClient) GetState(ctx context.Context) (*State, error) {
+	var state State
+	if err := c.do(ctx, http.MethodGet, "/state", true, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetStateAt returns broadcast state as it stood at the given point in time, as
+// reconstructed from the server's log of broadcast-events, or ErrStateUnknown if t
+// precedes that log
+func (c *APIClient) GetStateAt(ctx context.Context, t time.Time) (*State, error) {
+	q := url.Values{}
+	q.Set("t", t.UTC().Format(time.RFC3339Nano))
+
+	var state State
+	if err := c.do(ctx, http.MethodGet, "/state/at?"+q.Encode(), true, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// GetHistory returns the details of up to n past broadcasts, most recent first. If n is
+// 0, the server's default limit applies. If before is nonzero, only broadcasts with
+// IDs less than before are returned, allowing results to be paginated.
+func (c *APIClient) GetHistory(ctx context.Context, n int, before int) (*History, error) {
+	q := url.Values{}
+	if n > 0 {
+		q.Set("n", strconv.Itoa(n))
+	}
+	if before > 0 {
+		q.Set("before", strconv.Itoa(before))
+	}
+	path := "/history"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var history History
+	if err := c.do(ctx, http.MethodGet, path, true, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// GetBroadcast returns the details of the broadcast with the given ID, or
+// ErrNoSuchBroadcast if it does not exist
+func (c *APIClient) GetBroadcast(ctx context.Context, broadcastId int) (*Broadcast, error) {
+	var broadcast Broadcast
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/history/%d", broadcastId), true, &broadcast); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// GetScreeningHistory returns the IDs of all broadcasts in which each tape has been
+// screened
+func (c *APIClient) GetScreeningHistory(ctx context.Context) (*ScreeningHistory, error) {
+	var screeningHistory ScreeningHistory
+	if err := c.do(ctx, http.MethodGet, "/screening-history", true, &screeningHistory); err != nil {
+		return nil, err
+	}
+	return &screeningHistory, nil
+}
+
+// StartBroadcast starts a new broadcast, returning its details; requires broadcaster
+// access
+func (c *APIClient) StartBroadcast(ctx context.Context) (*Broadcast, error) {
+	var broadcast Broadcast
+	if err := c.do(ctx, http.MethodPost, "/admin/broadcast", false, &broadcast); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// EndBroadcast ends the current broadcast, returning its details; requires broadcaster
+// access
+func (c *APIClient) EndBroadcast(ctx context.Context) (*Broadcast, error) {
+	var broadcast Broadcast
+	if err := c.do(ctx, http.MethodDelete, "/admin/broadcast", false, &broadcast); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// ResumeBroadcast resumes the most recent broadcast after it's ended, returning its
+// details; requires broadcaster access
+func (c *APIClient) ResumeBroadcast(ctx context.Context, broadcastId int) (*Broadcast, error) {
+	var broadcast Broadcast
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/admin/broadcast/%d/resume", broadcastId), false, &broadcast); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// StartScreening begins screening the tape with the given ID in the current broadcast;
+// requires broadcaster access
+func (c *APIClient) StartScreening(ctx context.Context, tapeId int) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/admin/tape/%d", tapeId), false, nil)
+}
+
+// ClearScreening ends the current screening, if any; requires broadcaster access
+func (c *APIClient) ClearScreening(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/admin/tape", true, nil)
+}
+
+// do makes a request, retrying it if it's idempotent and fails in a way that may be
+// transient, and decodes the JSON response body into result if non-nil
+func (c *APIClient) do(ctx context.Context, method string, path string, idempotent bool, result interface{}) error {
+	maxAttempts := 1
+	if idempotent && c.maxAttempts > 1 {
+		maxAttempts = c.maxAttempts
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryDelay * time.Duration(attempt-1)):
+			}
+		}
+		var retryable bool
+		retryable, err = c.attempt(ctx, method, path, result)
+		if err == nil || !retryable {
+			return err
+		}
+	}
+	return err
+}
+
+// attempt makes a single request, returning an error along with a flag indicating
+// whether the request may succeed if retried
+func (c *APIClient) attempt(ctx context.Context, method string, path string, result interface{}) (bool, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.broadcastsUrl+path, nil)
+	if err != nil {
+		return false, err
+	}
+	if c.accessToken != "" {
+		req.Header.Set("authorization", "Bearer "+c.accessToken)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		// Network errors and timeouts may be transient
+		return true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return res.StatusCode >= 500, parseErrorResponse(res)
+	}
+	if result != nil && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+			return false, fmt.Errorf("failed to decode response body from %s %s: %w", method, path, err)
+		}
+	}
+	return false, nil
+}
+
+// sentinelErrors lists all errors that the broadcasts service may report, so that they
+// can be identified by their message
+var sentinelErrors = []error{
+	ErrBroadcastInProgress,
+	ErrNoBroadcastInProgress,
+	ErrScreeningInProgress,
+	ErrNoScreeningInProgress,
+	ErrNoSuchBroadcast,
+	ErrBroadcastNotResumable,
+	ErrStateUnknown,
+}
+
+// parseErrorResponse returns the error described by an error response from the
+// broadcasts service, which reports errors as plain-text messages
+func parseErrorResponse(res *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	message := strings.TrimSpace(string(data))
+	for _, sentinel := range sentinelErrors {
+		if message == sentinel.Error() {
+			return sentinel
+		}
+	}
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Message:    message,
+	}
+}
@@ -0,0 +1,210 @@
+package broadcasts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_APIClient(t *testing.T) {
+	t.Run("GetHistory supplies pagination parameters", func(t *testing.T) {
+		var gotQuery string
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/history", req.URL.Path)
+			gotQuery = req.URL.RawQuery
+			res.Write([]byte(`{"broadcasts":[{"id":41,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[]}]}`))
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL)
+		history, err := c.GetHistory(context.Background(), 5, 42)
+		assert.NoError(t, err)
+		assert.Equal(t, "before=42&n=5", gotQuery)
+		assert.Len(t, history.Broadcasts, 1)
+		assert.Equal(t, 41, history.Broadcasts[0].Id)
+	})
+
+	t.Run("GetStateAt supplies an RFC3339 timestamp", func(t *testing.T) {
+		var gotT string
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/state/at", req.URL.Path)
+			gotT = req.URL.Query().Get("t")
+			res.Write([]byte(`{"broadcast_id":41,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":8,"broadcast_started_at":"1997-09-01T12:00:00Z","screening_started_at":null}`))
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL)
+		at := time.Date(1997, 9, 1, 13, 30, 0, 0, time.FixedZone("EDT", -4*60*60))
+		state, err := c.GetStateAt(context.Background(), at)
+		assert.NoError(t, err)
+		assert.Equal(t, "1997-09-01T17:30:00Z", gotT)
+		assert.Equal(t, 41, state.BroadcastId)
+		assert.Equal(t, int64(8), state.Version)
+	})
+
+	t.Run("GetStateAt reports a time before the event log as ErrStateUnknown", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			http.Error(res, "broadcast state is unknown before the event log began", http.StatusNotFound)
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL)
+		state, err := c.GetStateAt(context.Background(), time.Date(1997, 9, 1, 13, 30, 0, 0, time.UTC))
+		assert.ErrorIs(t, err, ErrStateUnknown)
+		assert.Nil(t, state)
+	})
+
+	t.Run("GetBroadcast reports a nonexistent broadcast as ErrNoSuchBroadcast", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/history/42", req.URL.Path)
+			http.Error(res, "no such broadcast", http.StatusNotFound)
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL)
+		broadcast, err := c.GetBroadcast(context.Background(), 42)
+		assert.ErrorIs(t, err, ErrNoSuchBroadcast)
+		assert.Nil(t, broadcast)
+	})
+
+	t.Run("admin requests are authenticated and errors map to sentinels", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, "/admin/tape/50", req.URL.Path)
+			assert.Equal(t, "Bearer my-token", req.Header.Get("authorization"))
+			http.Error(res, "no broadcast is currently in progress", http.StatusBadRequest)
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL, WithAccessToken("my-token"))
+		err := c.StartScreening(context.Background(), 50)
+		assert.ErrorIs(t, err, ErrNoBroadcastInProgress)
+	})
+
+	t.Run("ClearScreening succeeds on 204", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, http.MethodDelete, req.Method)
+			assert.Equal(t, "/admin/tape", req.URL.Path)
+			res.WriteHeader(http.StatusNoContent)
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL, WithAccessToken("my-token"))
+		err := c.ClearScreening(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("unrecognized errors are reported as APIError", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			http.Error(res, "insufficient access", http.StatusForbidden)
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL)
+		_, err := c.StartBroadcast(context.Background())
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+		assert.Equal(t, "insufficient access", apiErr.Message)
+	})
+
+	t.Run("idempotent requests are retried on 5xx errors", func(t *testing.T) {
+		h := &flakyHandler{numFailures: 2, body: `{"broadcastIdsByTapeId":{"50":[41,42]}}`}
+		s := httptest.NewServer(h)
+		defer s.Close()
+
+		c := NewAPIClient(s.URL, WithRetries(3, time.Millisecond))
+		screeningHistory, err := c.GetScreeningHistory(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []int{41, 42}, screeningHistory.BroadcastIdsByTapeId["50"])
+		assert.Equal(t, 3, h.numRequests())
+	})
+
+	t.Run("idempotent requests give up after max attempts", func(t *testing.T) {
+		h := &flakyHandler{numFailures: 5}
+		s := httptest.NewServer(h)
+		defer s.Close()
+
+		c := NewAPIClient(s.URL, WithRetries(3, time.Millisecond))
+		_, err := c.GetState(context.Background())
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.Equal(t, 3, h.numRequests())
+	})
+
+	t.Run("non-idempotent requests are not retried", func(t *testing.T) {
+		h := &flakyHandler{numFailures: 1}
+		s := httptest.NewServer(h)
+		defer s.Close()
+
+		c := NewAPIClient(s.URL, WithRetries(3, time.Millisecond))
+		_, err := c.EndBroadcast(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, 1, h.numRequests())
+	})
+
+	t.Run("requests time out", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer s.Close()
+
+		c := NewAPIClient(s.URL, WithTimeout(10*time.Millisecond))
+		_, err := c.GetState(context.Background())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("configured http.Client is used", func(t *testing.T) {
+		rt := &recordingTransport{}
+		c := NewAPIClient("http://broadcasts.example", WithHTTPClient(&http.Client{Transport: rt}))
+		_, err := c.GetBroadcast(context.Background(), 42)
+		assert.Error(t, err)
+		assert.Equal(t, "http://broadcasts.example/history/42", rt.url)
+	})
+}
+
+// flakyHandler responds with 503 to its first numFailures requests, then with body
+type flakyHandler struct {
+	numFailures int
+	body        string
+	count       int
+	mu          sync.Mutex
+}
+
+func (h *flakyHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+	if h.count <= h.numFailures {
+		http.Error(res, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	res.Write([]byte(h.body))
+}
+
+func (h *flakyHandler) numRequests() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// recordingTransport records the URL of the request it's given, then fails it
+type recordingTransport struct {
+	url string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.url = req.URL.String()
+	return nil, fmt.Errorf("not connected")
+}
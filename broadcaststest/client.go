@@ -0,0 +1,118 @@
+package broadcaststest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/schemas/core"
+)
+
+// Client is a fake broadcasts.Client whose state is set directly by the test. Unlike
+// the real client, subscribers are called synchronously from SetState, so their
+// effects are observable as soon as SetState returns.
+type Client struct {
+	state        core.State
+	healthy      bool
+	lastSyncedAt time.Time
+	subscribers  map[int]broadcasts.SubscriberFunc
+	nextId       int
+	mu           sync.Mutex
+}
+
+// NewClient initializes a fake Client that's healthy, with the given initial state
+func NewClient(initial core.State) *Client {
+	return &Client{
+		state:        initial,
+		healthy:      true,
+		lastSyncedAt: time.Now(),
+		subscribers:  make(map[int]broadcasts.SubscriberFunc),
+	}
+}
+
+// SetState changes the client's state, calling every subscriber (in the order they
+// subscribed) if the new state differs from the old
+func (c *Client) SetState(next core.State) {
+	c.mu.Lock()
+	prev := c.state
+	c.state = next
+	c.lastSyncedAt = time.Now()
+	var fs []broadcasts.SubscriberFunc
+	if next != prev {
+		for id := 0; id < c.nextId; id++ {
+			if f, ok := c.subscribers[id]; ok {
+				fs = append(fs, f)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	for _, f := range fs {
+		f(prev, next)
+	}
+}
+
+// SetHealthy changes the value reported by Healthy
+func (c *Client) SetHealthy(healthy bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.healthy = healthy
+}
+
+func (c *Client) GetState() core.State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *Client) Subscribe(f broadcasts.SubscriberFunc) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextId
+	c.nextId++
+	c.subscribers[id] = f
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subscribers, id)
+	}
+}
+
+func (c *Client) WaitFor(ctx context.Context, predicate func(core.State) bool) (core.State, error) {
+	ch := make(chan core.State, 1)
+	unsubscribe := c.Subscribe(func(prev, next core.State) {
+		if predicate(next) {
+			select {
+			case ch <- next:
+			default:
+			}
+		}
+	})
+	defer unsubscribe()
+
+	if state := c.GetState(); predicate(state) {
+		return state, nil
+	}
+	select {
+	case state := <-ch:
+		return state, nil
+	case <-ctx.Done():
+		return c.GetState(), ctx.Err()
+	}
+}
+
+func (c *Client) Healthy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.healthy
+}
+
+func (c *Client) LastSyncedAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSyncedAt
+}
+
+var _ broadcasts.Client = (*Client)(nil)
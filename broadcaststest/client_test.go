@@ -0,0 +1,75 @@
+package broadcaststest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Client_SetState(t *testing.T) {
+	c := NewClient(core.State{})
+
+	var calls [][2]core.State
+	unsubscribe := c.Subscribe(func(prev, next core.State) {
+		calls = append(calls, [2]core.State{prev, next})
+	})
+
+	c.SetState(core.State{BroadcastId: 1})
+	c.SetState(core.State{BroadcastId: 1})
+	c.SetState(core.State{BroadcastId: 1, TapeId: 42})
+	assert.Equal(t, core.State{BroadcastId: 1, TapeId: 42}, c.GetState())
+	assert.Equal(t, [][2]core.State{
+		{{}, {BroadcastId: 1}},
+		{{BroadcastId: 1}, {BroadcastId: 1, TapeId: 42}},
+	}, calls)
+
+	unsubscribe()
+	c.SetState(core.State{})
+	assert.Len(t, calls, 2)
+}
+
+func Test_Client_Subscribe_order(t *testing.T) {
+	c := NewClient(core.State{})
+
+	var order []int
+	for i := 0; i < 5; i++ {
+		i := i
+		c.Subscribe(func(prev, next core.State) {
+			order = append(order, i)
+		})
+	}
+	c.SetState(core.State{BroadcastId: 1})
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+}
+
+func Test_Client_WaitFor(t *testing.T) {
+	c := NewClient(core.State{})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.SetState(core.State{BroadcastId: 1})
+		c.SetState(core.State{BroadcastId: 1, TapeId: 42})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	state, err := c.WaitFor(ctx, broadcasts.IsScreening(42))
+	assert.NoError(t, err)
+	assert.Equal(t, core.State{BroadcastId: 1, TapeId: 42}, state)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitFor(ctx, broadcasts.IsOffline)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_Client_SetHealthy(t *testing.T) {
+	c := NewClient(core.State{})
+	assert.True(t, c.Healthy())
+	c.SetHealthy(false)
+	assert.False(t, c.Healthy())
+}
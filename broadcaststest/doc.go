@@ -0,0 +1,4 @@
+// Package broadcaststest provides in-memory fakes for use in tests: a Client that
+// reports whatever broadcast state the test sets, and a Writer that maintains
+// broadcast state according to the same rules as the service's real broadcasts.Writer
+package broadcaststest
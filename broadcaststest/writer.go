@@ -0,0 +1,279 @@
+package broadcaststest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+)
+
+// Writer is an in-memory implementation of broadcasts.Writer, which records broadcasts
+// and screenings according to the same rules as the real writer and fails with the same
+// errors (e.g. broadcasts.ErrNoBroadcastInProgress). Broadcasts are assigned sequential
+// IDs starting from 1, and all timestamps are taken from the Writer's clock, which
+// defaults to time.Now.
+type Writer struct {
+	resumeGrace time.Duration
+	now         func() time.Time
+	err         error
+	broadcasts  []broadcasts.Broadcast
+	clients     []*Client
+	mu          sync.Mutex
+}
+
+// NewWriter initializes an in-memory Writer with no broadcast history. As with
+// state.NewWriter, a nonzero resumeGrace will cause StartBroadcast to resume the
+// previous broadcast if it ended no more than that long ago.
+func NewWriter(resumeGrace time.Duration) *Writer {
+	return &Writer{
+		resumeGrace: resumeGrace,
+		now:         time.Now,
+	}
+}
+
+// SetClock replaces the function used to timestamp changes in state
+func (w *Writer) SetClock(now func() time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.now = now
+}
+
+// SetError causes all subsequent calls to fail with the given error, without modifying
+// state, until SetError is called again with nil
+func (w *Writer) SetError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
+
+// Attach causes the state of the given fake Client to be updated whenever the Writer
+// makes a change in state, as would happen via broadcast-events in production. The
+// Client's subscribers are called synchronously, before the Writer's call returns, so
+// they must not call back into the Writer.
+func (w *Writer) Attach(c *Client) {
+	w.mu.Lock()
+	c.SetState(w.stateLocked())
+	w.clients = append(w.clients, c)
+	w.mu.Unlock()
+}
+
+// State returns the current broadcast state as it would be reported to clients
+func (w *Writer) State() core.State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stateLocked()
+}
+
+// Broadcasts returns a copy of all broadcasts recorded so far, in descending order by
+// ID (i.e. most recent first), as they'd be reported in the broadcast history
+func (w *Writer) Broadcasts() []broadcasts.Broadcast {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := make([]broadcasts.Broadcast, 0, len(w.broadcasts))
+	for i := len(w.broadcasts) - 1; i >= 0; i-- {
+		result = append(result, copyBroadcast(&w.broadcasts[i]))
+	}
+	return result
+}
+
+func (w *Writer) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	var broadcast *broadcasts.Broadcast
+	err := w.apply(func(now time.Time) error {
+		if err := w.validate(broadcasts.Transition{Type: broadcasts.TransitionStartBroadcast}); err != nil {
+			return err
+		}
+		latest := w.latest()
+		if latest != nil && w.resumeGrace > 0 && now.Sub(*latest.EndedAt) <= w.resumeGrace {
+			broadcast = w.resume(latest, now)
+			return nil
+		}
+		w.broadcasts = append(w.broadcasts, broadcasts.Broadcast{
+			Id:            len(w.broadcasts) + 1,
+			StartedAt:     now,
+			Screenings:    []broadcasts.Screening{},
+			Interruptions: []broadcasts.Interruption{},
+		})
+		broadcast = w.result(w.latest())
+		return nil
+	})
+	return broadcast, err
+}
+
+func (w *Writer) EndCurrentBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	var broadcast *broadcasts.Broadcast
+	err := w.apply(func(now time.Time) error {
+		if err := w.validate(broadcasts.Transition{Type: broadcasts.TransitionEndBroadcast}); err != nil {
+			return err
+		}
+		latest := w.latest()
+		end(latest, now)
+		broadcast = w.result(latest)
+		return nil
+	})
+	return broadcast, err
+}
+
+func (w *Writer) ResumeBroadcast(ctx context.Context, broadcastId int) (*broadcasts.Broadcast, error) {
+	var broadcast *broadcasts.Broadcast
+	err := w.apply(func(now time.Time) error {
+		latest := w.latest()
+		if latest == nil {
+			return broadcasts.ErrNoSuchBroadcast
+		}
+		if err := w.validate(broadcasts.Transition{Type: broadcasts.TransitionStartBroadcast}); err != nil {
+			return err
+		}
+		if latest.Id != broadcastId {
+			if broadcastId < 1 || broadcastId > len(w.broadcasts) {
+				return broadcasts.ErrNoSuchBroadcast
+			}
+			return broadcasts.ErrBroadcastNotResumable
+		}
+		broadcast = w.resume(latest, now)
+		return nil
+	})
+	return broadcast, err
+}
+
+func (w *Writer) EndStaleBroadcast(ctx context.Context, broadcastId int, endedAt time.Time) error {
+	return w.apply(func(now time.Time) error {
+		if err := w.validate(broadcasts.Transition{Type: broadcasts.TransitionEndBroadcast, BroadcastId: broadcastId}); err != nil {
+			return err
+		}
+		latest := w.latest()
+		if endedAt.Before(latest.StartedAt) {
+			endedAt = latest.StartedAt
+		}
+		for _, screening := range latest.Screenings {
+			if endedAt.Before(screening.StartedAt) {
+				endedAt = screening.StartedAt
+			}
+		}
+		end(latest, endedAt)
+		return nil
+	})
+}
+
+func (w *Writer) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
+	var screening *broadcasts.Screening
+	err := w.apply(func(now time.Time) error {
+		if err := w.validate(broadcasts.Transition{Type: broadcasts.TransitionStartScreening, TapeId: tapeId}); err != nil {
+			return err
+		}
+		latest := w.latest()
+		if current := screeningInProgress(latest); current != nil {
+			current.EndedAt = &now
+		}
+		latest.Screenings = append(latest.Screenings, broadcasts.Screening{
+			Id:        uuid.New(),
+			TapeId:    tapeId,
+			StartedAt: now,
+		})
+		created := latest.Screenings[len(latest.Screenings)-1]
+		screening = &created
+		return nil
+	})
+	return screening, err
+}
+
+func (w *Writer) EndCurrentScreening(ctx context.Context) error {
+	return w.apply(func(now time.Time) error {
+		if err := w.validate(broadcasts.Transition{Type: broadcasts.TransitionEndScreening}); err != nil {
+			return err
+		}
+		screeningInProgress(w.latest()).EndedAt = &now
+		return nil
+	})
+}
+
+// apply runs the given function with the Writer locked, then notifies any attached
+// clients if the function succeeded. Clients are notified before the Writer is
+// unlocked, so that they see changes in the order they were made.
+func (w *Writer) apply(f func(now time.Time) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+	if err := f(w.now().UTC()); err != nil {
+		return err
+	}
+	next := w.stateLocked()
+	for _, c := range w.clients {
+		c.SetState(next)
+	}
+	return nil
+}
+
+// validate checks that the given transition is valid from the current state, using the
+// same state machine as the real writer
+func (w *Writer) validate(t broadcasts.Transition) error {
+	return t.Validate(w.stateLocked())
+}
+
+func (w *Writer) latest() *broadcasts.Broadcast {
+	if len(w.broadcasts) == 0 {
+		return nil
+	}
+	return &w.broadcasts[len(w.broadcasts)-1]
+}
+
+func (w *Writer) resume(b *broadcasts.Broadcast, now time.Time) *broadcasts.Broadcast {
+	b.Interruptions = append(b.Interruptions, broadcasts.Interruption{
+		StartedAt: *b.EndedAt,
+		EndedAt:   now,
+	})
+	b.EndedAt = nil
+	return w.result(b)
+}
+
+func (w *Writer) result(b *broadcasts.Broadcast) *broadcasts.Broadcast {
+	copied := copyBroadcast(b)
+	return &copied
+}
+
+func (w *Writer) stateLocked() core.State {
+	latest := w.latest()
+	if latest == nil || latest.EndedAt != nil {
+		return core.State{}
+	}
+	state := core.State{BroadcastId: latest.Id}
+	if current := screeningInProgress(latest); current != nil {
+		state.ScreeningId = current.Id
+		state.TapeId = current.TapeId
+	}
+	return state
+}
+
+// end marks the given broadcast, along with any screening in progress, as having
+// ended at the given time
+func end(b *broadcasts.Broadcast, endedAt time.Time) {
+	if current := screeningInProgress(b); current != nil {
+		current.EndedAt = &endedAt
+	}
+	b.EndedAt = &endedAt
+}
+
+func screeningInProgress(b *broadcasts.Broadcast) *broadcasts.Screening {
+	if len(b.Screenings) == 0 {
+		return nil
+	}
+	last := &b.Screenings[len(b.Screenings)-1]
+	if last.EndedAt != nil {
+		return nil
+	}
+	return last
+}
+
+func copyBroadcast(b *broadcasts.Broadcast) broadcasts.Broadcast {
+	copied := *b
+	copied.Screenings = append([]broadcasts.Screening{}, b.Screenings...)
+	copied.Interruptions = append([]broadcasts.Interruption{}, b.Interruptions...)
+	return copied
+}
+
+var _ broadcasts.Writer = (*Writer)(nil)
@@ -0,0 +1,303 @@
+package broadcaststest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Writer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWriter(0)
+	w.SetClock(func() time.Time { return now })
+
+	// We can't screen tapes or end broadcasts until a broadcast has been started
+	_, err := w.StartScreening(ctx, 42)
+	assert.ErrorIs(t, err, broadcasts.ErrNoBroadcastInProgress)
+	_, err = w.EndCurrentBroadcast(ctx)
+	assert.ErrorIs(t, err, broadcasts.ErrNoBroadcastInProgress)
+	_, err = w.ResumeBroadcast(ctx, 1)
+	assert.ErrorIs(t, err, broadcasts.ErrNoSuchBroadcast)
+
+	// Starting a broadcast should assign it ID 1, and we can't start another
+	broadcast, err := w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, broadcast.Id)
+	assert.Equal(t, now, broadcast.StartedAt)
+	_, err = w.StartBroadcast(ctx)
+	assert.ErrorIs(t, err, broadcasts.ErrBroadcastInProgress)
+
+	// Screening tapes should implicitly end the previous screening, but we can't screen
+	// the same tape twice in a row
+	err = w.EndCurrentScreening(ctx)
+	assert.ErrorIs(t, err, broadcasts.ErrNoScreeningInProgress)
+	first, err := w.StartScreening(ctx, 42)
+	assert.NoError(t, err)
+	_, err = w.StartScreening(ctx, 42)
+	assert.ErrorIs(t, err, broadcasts.ErrScreeningInProgress)
+	now = now.Add(10 * time.Minute)
+	second, err := w.StartScreening(ctx, 43)
+	assert.NoError(t, err)
+	assert.Equal(t, core.State{BroadcastId: 1, ScreeningId: second.Id, TapeId: 43}, w.State())
+
+	// Ending the broadcast should end the screening along with it
+	now = now.Add(10 * time.Minute)
+	broadcast, err = w.EndCurrentBroadcast(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &now, broadcast.EndedAt)
+	assert.Len(t, broadcast.Screenings, 2)
+	assert.Equal(t, first.Id, broadcast.Screenings[0].Id)
+	assert.Equal(t, &now, broadcast.Screenings[1].EndedAt)
+	assert.Equal(t, core.State{}, w.State())
+
+	// Resuming the broadcast should record an interruption
+	endedAt := now
+	now = now.Add(5 * time.Minute)
+	broadcast, err = w.ResumeBroadcast(ctx, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, broadcast.EndedAt)
+	assert.Equal(t, []broadcasts.Interruption{{StartedAt: endedAt, EndedAt: now}}, broadcast.Interruptions)
+	_, err = w.ResumeBroadcast(ctx, 1)
+	assert.ErrorIs(t, err, broadcasts.ErrBroadcastInProgress)
+
+	// Once a second broadcast has ended, the first can no longer be resumed
+	_, err = w.EndCurrentBroadcast(ctx)
+	assert.NoError(t, err)
+	broadcast, err = w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, broadcast.Id)
+	_, err = w.EndCurrentBroadcast(ctx)
+	assert.NoError(t, err)
+	_, err = w.ResumeBroadcast(ctx, 1)
+	assert.ErrorIs(t, err, broadcasts.ErrBroadcastNotResumable)
+	_, err = w.ResumeBroadcast(ctx, 3)
+	assert.ErrorIs(t, err, broadcasts.ErrNoSuchBroadcast)
+
+	history := w.Broadcasts()
+	assert.Len(t, history, 2)
+	assert.Equal(t, 2, history[0].Id)
+	assert.Equal(t, 1, history[1].Id)
+}
+
+func Test_Writer_resumeGrace(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWriter(time.Minute)
+	w.SetClock(func() time.Time { return now })
+
+	_, err := w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	_, err = w.EndCurrentBroadcast(ctx)
+	assert.NoError(t, err)
+
+	// Starting a broadcast within the grace period should resume the previous one
+	now = now.Add(30 * time.Second)
+	broadcast, err := w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, broadcast.Id)
+	assert.Len(t, broadcast.Interruptions, 1)
+	_, err = w.EndCurrentBroadcast(ctx)
+	assert.NoError(t, err)
+
+	// Once the grace period has elapsed, we should get a new broadcast
+	now = now.Add(2 * time.Minute)
+	broadcast, err = w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, broadcast.Id)
+}
+
+func Test_Writer_EndStaleBroadcast(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWriter(0)
+	w.SetClock(func() time.Time { return now })
+
+	_, err := w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = w.StartScreening(ctx, 42)
+	assert.NoError(t, err)
+
+	// Only the broadcast in progress may be ended
+	err = w.EndStaleBroadcast(ctx, 2, now)
+	assert.ErrorIs(t, err, broadcasts.ErrNoBroadcastInProgress)
+
+	// The end time should be clamped so that it's not before any screening started
+	err = w.EndStaleBroadcast(ctx, 1, now.Add(-2*time.Hour))
+	assert.NoError(t, err)
+	history := w.Broadcasts()
+	assert.Equal(t, &now, history[0].EndedAt)
+	assert.Equal(t, &now, history[0].Screenings[0].EndedAt)
+}
+
+func Test_Writer_SetError(t *testing.T) {
+	ctx := context.Background()
+	w := NewWriter(0)
+
+	w.SetError(fmt.Errorf("oh no"))
+	_, err := w.StartBroadcast(ctx)
+	assert.EqualError(t, err, "oh no")
+	assert.Empty(t, w.Broadcasts())
+
+	w.SetError(nil)
+	_, err = w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+}
+
+func Test_Writer_Attach(t *testing.T) {
+	ctx := context.Background()
+	w := NewWriter(0)
+	c := NewClient(core.State{BroadcastId: 99})
+
+	// Attaching should sync the client to the writer's current state
+	w.Attach(c)
+	assert.Equal(t, core.State{}, c.GetState())
+
+	var states []core.State
+	c.Subscribe(func(prev, next core.State) {
+		states = append(states, next)
+	})
+	_, err := w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	screening, err := w.StartScreening(ctx, 42)
+	assert.NoError(t, err)
+	_, err = w.EndCurrentBroadcast(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.State{
+		{BroadcastId: 1},
+		{BroadcastId: 1, ScreeningId: screening.Id, TapeId: 42},
+		{},
+	}, states)
+}
+
+func Test_Writer_Attach_concurrent(t *testing.T) {
+	// Start a broadcast, and while the first client is being notified of that change,
+	// end the broadcast from another goroutine: the second client should still see
+	// both changes in the order they were made, ending up in the writer's final state
+	ctx := context.Background()
+	w := NewWriter(0)
+	first := NewClient(core.State{})
+	second := NewClient(core.State{})
+	w.Attach(first)
+	w.Attach(second)
+
+	var interrupted atomic.Bool
+	ended := make(chan struct{})
+	first.Subscribe(func(prev, next core.State) {
+		if interrupted.Swap(true) {
+			return
+		}
+		go func() {
+			w.EndCurrentBroadcast(ctx)
+			close(ended)
+		}()
+
+		// If the writer doesn't hold the second change until we're done, give it a
+		// chance to overtake us
+		select {
+		case <-ended:
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+	var states []core.State
+	var mu sync.Mutex
+	second.Subscribe(func(prev, next core.State) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, next)
+	})
+
+	_, err := w.StartBroadcast(ctx)
+	assert.NoError(t, err)
+	<-ended
+
+	assert.Equal(t, core.State{}, w.State())
+	assert.Equal(t, w.State(), second.GetState())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []core.State{{BroadcastId: 1}, {}}, states)
+}
+
+// For any sequence of operations, the Writer should refuse exactly those changes that
+// the broadcast state machine deems invalid, and end up in the state it predicts
+func Test_Writer_followsStateMachine(t *testing.T) {
+	f := func(ops []writerOp) bool {
+		ctx := context.Background()
+		w := NewWriter(0)
+		for _, op := range ops {
+			prev := w.State()
+			wantNext, wantErr := op.transition(w).Apply(prev)
+			var err error
+			switch op.kind {
+			case 0:
+				_, err = w.StartBroadcast(ctx)
+			case 1:
+				_, err = w.EndCurrentBroadcast(ctx)
+			case 2:
+				_, err = w.StartScreening(ctx, op.tapeId)
+			case 3:
+				err = w.EndCurrentScreening(ctx)
+			}
+			if err != wantErr {
+				t.Logf("%+v from %+v: got error %v; want %v", op, prev, err, wantErr)
+				return false
+			}
+			if err == nil && !sameState(w.State(), wantNext) {
+				t.Logf("%+v from %+v: got state %+v; want %+v", op, prev, w.State(), wantNext)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// writerOp is a randomly-generated call to one of the Writer's methods
+type writerOp struct {
+	kind   int
+	tapeId int
+}
+
+func (writerOp) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(writerOp{
+		kind:   r.Intn(4),
+		tapeId: 1 + r.Intn(3),
+	})
+}
+
+// transition returns the state machine transition that corresponds to op. Since the
+// IDs of new broadcasts and screenings are assigned by the writer, they're predicted
+// from the writer's history.
+func (op writerOp) transition(w *Writer) broadcasts.Transition {
+	switch op.kind {
+	case 0:
+		return broadcasts.Transition{Type: broadcasts.TransitionStartBroadcast, BroadcastId: len(w.Broadcasts()) + 1}
+	case 1:
+		return broadcasts.Transition{Type: broadcasts.TransitionEndBroadcast}
+	case 2:
+		return broadcasts.Transition{Type: broadcasts.TransitionStartScreening, ScreeningId: uuid.New(), TapeId: op.tapeId}
+	}
+	return broadcasts.Transition{Type: broadcasts.TransitionEndScreening}
+}
+
+// sameState compares two states, disregarding the values of screening IDs since they're
+// randomly generated
+func sameState(a, b core.State) bool {
+	return a.BroadcastId == b.BroadcastId &&
+		a.TapeId == b.TapeId &&
+		(a.ScreeningId == uuid.Nil) == (b.ScreeningId == uuid.Nil)
+}
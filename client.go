@@ -3,130 +3,254 @@ package broadcasts
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
-	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
 	"github.com/golden-vcr/schemas/core"
-	"github.com/golden-vcr/server-common/rmq"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"golang.org/x/exp/slog"
 )
 
 // Client is a simple interface that keeps track of current broadcast state at all times
 type Client interface {
+	// GetState returns the current broadcast state
 	GetState() core.State
+
+	// Subscribe registers a function that will be called with the previous and new
+	// state each time broadcast state changes. Calls are made in order, from a
+	// goroutine dedicated to that subscriber: a slow subscriber will not delay other
+	// subscribers (or the client itself), and a subscriber that panics will not affect
+	// anything else. A subscriber that falls too far behind is unsubscribed
+	// automatically. Calling the returned function unsubscribes, after which no
+	// further calls will be made.
+	Subscribe(f SubscriberFunc) (unsubscribe func())
+
+	// WaitFor blocks until broadcast state satisfies the given predicate, returning the
+	// state that satisfied it. If the current state already satisfies the predicate,
+	// it returns immediately; otherwise it waits for changes in state as they're
+	// received. If the context is canceled first, it returns the current state along
+	// with the context's error. See IsLive, IsScreening, and BroadcastChanged for
+	// ready-made predicates.
+	WaitFor(ctx context.Context, predicate func(core.State) bool) (core.State, error)
+
+	// Healthy returns true if the client is currently receiving changes in broadcast
+	// state as they occur. If false, the client has lost its connection and is
+	// attempting to reconnect, so GetState may be out of date.
+	Healthy() bool
+
+	// LastSyncedAt returns the time at which the client's state was last confirmed to
+	// be current, either by resolving state from the broadcasts service or by receiving
+	// an event that describes a change in state
+	LastSyncedAt() time.Time
 }
 
+// minReconnectDelay is how long the client initially waits before attempting to
+// reconnect after losing its connection
+const minReconnectDelay = 500 * time.Millisecond
+
+// maxReconnectDelay is the longest the client will wait between consecutive attempts
+// to reconnect
+const maxReconnectDelay = 30 * time.Second
+
+// errSequenceGap indicates that an event's sequence number shows that we've missed one
+// or more preceding events
+var errSequenceGap = errors.New("gap in broadcast-events sequence")
+
 // NewClient initializes a broadcasts.Client that will keep track of platform-wide
 // broadcast state: the client makes an initial HTTP request to the broadcasts service,
 // and thereafter it consumes from the 'broadcast-events' queue in order to keep abreast
 // subsequent changes in state. Calling GetState() on the resulting client (thread-safe)
 // will return the current broadcast state at any time.
+//
+// If the client stops receiving from broadcast-events, it will reconnect with backoff
+// using the given AMQP connection, then resolve state from the broadcasts service once
+// again to account for any changes it missed. If the AMQP connection itself may be
+// lost, use DialClient instead, so that the client can open a new connection.
 func NewClient(ctx context.Context, logger *slog.Logger, broadcastsUrl string, amqpConn *amqp.Connection) (Client, error) {
-	// Get our current broadcast state as a starting point, so that we're fully
-	// initialized without having to wait on events to arrive
-	state, err := resolveInitialState(ctx, broadcastsUrl)
+	return newClient(ctx, logger, broadcastsUrl, func(ctx context.Context) (<-chan amqp.Delivery, func(), error) {
+		return recvBroadcastEvents(ctx, amqpConn)
+	})
+}
+
+// DialClient initializes a broadcasts.Client that connects to the AMQP server at the
+// given URL, opening a new connection whenever its connection is lost. It's otherwise
+// identical to a Client initialized with NewClient.
+func DialClient(ctx context.Context, logger *slog.Logger, broadcastsUrl string, amqpUrl string) (Client, error) {
+	var amqpConn *amqp.Connection
+	return newClient(ctx, logger, broadcastsUrl, func(ctx context.Context) (<-chan amqp.Delivery, func(), error) {
+		if amqpConn == nil || amqpConn.IsClosed() {
+			conn, err := amqp.Dial(amqpUrl)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to connect to AMQP server: %w", err)
+			}
+			amqpConn = conn
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+		}
+		return recvBroadcastEvents(ctx, amqpConn)
+	})
+}
+
+// recvBroadcastEvents opens a new channel on the given connection and begins receiving
+// from the broadcast-events queue. The returned function stops receiving, deleting our
+// queue and closing the channel without closing the connection itself.
+func recvBroadcastEvents(ctx context.Context, amqpConn *amqp.Connection) (<-chan amqp.Delivery, func(), error) {
+	// rmq.NewConsumer would declare the same exchange and queue, but closing its
+	// consumer also closes the connection, which we may share with the caller
+	ch, err := amqpConn.Channel()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to open AMQP channel for broadcast-events: %w", err)
+	}
+	q, err := declareBroadcastEventsQueue(ch)
+	if err != nil {
+		ch.Close()
+		return nil, nil, fmt.Errorf("Failed to initialize AMQP consumer for broadcast-events: %w", err)
+	}
+	disconnect := func() {
+		// Our queue is exclusive, but it would otherwise outlive the channel for as
+		// long as the connection remains open
+		ifUnused := false
+		ifEmpty := false
+		noWait := false
+		ch.QueueDelete(q.Name, ifUnused, ifEmpty, noWait)
+		ch.Close()
+	}
+
+	autoAck := true
+	exclusive := false
+	noLocal := false
+	noWait := false
+	broadcastEvents, err := ch.ConsumeWithContext(ctx, q.Name, "", autoAck, exclusive, noLocal, noWait, nil)
+	if err != nil {
+		disconnect()
+		return nil, nil, fmt.Errorf("Failed to init recv channel on broadcast-events consumer: %w", err)
+	}
+	return broadcastEvents, disconnect, nil
+}
+
+// declareBroadcastEventsQueue declares the broadcast-events fanout exchange, along with
+// a temporary queue bound to it, exactly as rmq.NewConsumer does
+func declareBroadcastEventsQueue(ch *amqp.Channel) (*amqp.Queue, error) {
+	durable := true
+	autoDelete := false
+	internal := false
+	noWait := false
+	if err := ch.ExchangeDeclare("broadcast-events", "fanout", durable, autoDelete, internal, noWait, nil); err != nil {
+		return nil, err
+	}
+
+	durable = false
+	exclusive := true
+	q, err := ch.QueueDeclare("", durable, autoDelete, exclusive, noWait, nil)
 	if err != nil {
 		return nil, err
 	}
+	if err := ch.QueueBind(q.Name, "", "broadcast-events", noWait, nil); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// connectFunc begins receiving from the broadcast-events queue, returning a function
+// that must be called to stop receiving once the deliveries channel is no longer used
+type connectFunc func(ctx context.Context) (deliveries <-chan amqp.Delivery, disconnect func(), err error)
 
-	// Prepare a client struct that encapsulates our current state
+// resolveFunc resolves the current broadcast state, along with its version
+type resolveFunc func(ctx context.Context) (*State, error)
+
+func newClient(ctx context.Context, logger *slog.Logger, broadcastsUrl string, connect connectFunc) (*client, error) {
 	c := &client{
-		currentState: *state,
+		logger:      logger,
+		subscribers: make(map[*subscriber]struct{}),
+		connect:     connect,
+		resolve: func(ctx context.Context) (*State, error) {
+			return resolveInitialState(ctx, broadcastsUrl)
+		},
+		minReconnectDelay: minReconnectDelay,
+		maxReconnectDelay: maxReconnectDelay,
 	}
 
 	// Initialize a consumer so that whenenver broadcast state changes, we'll be
 	// notified
-	broadcastEventsConsumer, err := rmq.NewConsumer(amqpConn, "broadcast-events")
+	broadcastEvents, disconnect, err := c.connect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to initialize AMQP consumer for broadcast-events: %w", err)
+		return nil, err
 	}
-	broadcastEvents, err := broadcastEventsConsumer.Recv(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to init recv channel on broadcast-events consumer: %w", err)
+
+	// Get our current broadcast state as a starting point, so that we're fully
+	// initialized without having to wait on events to arrive
+	if err := c.sync(ctx); err != nil {
+		disconnect()
+		return nil, err
 	}
+	c.setHealthy(true)
 
 	// Run a goroutine that will update our client's state any time we consume an event
-	// from the broadcast-events queue
-	go func() {
-		done := false
-		for !done {
-			select {
-			case <-ctx.Done():
-				logger.Info("Consumer context canceled; broadcast state client shutting down")
-				done = true
-			case d, ok := <-broadcastEvents:
-				if ok {
-					var ev ebroadcast.Event
-					if err := json.Unmarshal(d.Body, &ev); err != nil {
-						logger.Error("Failed to unmarshal event from broadcast-events; broadcast state client shutting down", "error", err)
-						done = true
-						break
-					}
-					c.handleEvent(&ev, logger)
-				} else {
-					logger.Info("Channel is closed; broadcast state client shutting down")
-					done = true
-				}
-			}
-		}
-	}()
-
+	// from the broadcast-events queue, reconnecting as needed
+	go c.run(ctx, broadcastEvents, disconnect)
 	return c, nil
 }
 
 // resolveInitialState makes a request to the broadcasts service in order to resolve an
 // initial value for our platform-wide broadcast state
-func resolveInitialState(ctx context.Context, broadcastsUrl string) (*core.State, error) {
-	// Prepare a request to the broadcasts service's history API, to retrieve data for
-	// the most recent broadcast
-	url := broadcastsUrl + "/history"
+func resolveInitialState(ctx context.Context, broadcastsUrl string) (*State, error) {
+	state, _, err := fetchState(ctx, broadcastsUrl, "")
+	return state, err
+}
+
+// fetchState makes a request to the broadcasts service's state API, which reports the
+// current broadcast state directly, returning that state along with its ETag. If etag
+// is non-empty and matches the current state, the state is unchanged, and nil is
+// returned in its place.
+func fetchState(ctx context.Context, broadcastsUrl string, etag string) (*State, string, error) {
+	// Prepare a request to the broadcasts service's state API, only asking for the
+	// state to be sent if it differs from the state we already have
+	url := broadcastsUrl + "/state"
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("if-none-match", etag)
 	}
-	q := req.URL.Query()
-	q.Set("n", "1")
-	req.URL.RawQuery = q.Encode()
 
 	// Make the request, and ensure that we got a valid response
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if etag != "" && res.StatusCode == http.StatusNotModified {
+		return nil, etag, nil
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("got response %d from %s", res.StatusCode, url)
-	}
-	var h History
-	if err := json.NewDecoder(res.Body).Decode(&h); err != nil {
-		return nil, fmt.Errorf("failed to decode response body from %s: %w", url, err)
-	}
-
-	// Resolve our initial state value based on the data reported from the API
-	var state core.State
-	if len(h.Broadcasts) > 0 {
-		if broadcast := h.Broadcasts[0]; broadcast.EndedAt == nil {
-			// If our most recent broadcast is still in progress, that's our current
-			// broadcast ID
-			state.BroadcastId = broadcast.Id
-			if len(broadcast.Screenings) > 0 {
-				if screening := broadcast.Screenings[len(broadcast.Screenings)-1]; screening.EndedAt == nil {
-					// If the latest screening in that broadcast is still in progress,
-					// that gives us our current screening and tape IDs
-					state.ScreeningId = screening.Id
-					state.TapeId = screening.TapeId
-				}
-			}
-		}
+		return nil, "", fmt.Errorf("got response %d from %s", res.StatusCode, url)
 	}
-	return &state, nil
+	var state State
+	if err := json.NewDecoder(res.Body).Decode(&state); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response body from %s: %w", url, err)
+	}
+	return &state, res.Header.Get("etag"), nil
 }
 
 type client struct {
+	logger       *slog.Logger
 	currentState core.State
+	lastSeq      int64
+	subscribers  map[*subscriber]struct{}
+	healthy      bool
+	lastSyncedAt time.Time
 	mu           sync.RWMutex
+
+	connect           connectFunc
+	resolve           resolveFunc
+	minReconnectDelay time.Duration
+	maxReconnectDelay time.Duration
 }
 
 func (c *client) GetState() core.State {
@@ -135,11 +259,227 @@ func (c *client) GetState() core.State {
 	return c.currentState
 }
 
-func (c *client) handleEvent(ev *ebroadcast.Event, logger *slog.Logger) {
+func (c *client) Healthy() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.healthy
+}
+
+func (c *client) LastSyncedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastSyncedAt
+}
+
+func (c *client) Subscribe(f SubscriberFunc) func() {
+	s := newSubscriber(f, c.logger)
+
+	c.mu.Lock()
+	c.subscribers[s] = struct{}{}
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		delete(c.subscribers, s)
+		c.mu.Unlock()
+		s.close()
+	}
+}
+
+// run consumes events until the context is canceled, reconnecting whenever the
+// deliveries channel is closed. disconnect is called once we're done with each
+// deliveries channel.
+func (c *client) run(ctx context.Context, deliveries <-chan amqp.Delivery, disconnect func()) {
+	for {
+		c.consume(ctx, deliveries)
+		disconnect()
+		if ctx.Err() != nil {
+			c.logger.Info("Consumer context canceled; broadcast state client shutting down")
+			return
+		}
+
+		// Our channel has been closed out from under us: until we've reconnected, we
+		// can't be sure that our state is current
+		c.logger.Warn("Channel is closed; broadcast state client will reconnect")
+		c.setHealthy(false)
+		deliveries, disconnect = c.reconnect(ctx)
+		if deliveries == nil {
+			c.logger.Info("Consumer context canceled; broadcast state client shutting down")
+			return
+		}
+		c.setHealthy(true)
+		c.logger.Info("Broadcast state client reconnected")
+	}
+}
+
+// consume handles each event from the deliveries channel until it's closed or the
+// context is canceled. A message that can't be parsed is logged and skipped.
+func (c *client) consume(ctx context.Context, deliveries <-chan amqp.Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			var ev SequencedEvent
+			if err := json.Unmarshal(d.Body, &ev); err != nil {
+				c.logger.Error("Failed to unmarshal event from broadcast-events; ignoring it", "error", err)
+				continue
+			}
+			if err := c.handleEvent(&ev); err != nil {
+				if errors.Is(err, ErrInvalidTransition) {
+					c.logger.Error("Got invalid event from broadcast-events; ignoring it", "error", err, "broadcastEvent", ev)
+					continue
+				}
+				c.logger.Warn("Event from broadcast-events is inconsistent with current state; resyncing", "error", err, "broadcastEvent", ev)
+				if err := c.sync(ctx); err != nil {
+					c.logger.Error("Failed to resync broadcast state", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// reconnect attempts to begin receiving from broadcast-events again, then resolves
+// state anew to catch up with any changes that occurred while we were disconnected. It
+// retries with exponential backoff until it succeeds, returning the new deliveries
+// channel and the function that stops receiving from it, or until the context is
+// canceled, returning nil. An attempt that fails to resync is disconnected before we
+// retry.
+func (c *client) reconnect(ctx context.Context) (<-chan amqp.Delivery, func()) {
+	delay := c.minReconnectDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(delay):
+		}
+
+		deliveries, disconnect, err := c.connect(ctx)
+		if err == nil {
+			// We're receiving events again, so any subsequent changes will be applied
+			// on top of the state we resolve now
+			err = c.sync(ctx)
+			if err == nil {
+				return deliveries, disconnect
+			}
+			disconnect()
+		}
+		c.logger.Error("Failed to reconnect broadcast state client", "error", err, "retryDelay", delay)
+
+		delay *= 2
+		if delay > c.maxReconnectDelay {
+			delay = c.maxReconnectDelay
+		}
+	}
+}
+
+// sync resolves the current broadcast state and adopts it as our own
+func (c *client) sync(ctx context.Context) error {
+	state, err := c.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	c.adoptState(state)
+	return nil
+}
+
+func (c *client) setHealthy(healthy bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.healthy = healthy
+}
+
+// markSynced records that our current state has just been confirmed to be current
+func (c *client) markSynced() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSyncedAt = time.Now()
+}
+
+// handleEvent applies the change described by a broadcast-events message to our
+// current state. If the event is malformed, it's ignored and an ErrInvalidTransition is
+// returned; if its sequence number shows that it's already reflected in our state, it's
+// ignored. If the event's sequence number skips ahead, or if the event describes a
+// change that's impossible from our current state, then we must have missed an earlier
+// event: we adopt the state the event leaves us in, since it's our best guess, but the
+// returned error indicates that we need to resync.
+func (c *client) handleEvent(ev *SequencedEvent) error {
+	t, err := TransitionFromEvent(&ev.Event)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop any event that's a duplicate of one we've already handled, or that was
+	// produced before the state we most recently resolved
+	if ev.Seq != 0 && ev.Seq <= c.lastSeq {
+		c.logger.Debug("Ignoring stale event from broadcast-events", "seq", ev.Seq, "lastSeq", c.lastSeq)
+		return nil
+	}
+	var gapErr error
+	if ev.Seq != 0 && c.lastSeq != 0 && ev.Seq != c.lastSeq+1 {
+		gapErr = fmt.Errorf("%w: expected seq %d; got %d", errSequenceGap, c.lastSeq+1, ev.Seq)
+	}
+
+	prev := c.currentState
+	next, err := t.Apply(prev)
+	if err != nil {
+		// An event that doesn't change our state (e.g. one that's been redelivered) is
+		// redundant but harmless
+		next = ev.ToState(prev)
+		if next == prev {
+			err = nil
+		}
+	}
+
+	// An event with no sequence number leaves us unable to tell which events follow it
+	c.lastSeq = ev.Seq
+	c.setStateLocked(next, ev)
+	if gapErr != nil {
+		return gapErr
+	}
+	return err
+}
+
+// adoptState replaces our current state with state resolved from the broadcasts
+// service, unless we've already handled events that are newer than that state
+func (c *client) adoptState(state *State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state.Version < c.lastSeq {
+		c.logger.Warn("Ignoring resolved broadcast state that's older than current state", "version", state.Version, "lastSeq", c.lastSeq)
+		return
+	}
+	c.lastSeq = state.Version
+	c.setStateLocked(state.State, nil)
+}
 
+// setStateLocked updates our current state, marking it as having been synced just now,
+// and notifies all subscribers if the state has changed. ev is the event that caused
+// the change, if any. The caller must hold c.mu.
+func (c *client) setStateLocked(next core.State, ev *SequencedEvent) {
 	prev := c.currentState
-	c.currentState = ev.ToState(prev)
-	logger.Info("Broadcast state changed", "broadcastEvent", ev, "prevState", prev, "newState", c.currentState)
+	c.currentState = next
+	c.lastSyncedAt = time.Now()
+	if ev != nil {
+		c.logger.Info("Broadcast state changed", "broadcastEvent", ev, "prevState", prev, "newState", c.currentState)
+	} else if next != prev {
+		c.logger.Info("Broadcast state resynced", "prevState", prev, "newState", c.currentState)
+	}
+
+	// Notify all subscribers if our state has actually changed: since we're still
+	// holding the lock, every subscriber is given changes in the same order
+	if c.currentState != prev {
+		change := stateChange{prev: prev, next: c.currentState}
+		for s := range c.subscribers {
+			if !s.push(change) {
+				delete(c.subscribers, s)
+			}
+		}
+	}
 }
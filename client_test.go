@@ -0,0 +1,613 @@
+package broadcasts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+func Test_client_Subscribe(t *testing.T) {
+	screeningId := uuid.MustParse("f29a4ffe-cb9f-43ba-9f91-a3b1fa350472")
+	events := []ebroadcast.Event{
+		{
+			Type:      ebroadcast.EventTypeBroadcastStarted,
+			Broadcast: ebroadcast.BroadcastData{Id: 55},
+		},
+		{
+			// A redundant event that doesn't change state should not be announced
+			Type:      ebroadcast.EventTypeBroadcastStarted,
+			Broadcast: ebroadcast.BroadcastData{Id: 55},
+		},
+		{
+			Type:      ebroadcast.EventTypeScreeningStarted,
+			Broadcast: ebroadcast.BroadcastData{Id: 55},
+			Screening: &ebroadcast.ScreeningData{Id: screeningId, TapeId: 109},
+		},
+		{
+			Type:      ebroadcast.EventTypeBroadcastFinished,
+			Broadcast: ebroadcast.BroadcastData{Id: 55},
+		},
+	}
+	wantChanges := []stateChange{
+		{
+			prev: core.State{},
+			next: core.State{BroadcastId: 55},
+		},
+		{
+			prev: core.State{BroadcastId: 55},
+			next: core.State{BroadcastId: 55, ScreeningId: screeningId, TapeId: 109},
+		},
+		{
+			prev: core.State{BroadcastId: 55, ScreeningId: screeningId, TapeId: 109},
+			next: core.State{},
+		},
+	}
+
+	t.Run("subscribers receive all changes in order", func(t *testing.T) {
+		c := newTestClient()
+		r := &recorder{}
+		unsubscribe := c.Subscribe(r.record)
+		defer unsubscribe()
+
+		for i := range events {
+			c.handleEvent(&SequencedEvent{Event: events[i]})
+		}
+		assert.Equal(t, wantChanges, r.wait(t, len(wantChanges)))
+	})
+
+	t.Run("a slow subscriber does not block the client or other subscribers", func(t *testing.T) {
+		c := newTestClient()
+		release := make(chan struct{})
+		slow := &recorder{}
+		unsubscribeSlow := c.Subscribe(func(prev, next core.State) {
+			<-release
+			slow.record(prev, next)
+		})
+		defer unsubscribeSlow()
+		fast := &recorder{}
+		unsubscribeFast := c.Subscribe(fast.record)
+		defer unsubscribeFast()
+
+		for i := range events {
+			c.handleEvent(&SequencedEvent{Event: events[i]})
+		}
+		assert.Equal(t, wantChanges, fast.wait(t, len(wantChanges)))
+
+		close(release)
+		assert.Equal(t, wantChanges, slow.wait(t, len(wantChanges)))
+	})
+
+	t.Run("a subscriber that panics does not affect other subscribers", func(t *testing.T) {
+		c := newTestClient()
+		numPanics := 0
+		var mu sync.Mutex
+		unsubscribePanicky := c.Subscribe(func(prev, next core.State) {
+			mu.Lock()
+			numPanics++
+			mu.Unlock()
+			panic("oh no")
+		})
+		defer unsubscribePanicky()
+		r := &recorder{}
+		unsubscribe := c.Subscribe(r.record)
+		defer unsubscribe()
+
+		for i := range events {
+			c.handleEvent(&SequencedEvent{Event: events[i]})
+		}
+		assert.Equal(t, wantChanges, r.wait(t, len(wantChanges)))
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return numPanics == len(wantChanges)
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("a subscriber that falls too far behind is unsubscribed", func(t *testing.T) {
+		c := newTestClient()
+		release := make(chan struct{})
+		defer close(release)
+		stuck := &recorder{}
+		unsubscribeStuck := c.Subscribe(func(prev, next core.State) {
+			<-release
+			stuck.record(prev, next)
+		})
+		defer unsubscribeStuck()
+		r := &recorder{}
+		unsubscribe := c.Subscribe(r.record)
+		defer unsubscribe()
+
+		numChanges := 2 * subscriberQueueSize
+		for i := 0; i < numChanges; i++ {
+			ev := ebroadcast.Event{
+				Type:      ebroadcast.EventTypeBroadcastStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			}
+			if i%2 == 1 {
+				ev.Type = ebroadcast.EventTypeBroadcastFinished
+			}
+			c.handleEvent(&SequencedEvent{Event: ev})
+
+			// Let the other subscriber keep up, so that only the stuck one falls behind
+			r.wait(t, i+1)
+		}
+		assert.Len(t, r.wait(t, numChanges), numChanges)
+
+		c.mu.RLock()
+		numSubscribers := len(c.subscribers)
+		c.mu.RUnlock()
+		assert.Equal(t, 1, numSubscribers)
+	})
+
+	t.Run("unsubscribing stops delivery", func(t *testing.T) {
+		c := newTestClient()
+		r := &recorder{}
+		unsubscribe := c.Subscribe(r.record)
+
+		c.handleEvent(&SequencedEvent{Event: events[0]})
+		assert.Equal(t, wantChanges[:1], r.wait(t, 1))
+
+		unsubscribe()
+		unsubscribe()
+		for i := range events[1:] {
+			c.handleEvent(&SequencedEvent{Event: events[1+i]})
+		}
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, wantChanges[:1], r.wait(t, 1))
+	})
+}
+
+func newTestClient() *client {
+	return &client{
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+type recorder struct {
+	changes []stateChange
+	mu      sync.Mutex
+}
+
+func (r *recorder) record(prev, next core.State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, stateChange{prev: prev, next: next})
+}
+
+func (r *recorder) wait(t *testing.T, n int) []stateChange {
+	var changes []stateChange
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		changes = append([]stateChange(nil), r.changes...)
+		return len(changes) >= n
+	}, time.Second, time.Millisecond)
+	return changes
+}
+
+func Test_client_run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Prepare a client whose connections we control: the first reconnect attempt will
+	// fail, and the second will succeed
+	connections := make(chan chan amqp.Delivery, 1)
+	numConnectAttempts := 0
+	var resolvedState core.State
+	var resolvedVersion int64
+	var mu sync.Mutex
+	c := newTestClient()
+	c.minReconnectDelay = time.Millisecond
+	c.maxReconnectDelay = 4 * time.Millisecond
+	c.connect = func(ctx context.Context) (<-chan amqp.Delivery, func(), error) {
+		mu.Lock()
+		defer mu.Unlock()
+		numConnectAttempts++
+		if numConnectAttempts == 1 {
+			return nil, nil, fmt.Errorf("connection refused")
+		}
+		ch := make(chan amqp.Delivery)
+		connections <- ch
+		return ch, func() {}, nil
+	}
+	c.resolve = func(ctx context.Context) (*State, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &State{State: resolvedState, Version: resolvedVersion}, nil
+	}
+	c.setHealthy(true)
+	r := &recorder{}
+	unsubscribe := c.Subscribe(r.record)
+	defer unsubscribe()
+
+	deliveries := make(chan amqp.Delivery)
+	go c.run(ctx, deliveries, func() {})
+
+	// A message we can't parse should be skipped without affecting later messages
+	deliveries <- amqp.Delivery{Body: []byte("not-json")}
+	deliveries <- amqp.Delivery{Body: []byte(`{"type":"broadcast-started","broadcast":{"id":55,"started_at":"1997-09-01T12:00:00Z"}}`)}
+	assert.Equal(t, []stateChange{
+		{prev: core.State{}, next: core.State{BroadcastId: 55}},
+	}, r.wait(t, 1))
+	assert.True(t, c.Healthy())
+	syncedBeforeReconnect := c.LastSyncedAt()
+	assert.False(t, syncedBeforeReconnect.IsZero())
+
+	// If our channel closes while the broadcast ends, we should reconnect and resync,
+	// informing subscribers of the change we missed
+	mu.Lock()
+	resolvedState = core.State{}
+	mu.Unlock()
+	close(deliveries)
+	reconnected := <-connections
+	assert.Equal(t, []stateChange{
+		{prev: core.State{}, next: core.State{BroadcastId: 55}},
+		{prev: core.State{BroadcastId: 55}, next: core.State{}},
+	}, r.wait(t, 2))
+	assert.Eventually(t, c.Healthy, time.Second, time.Millisecond)
+	assert.True(t, c.LastSyncedAt().After(syncedBeforeReconnect))
+	mu.Lock()
+	assert.Equal(t, 2, numConnectAttempts)
+	mu.Unlock()
+
+	// We should continue receiving events on our new channel
+	reconnected <- amqp.Delivery{Body: []byte(`{"type":"broadcast-started","broadcast":{"id":56,"started_at":"1997-09-02T12:00:00Z"}}`)}
+	assert.Equal(t, core.State{BroadcastId: 56}, r.wait(t, 3)[2].next)
+
+	// If we get an event that's impossible given our current state, we must have missed
+	// something: we should adopt the state implied by the event, then resync
+	mu.Lock()
+	resolvedState = core.State{BroadcastId: 57}
+	mu.Unlock()
+	reconnected <- amqp.Delivery{Body: []byte(`{"type":"screening-started","broadcast":{"id":57,"started_at":"1997-09-03T12:00:00Z"},"screening":{"id":"f29a4ffe-cb9f-43ba-9f91-a3b1fa350472","started_at":"1997-09-03T12:15:00Z","tape_id":109}}`)}
+	screeningId := uuid.MustParse("f29a4ffe-cb9f-43ba-9f91-a3b1fa350472")
+	assert.Equal(t, []stateChange{
+		{prev: core.State{BroadcastId: 56}, next: core.State{BroadcastId: 57, ScreeningId: screeningId, TapeId: 109}},
+		{prev: core.State{BroadcastId: 57, ScreeningId: screeningId, TapeId: 109}, next: core.State{BroadcastId: 57}},
+	}, r.wait(t, 5)[3:])
+
+	// If we notice a gap in event sequence numbers, we should resync
+	mu.Lock()
+	resolvedState = core.State{}
+	resolvedVersion = 102
+	mu.Unlock()
+	reconnected <- amqp.Delivery{Body: []byte(`{"type":"screening-finished","broadcast":{"id":57,"started_at":"1997-09-03T12:00:00Z"},"seq":100}`)}
+	reconnected <- amqp.Delivery{Body: []byte(`{"type":"screening-started","broadcast":{"id":57,"started_at":"1997-09-03T12:00:00Z"},"screening":{"id":"f29a4ffe-cb9f-43ba-9f91-a3b1fa350472","started_at":"1997-09-03T12:15:00Z","tape_id":110},"seq":102}`)}
+	changes := r.wait(t, 7)
+	assert.Equal(t, core.State{BroadcastId: 57, ScreeningId: screeningId, TapeId: 110}, changes[5].next)
+	assert.Equal(t, core.State{}, changes[6].next)
+}
+
+func Test_client_handleEvent(t *testing.T) {
+	screeningId := uuid.MustParse("f29a4ffe-cb9f-43ba-9f91-a3b1fa350472")
+	tests := []struct {
+		name      string
+		state     core.State
+		ev        ebroadcast.Event
+		wantState core.State
+		wantErr   error
+	}{
+		{
+			"valid transition is applied",
+			core.State{BroadcastId: 55},
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeScreeningStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+				Screening: &ebroadcast.ScreeningData{Id: screeningId, TapeId: 109},
+			},
+			core.State{BroadcastId: 55, ScreeningId: screeningId, TapeId: 109},
+			nil,
+		},
+		{
+			"redundant event is not an error",
+			core.State{BroadcastId: 55},
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeBroadcastStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			},
+			core.State{BroadcastId: 55},
+			nil,
+		},
+		{
+			"impossible event is applied but reported",
+			core.State{},
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeScreeningStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+				Screening: &ebroadcast.ScreeningData{Id: screeningId, TapeId: 109},
+			},
+			core.State{BroadcastId: 55, ScreeningId: screeningId, TapeId: 109},
+			ErrNoBroadcastInProgress,
+		},
+		{
+			"event for a different broadcast is reported",
+			core.State{BroadcastId: 55},
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeBroadcastStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 56},
+			},
+			core.State{BroadcastId: 56},
+			ErrBroadcastInProgress,
+		},
+		{
+			"malformed event is ignored",
+			core.State{BroadcastId: 55},
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeScreeningStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			},
+			core.State{BroadcastId: 55},
+			ErrInvalidTransition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient()
+			c.currentState = tt.state
+			err := c.handleEvent(&SequencedEvent{Event: tt.ev})
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantState, c.GetState())
+		})
+	}
+}
+
+func Test_client_Healthy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Prepare a client that can never reconnect
+	c := newTestClient()
+	c.minReconnectDelay = time.Millisecond
+	c.maxReconnectDelay = time.Millisecond
+	c.connect = func(ctx context.Context) (<-chan amqp.Delivery, func(), error) {
+		return nil, nil, fmt.Errorf("connection refused")
+	}
+	c.setHealthy(true)
+
+	// Once our channel is closed, we should report that we're no longer healthy
+	deliveries := make(chan amqp.Delivery)
+	done := make(chan struct{})
+	go func() {
+		c.run(ctx, deliveries, func() {})
+		close(done)
+	}()
+	close(deliveries)
+	assert.Eventually(t, func() bool { return !c.Healthy() }, time.Second, time.Millisecond)
+
+	// Canceling the context should stop our attempts to reconnect
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("client did not shut down")
+	}
+}
+
+func Test_client_disconnect(t *testing.T) {
+	t.Run("failed initial sync disconnects", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			http.Error(res, "service unavailable", http.StatusServiceUnavailable)
+		}))
+		defer s.Close()
+
+		counter := &connectionCounter{}
+		c, err := newClient(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), s.URL, counter.connect)
+		assert.Error(t, err)
+		assert.Nil(t, c)
+		assert.Equal(t, 1, counter.numConnects())
+		assert.Equal(t, 0, counter.numOpen())
+	})
+
+	t.Run("failed resyncs while reconnecting disconnect", func(t *testing.T) {
+		counter := &connectionCounter{}
+		numResolveAttempts := 0
+		c := newTestClient()
+		c.minReconnectDelay = time.Millisecond
+		c.maxReconnectDelay = time.Millisecond
+		c.connect = counter.connect
+		c.resolve = func(ctx context.Context) (*State, error) {
+			numResolveAttempts++
+			if numResolveAttempts < 3 {
+				return nil, fmt.Errorf("service unavailable")
+			}
+			return &State{}, nil
+		}
+
+		deliveries, disconnect := c.reconnect(context.Background())
+		assert.NotNil(t, deliveries)
+		assert.Equal(t, 3, counter.numConnects())
+		assert.Equal(t, 1, counter.numOpen())
+
+		disconnect()
+		assert.Equal(t, 0, counter.numOpen())
+	})
+
+	t.Run("shutting down disconnects", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		counter := &connectionCounter{}
+		c := newTestClient()
+		deliveries, disconnect, err := counter.connect(ctx)
+		assert.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			c.run(ctx, deliveries, disconnect)
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("client did not shut down")
+		}
+		assert.Equal(t, 0, counter.numOpen())
+	})
+}
+
+// connectionCounter is a connectFunc that keeps track of how many of the deliveries
+// channels it's opened have yet to be disconnected
+type connectionCounter struct {
+	connects int
+	open     int
+	mu       sync.Mutex
+}
+
+func (cc *connectionCounter) connect(ctx context.Context) (<-chan amqp.Delivery, func(), error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.connects++
+	cc.open++
+
+	var once sync.Once
+	return make(chan amqp.Delivery), func() {
+		once.Do(func() {
+			cc.mu.Lock()
+			defer cc.mu.Unlock()
+			cc.open--
+		})
+	}, nil
+}
+
+func (cc *connectionCounter) numConnects() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.connects
+}
+
+func (cc *connectionCounter) numOpen() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.open
+}
+
+func Test_client_handleEvent_seq(t *testing.T) {
+	started := ebroadcast.Event{
+		Type:      ebroadcast.EventTypeBroadcastStarted,
+		Broadcast: ebroadcast.BroadcastData{Id: 55},
+	}
+	finished := ebroadcast.Event{
+		Type:      ebroadcast.EventTypeBroadcastFinished,
+		Broadcast: ebroadcast.BroadcastData{Id: 55},
+	}
+	tests := []struct {
+		name        string
+		state       core.State
+		lastSeq     int64
+		ev          SequencedEvent
+		wantState   core.State
+		wantLastSeq int64
+		wantErr     error
+	}{
+		{
+			"next event in sequence is applied",
+			core.State{},
+			10,
+			SequencedEvent{Event: started, Seq: 11},
+			core.State{BroadcastId: 55},
+			11,
+			nil,
+		},
+		{
+			"first event is applied if we have no prior sequence number",
+			core.State{},
+			0,
+			SequencedEvent{Event: started, Seq: 11},
+			core.State{BroadcastId: 55},
+			11,
+			nil,
+		},
+		{
+			"duplicate event is dropped",
+			core.State{},
+			11,
+			SequencedEvent{Event: started, Seq: 11},
+			core.State{},
+			11,
+			nil,
+		},
+		{
+			"stale event is dropped",
+			core.State{BroadcastId: 55},
+			12,
+			SequencedEvent{Event: finished, Seq: 9},
+			core.State{BroadcastId: 55},
+			12,
+			nil,
+		},
+		{
+			"gap in sequence is applied but reported",
+			core.State{},
+			10,
+			SequencedEvent{Event: started, Seq: 13},
+			core.State{BroadcastId: 55},
+			13,
+			errSequenceGap,
+		},
+		{
+			"event with no sequence number is applied without checking sequence",
+			core.State{},
+			10,
+			SequencedEvent{Event: started},
+			core.State{BroadcastId: 55},
+			0,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient()
+			c.currentState = tt.state
+			c.lastSeq = tt.lastSeq
+			err := c.handleEvent(&tt.ev)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantState, c.GetState())
+			assert.Equal(t, tt.wantLastSeq, c.lastSeq)
+		})
+	}
+}
+
+func Test_client_adoptState(t *testing.T) {
+	c := newTestClient()
+	c.adoptState(&State{State: core.State{BroadcastId: 55}, Version: 10})
+	assert.Equal(t, core.State{BroadcastId: 55}, c.GetState())
+	assert.Equal(t, int64(10), c.lastSeq)
+
+	// Events that are already reflected in our resolved state should be dropped
+	err := c.handleEvent(&SequencedEvent{
+		Event: ebroadcast.Event{Type: ebroadcast.EventTypeBroadcastStarted, Broadcast: ebroadcast.BroadcastData{Id: 55}},
+		Seq:   10,
+	})
+	assert.NoError(t, err)
+	err = c.handleEvent(&SequencedEvent{
+		Event: ebroadcast.Event{Type: ebroadcast.EventTypeBroadcastFinished, Broadcast: ebroadcast.BroadcastData{Id: 55}},
+		Seq:   11,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, core.State{}, c.GetState())
+
+	// Resolved state that's older than the events we've applied should be ignored
+	c.adoptState(&State{State: core.State{BroadcastId: 55}, Version: 10})
+	assert.Equal(t, core.State{}, c.GetState())
+	assert.Equal(t, int64(11), c.lastSeq)
+}
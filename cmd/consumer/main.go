@@ -1,25 +1,34 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"os"
+	"time"
 
 	"github.com/codingconcepts/env"
+	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	amqp "github.com/rabbitmq/amqp091-go"
-	"golang.org/x/sync/errgroup"
 
 	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/consumer"
+	"github.com/golden-vcr/broadcasts/internal/health"
+	"github.com/golden-vcr/broadcasts/internal/metrics"
+	"github.com/golden-vcr/broadcasts/internal/outbox"
+	"github.com/golden-vcr/broadcasts/internal/reconcile"
 	"github.com/golden-vcr/broadcasts/internal/state"
-	etwitch "github.com/golden-vcr/schemas/twitch-events"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
 	"github.com/golden-vcr/server-common/db"
 	"github.com/golden-vcr/server-common/entry"
 	"github.com/golden-vcr/server-common/rmq"
 )
 
 type Config struct {
+	BindAddr   string `env:"BIND_ADDR"`
+	ListenPort uint16 `env:"LISTEN_PORT" default:"5017"`
+
 	DatabaseHost     string `env:"PGHOST" required:"true"`
 	DatabasePort     int    `env:"PGPORT" required:"true"`
 	DatabaseName     string `env:"PGDATABASE" required:"true"`
@@ -32,6 +41,14 @@ type Config struct {
 	RmqVhost    string `env:"RMQ_VHOST" required:"true"`
 	RmqUser     string `env:"RMQ_USER" required:"true"`
 	RmqPassword string `env:"RMQ_PASSWORD" required:"true"`
+
+	BroadcastResumeGrace time.Duration `env:"BROADCAST_RESUME_GRACE" default:"10m"`
+
+	StreamStatusURL       string        `env:"STREAM_STATUS_URL"`
+	ReconcileInterval     time.Duration `env:"RECONCILE_INTERVAL" default:"5m"`
+	ReconcileOfflineGrace time.Duration `env:"RECONCILE_OFFLINE_GRACE" default:"15m"`
+
+	TracesExporter string `env:"OTEL_TRACES_EXPORTER" default:"none"`
 }
 
 func main() {
@@ -48,8 +65,16 @@ func main() {
 		app.Fail("Failed to load config", err)
 	}
 
-	// Configure our database connection and initialize a Queries struct, so we can
-	// view and modify current broadcast state
+	// Configure OpenTelemetry so that we can record traces, exporting them via OTLP
+	// in production or to stdout in local development
+	shutdownTracing, err := tracing.Init(ctx, "broadcasts-consumer", config.TracesExporter)
+	if err != nil {
+		app.Fail("Failed to initialize tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Configure our database connection, so we can view and modify current broadcast
+	// state
 	connectionString := db.FormatConnectionString(
 		config.DatabaseHost,
 		config.DatabasePort,
@@ -66,7 +91,6 @@ func main() {
 	if err := db.Ping(); err != nil {
 		app.Fail("Failed to connect to database", err)
 	}
-	q := queries.New(db)
 
 	// Initialize an AMQP client
 	amqpConn, err := amqp.Dial(rmq.FormatConnectionString(config.RmqHost, config.RmqPort, config.RmqVhost, config.RmqUser, config.RmqPassword))
@@ -76,8 +100,8 @@ func main() {
 	defer amqpConn.Close()
 
 	// Prepare a producer that we can use to send messages to the broadcast-events
-	// queue
-	broadcastEventsProducer, err := rmq.NewProducer(amqpConn, "broadcast-events")
+	// queue, propagating trace context in message headers
+	broadcastEventsProducer, err := tracing.NewProducer(amqpConn, "broadcast-events")
 	if err != nil {
 		app.Fail("Failed to initialize AMQP producer for broadcast-events", err)
 	}
@@ -94,52 +118,44 @@ func main() {
 		app.Fail("Failed to init recv channel on twitch-events consumer", err)
 	}
 
+	// Run an outbox relay in the background: any events recorded to the outbox (by this
+	// process or any other) will be sent to the broadcast-events queue
+	relay := outbox.NewRelay(app.Log(), db, metrics.InstrumentProducer(broadcastEventsProducer, "broadcast-events"))
+	go relay.Run(ctx)
+
 	// Prepare a state.Writer interface, allowing us authoritatively modify the current
 	// broadcast state in a way that propagates to the DB and the broadcast-events queue
-	writer := state.NewWriter(q, broadcastEventsProducer)
-
-	// Each time we read a message from the queue, spin up a new goroutine for that
-	// message, parse it according to our twitch-events schema, then handle it
-	wg, ctx := errgroup.WithContext(ctx)
-	done := false
-	for !done {
-		select {
-		case <-ctx.Done():
-			app.Log().Info("Consumer context canceled; exiting main loop")
-			done = true
-		case d, ok := <-twitchEvents:
-			if ok {
-				wg.Go(func() error {
-					var ev etwitch.Event
-					if err := json.Unmarshal(d.Body, &ev); err != nil {
-						return err
-					}
-					switch ev.Type {
-					case etwitch.EventTypeStreamStarted:
-						broadcast, err := writer.StartBroadcast(ctx)
-						if err != nil {
-							app.Log().Error("Failed to start broadcast", "error", err)
-						} else {
-							app.Log().Info("Started broadcast", "broadcast", broadcast)
-						}
-					case etwitch.EventTypeStreamEnded:
-						err := writer.EndCurrentBroadcast(ctx)
-						if err != nil {
-							app.Log().Error("Failed to end broadcast", "error", err)
-						} else {
-							app.Log().Info("Ended broadcast")
-						}
-					}
-					return nil
-				})
-			} else {
-				app.Log().Info("Channel is closed; exiting main loop")
-				done = true
-			}
-		}
+	writer := metrics.InstrumentWriter(state.NewWriter(db, relay, config.BroadcastResumeGrace))
+
+	// If we have a source of truth for whether the stream is live, periodically check
+	// our broadcast state against it, so that a broadcast can't be left open
+	// indefinitely if we miss the event that should have ended it
+	if config.StreamStatusURL != "" {
+		reconciler := reconcile.NewReconciler(app.Log(), queries.New(tracing.WrapDB(db)), writer, reconcile.NewStatusClient(config.StreamStatusURL), config.ReconcileInterval, config.ReconcileOfflineGrace)
+		go reconciler.Run(ctx)
+	} else {
+		app.Log().Warn("STREAM_STATUS_URL is not set; broadcast state will not be reconciled")
+	}
+
+	// Run an HTTP server in the background so that Prometheus can scrape metrics from
+	// this process via GET /metrics, and so that orchestrators can check its health
+	// via GET /healthz and GET /readyz
+	handlerTask := &health.Task{}
+	r := mux.NewRouter()
+	r.Path("/metrics").Methods("GET").Handler(metrics.Handler())
+	{
+		healthServer := health.NewServer()
+		healthServer.AddLivenessCheck("amqp", health.CheckAmqp(amqpConn))
+		healthServer.AddLivenessCheck("twitch-events", handlerTask.Check)
+		healthServer.AddReadinessCheck("postgres", health.CheckDB(db))
+		healthServer.RegisterRoutes(r)
 	}
+	go entry.RunServer(ctx, app.Log(), r, config.BindAddr, config.ListenPort)
 
-	if err := wg.Wait(); err != nil {
+	// Handle each message we read from the queue in order, one at a time, parsing it
+	// according to our twitch-events schema and updating broadcast state accordingly
+	handler := consumer.NewHandler(app.Log(), writer)
+	if err := handlerTask.Run(func() error { return handler.Run(ctx, twitchEvents) }); err != nil {
 		app.Fail("Encountered an error during message handling", err)
 	}
 }
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/codingconcepts/env"
 	"github.com/gorilla/mux"
@@ -11,10 +14,19 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 
 	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts"
 	"github.com/golden-vcr/broadcasts/gen/queries"
 	"github.com/golden-vcr/broadcasts/internal/admin"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/health"
 	"github.com/golden-vcr/broadcasts/internal/history"
+	"github.com/golden-vcr/broadcasts/internal/live"
+	"github.com/golden-vcr/broadcasts/internal/metrics"
+	"github.com/golden-vcr/broadcasts/internal/outbox"
 	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
+	"github.com/golden-vcr/broadcasts/internal/webhook"
+	"github.com/golden-vcr/broadcasts/internal/ws"
 	"github.com/golden-vcr/server-common/db"
 	"github.com/golden-vcr/server-common/entry"
 	"github.com/golden-vcr/server-common/rmq"
@@ -38,6 +50,18 @@ type Config struct {
 	RmqVhost    string `env:"RMQ_VHOST" required:"true"`
 	RmqUser     string `env:"RMQ_USER" required:"true"`
 	RmqPassword string `env:"RMQ_PASSWORD" required:"true"`
+
+	BroadcastResumeGrace time.Duration `env:"BROADCAST_RESUME_GRACE" default:"10m"`
+
+	StateStreamMaxSubscribers int `env:"STATE_STREAM_MAX_SUBSCRIBERS" default:"512"`
+
+	WebhookTimeout time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s"`
+
+	TracesExporter string `env:"OTEL_TRACES_EXPORTER" default:"none"`
+
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	WebSocketAllowedOrigins []string `env:"WEBSOCKET_ALLOWED_ORIGINS" default:"https://goldenvcr.com"`
 }
 
 func main() {
@@ -54,6 +78,21 @@ func main() {
 		app.Fail("Failed to load config", err)
 	}
 
+	// Configure OpenTelemetry so that we can record traces, exporting them via OTLP
+	// in production or to stdout in local development
+	shutdownTracing, err := tracing.Init(ctx, "broadcasts", config.TracesExporter)
+	if err != nil {
+		app.Fail("Failed to initialize tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Identify the reverse proxies whose X-Forwarded-For headers we can believe, so
+	// that changes in state are attributed to the real client IP in the audit log
+	trustedProxies, err := audit.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		app.Fail("Failed to parse trusted proxies", err)
+	}
+
 	// Configure our database connection and initialize a Queries struct, so we can
 	// view and modify current broadcast state
 	connectionString := db.FormatConnectionString(
@@ -72,7 +111,7 @@ func main() {
 	if err := db.Ping(); err != nil {
 		app.Fail("Failed to connect to database", err)
 	}
-	q := queries.New(db)
+	q := queries.New(tracing.WrapDB(db))
 
 	// Initialize an AMQP client
 	amqpConn, err := amqp.Dial(rmq.FormatConnectionString(config.RmqHost, config.RmqPort, config.RmqVhost, config.RmqUser, config.RmqPassword))
@@ -87,31 +126,116 @@ func main() {
 	if err != nil {
 		app.Fail("Failed to initialize auth client", err)
 	}
+	authClient = tracing.InstrumentAuthClient(authClient)
 
 	// Prepare a producer that we can use to send messages to the broadcast-events
-	// queue
-	broadcastEventsProducer, err := rmq.NewProducer(amqpConn, "broadcast-events")
+	// queue, propagating trace context in message headers
+	broadcastEventsProducer, err := tracing.NewProducer(amqpConn, "broadcast-events")
 	if err != nil {
 		app.Fail("Failed to initialize AMQP producer for broadcast-events", err)
 	}
 
-	// Prepare a state.Writer interface, allowing us authoritatively modify the current
-	// broadcast state in a way that propagates to the DB and the broadcast-events queue
-	writer := state.NewWriter(q, broadcastEventsProducer)
+	// Run an outbox relay in the background: any events recorded to the outbox (by this
+	// process or any other) will be sent to the broadcast-events queue
+	relay := outbox.NewRelay(app.Log(), db, metrics.InstrumentProducer(broadcastEventsProducer, "broadcast-events"))
+	go relay.Run(ctx)
+
+	// Run a webhook dispatcher in the background as well: each event is also delivered
+	// to every registered webhook subscriber, with retries
+	dispatcher := webhook.NewDispatcher(app.Log(), db, &http.Client{Timeout: config.WebhookTimeout})
+	go dispatcher.Run(ctx)
 
-	// Start setting up our HTTP handlers, using gorilla/mux for routing
+	// Prepare a state.Writer interface, allowing us authoritatively modify the current
+	// broadcast state in a way that propagates to the DB, the broadcast-events queue,
+	// and any webhook subscribers
+	writer := metrics.InstrumentWriter(state.NewWriter(db, outbox.Notifiers{relay, dispatcher}, config.BroadcastResumeGrace))
+
+	// Consume from broadcast-events so that we'll be notified whenever broadcast state
+	// changes, and run a watcher that will resolve the new state each time, so that it
+	// can be pushed to all clients subscribed to the state stream or connected via
+	// WebSocket
+	broadcastEventsConsumer, err := rmq.NewConsumer(amqpConn, "broadcast-events")
+	if err != nil {
+		app.Fail("Failed to initialize AMQP consumer for broadcast-events", err)
+	}
+	broadcastEvents, err := broadcastEventsConsumer.Recv(ctx)
+	if err != nil {
+		app.Fail("Failed to init recv channel on broadcast-events consumer", err)
+	}
+	streamStates := make(chan broadcasts.State, 32)
+	wsStates := make(chan broadcasts.State, 32)
+	watcher := live.NewWatcher(app.Log(), q)
+	watcherTask := &health.Task{}
+	watcherTask.Go(func() { watcher.Run(ctx, broadcastEvents, streamStates, wsStates) })
+
+	// Start setting up our HTTP handlers, using gorilla/mux for routing, and recording
+	// metrics for every request
 	r := mux.NewRouter()
+	r.Use(metrics.Middleware)
+
+	// Prometheus can scrape metrics from this process via GET /metrics
+	r.Path("/metrics").Methods("GET").Handler(metrics.Handler())
+
+	// Orchestrators can check whether this process is alive via GET /healthz, and
+	// whether it's ready to serve requests via GET /readyz
+	{
+		healthServer := health.NewServer()
+		healthServer.AddLivenessCheck("amqp", health.CheckAmqp(amqpConn))
+		healthServer.AddLivenessCheck("broadcast-events", watcherTask.Check)
+		healthServer.AddReadinessCheck("postgres", health.CheckDB(db))
+		healthServer.RegisterRoutes(r)
+	}
 
-	// We can call the broadcaster-only admin API to directly modify broadcast state
+	// The broadcaster can manage webhook subscribers via the admin API, and inspect or
+	// replay their deliveries; these routes are registered first so that they take
+	// precedence over the other admin routes
 	{
-		adminServer := admin.NewServer(writer)
-		adminServer.RegisterRoutes(authClient, r.PathPrefix("/admin").Subrouter())
+		webhookRouter := r.PathPrefix("/admin/webhooks").Subrouter()
+		webhookRouter.Use(tracing.Middleware)
+		webhookServer := webhook.NewServer(q, dispatcher)
+		webhookServer.RegisterRoutes(authClient, webhookRouter)
+	}
+
+	// The broadcaster can review the audit log, which records every attempt to change
+	// broadcast state along with who made it; like the webhook routes, these take
+	// precedence over the other admin routes
+	{
+		auditRouter := r.PathPrefix("/admin/audit").Subrouter()
+		auditRouter.Use(tracing.Middleware)
+		auditServer := audit.NewServer(q)
+		auditServer.RegisterRoutes(authClient, auditRouter)
+	}
+
+	// We can call the broadcaster-only admin API to directly modify broadcast state;
+	// each request is traced, including the time taken to check the caller's access
+	{
+		adminRouter := r.PathPrefix("/admin").Subrouter()
+		adminRouter.Use(tracing.Middleware)
+		adminServer := admin.NewServer(writer, trustedProxies)
+		adminServer.RegisterRoutes(authClient, adminRouter)
 	}
 
 	// Anyone can call the history API to get data about past broadcasts
 	{
+		historyRouter := r.NewRoute().Subrouter()
+		historyRouter.Use(tracing.Middleware)
 		historyServer := history.NewServer(q)
-		historyServer.RegisterRoutes(r)
+		historyServer.RegisterRoutes(historyRouter)
+	}
+
+	// Anyone can call the state API to get the current state of the broadcast, or to
+	// subscribe to a stream of changes in broadcast state
+	{
+		liveServer := live.NewServer(ctx, app.Log(), q, streamStates, config.StateStreamMaxSubscribers)
+		liveServer.RegisterRoutes(r)
+	}
+
+	// Anyone can connect via WebSocket to receive changes in broadcast state, and the
+	// broadcaster can use the same connection to send commands that modify that state;
+	// browsers may only connect from our own frontend
+	{
+		wsServer := ws.NewServer(ctx, authClient, q, writer, config.WebSocketAllowedOrigins, trustedProxies, wsStates)
+		wsServer.RegisterRoutes(r)
 	}
 
 	// Handle incoming HTTP connections until our top-level context is canceled, at
@@ -0,0 +1,27 @@
+begin;
+
+create table broadcasts.outbox (
+    id         bigserial primary key,
+    payload    json not null,
+    created_at timestamptz not null default now(),
+    sent_at    timestamptz
+);
+
+comment on table broadcasts.outbox is
+    'Transactional outbox for the broadcast-events queue: each event that describes a '
+    'change in broadcast state is recorded here in the same transaction that makes '
+    'that change, then relayed to the queue asynchronously.';
+comment on column broadcasts.outbox.id is
+    'Serial ID for this event; events are relayed in ascending order by ID.';
+comment on column broadcasts.outbox.payload is
+    'JSON-serialized ebroadcast.Event to be sent to broadcast-events.';
+comment on column broadcasts.outbox.created_at is
+    'Time at which the event was recorded.';
+comment on column broadcasts.outbox.sent_at is
+    'Time at which the event was successfully sent to broadcast-events, or NULL if it '
+    'is still pending.';
+
+create index outbox_pending_index on broadcasts.outbox (id)
+    where sent_at is null;
+
+commit;
@@ -0,0 +1,5 @@
+begin;
+
+drop table broadcasts.broadcast_interruption;
+
+commit;
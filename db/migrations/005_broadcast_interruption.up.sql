@@ -0,0 +1,39 @@
+begin;
+
+create table broadcasts.broadcast_interruption (
+    id           serial primary key,
+    broadcast_id integer not null,
+    started_at   timestamptz not null,
+    ended_at     timestamptz
+);
+
+alter table broadcasts.broadcast_interruption
+    add constraint broadcast_interruption_broadcast_id_fk
+    foreign key (broadcast_id) references broadcasts.broadcast (id);
+
+comment on table broadcasts.broadcast_interruption is
+    'Records a period of time during which a broadcast was offline. A row is opened '
+    'whenever a broadcast ends, and closed if that broadcast is later resumed: an '
+    'interruption is only a true outage once it''s been closed.';
+comment on column broadcasts.broadcast_interruption.id is
+    'Serial ID for this interruption.';
+comment on column broadcasts.broadcast_interruption.broadcast_id is
+    'ID of the broadcast that was interrupted.';
+comment on column broadcasts.broadcast_interruption.started_at is
+    'Time at which the broadcast went offline.';
+comment on column broadcasts.broadcast_interruption.ended_at is
+    'Time at which the broadcast was resumed, or NULL if it has not been resumed.';
+
+create index broadcast_interruption_broadcast_id_index
+    on broadcasts.broadcast_interruption (broadcast_id);
+
+create unique index broadcast_interruption_open_index
+    on broadcasts.broadcast_interruption (broadcast_id)
+    where ended_at is null;
+
+insert into broadcasts.broadcast_interruption (broadcast_id, started_at)
+select broadcast.id, broadcast.ended_at
+from broadcasts.broadcast
+where broadcast.ended_at is not null;
+
+commit;
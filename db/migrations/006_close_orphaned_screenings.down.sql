@@ -0,0 +1,8 @@
+begin;
+
+-- Screenings that were closed by the corresponding up migration can't be distinguished
+-- from screenings that were ended explicitly, so they're left as-is
+comment on column broadcasts.screening.ended_at is
+    'Time at which the screening ended, if it''s not stil ongoing.';
+
+commit;
@@ -0,0 +1,13 @@
+begin;
+
+update broadcasts.screening set ended_at = broadcast.ended_at
+from broadcasts.broadcast
+where screening.broadcast_id = broadcast.id
+    and broadcast.ended_at is not null
+    and screening.ended_at is null;
+
+comment on column broadcasts.screening.ended_at is
+    'Time at which the screening ended, if it''s not stil ongoing. When a broadcast '
+    'ends, any screening that''s still in progress is ended at the same time.';
+
+commit;
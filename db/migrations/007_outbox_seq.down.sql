@@ -0,0 +1,5 @@
+begin;
+
+alter table broadcasts.outbox drop column seq;
+
+commit;
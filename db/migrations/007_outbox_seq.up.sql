@@ -0,0 +1,16 @@
+begin;
+
+alter table broadcasts.outbox add column seq bigint;
+
+update broadcasts.outbox set seq = outbox.id;
+
+alter table broadcasts.outbox alter column seq set not null;
+alter table broadcasts.outbox add constraint outbox_seq_unique unique (seq);
+
+comment on column broadcasts.outbox.seq is
+    'Sequence number for this event, which is also embedded in its payload. Sequence '
+    'numbers are assigned while holding the broadcast state lock, so they increase by '
+    'exactly 1 with each event: consumers can use them to detect missed, duplicate, or '
+    'out-of-order events.';
+
+commit;
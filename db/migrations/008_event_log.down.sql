@@ -0,0 +1,5 @@
+begin;
+
+drop table broadcasts.event_log;
+
+commit;
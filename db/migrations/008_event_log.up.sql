@@ -0,0 +1,36 @@
+begin;
+
+create table broadcasts.event_log (
+    seq         bigint primary key,
+    event_type  text not null,
+    payload     json not null,
+    occurred_at timestamptz not null
+);
+
+comment on table broadcasts.event_log is
+    'Permanent, append-only record of every event produced for the broadcast-events '
+    'queue. Replaying the log in order of seq reconstructs broadcast state as it stood '
+    'at any point in time since the log began.';
+comment on column broadcasts.event_log.seq is
+    'Sequence number of the event, as embedded in its payload.';
+comment on column broadcasts.event_log.event_type is
+    'Type of the event, e.g. ''broadcast-started'' or ''screening-finished''.';
+comment on column broadcasts.event_log.payload is
+    'JSON-serialized event, exactly as sent to broadcast-events.';
+comment on column broadcasts.event_log.occurred_at is
+    'Time at which the change described by the event took effect. This is usually '
+    'the time at which the event was recorded, but may be earlier if the event '
+    'corrects state after the fact, e.g. when a stale broadcast is ended.';
+
+create index event_log_occurred_at_index on broadcasts.event_log (occurred_at);
+
+-- Seed the log with every event that's been recorded to the outbox so far
+insert into broadcasts.event_log (seq, event_type, payload, occurred_at)
+select
+    outbox.seq,
+    outbox.payload->>'type',
+    outbox.payload,
+    outbox.created_at
+from broadcasts.outbox;
+
+commit;
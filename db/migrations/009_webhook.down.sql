@@ -0,0 +1,6 @@
+begin;
+
+drop table broadcasts.webhook_delivery;
+drop table broadcasts.webhook_subscriber;
+
+commit;
@@ -0,0 +1,92 @@
+begin;
+
+create table broadcasts.webhook_subscriber (
+    id         serial primary key,
+    url        text not null,
+    secret     text not null,
+    created_at timestamptz not null default now(),
+    deleted_at timestamptz
+);
+
+comment on table broadcasts.webhook_subscriber is
+    'Registers an HTTP endpoint that should receive a webhook for every event produced '
+    'for the broadcast-events queue, for the benefit of services that can''t consume '
+    'from the queue directly.';
+comment on column broadcasts.webhook_subscriber.id is
+    'Serial ID for this subscriber.';
+comment on column broadcasts.webhook_subscriber.url is
+    'Absolute URL to which each event will be POSTed.';
+comment on column broadcasts.webhook_subscriber.secret is
+    'Shared secret used to sign each request with HMAC-SHA256, so that the subscriber '
+    'can verify that the request originated from the broadcasts service.';
+comment on column broadcasts.webhook_subscriber.created_at is
+    'Time at which the subscriber was registered.';
+comment on column broadcasts.webhook_subscriber.deleted_at is
+    'Time at which the subscriber was removed, if applicable. Removed subscribers are '
+    'retained so that their delivery history remains available, but they receive no '
+    'further deliveries.';
+
+create table broadcasts.webhook_delivery (
+    id              bigserial primary key,
+    subscriber_id   integer not null,
+    seq             bigint not null,
+    created_at      timestamptz not null default now(),
+    num_attempts    integer not null default 0,
+    next_attempt_at timestamptz,
+    last_attempt_at timestamptz,
+    last_status     integer,
+    last_error      text,
+    delivered_at    timestamptz,
+    failed_at       timestamptz
+);
+
+alter table broadcasts.webhook_delivery
+    add constraint webhook_delivery_subscriber_id_fk
+    foreign key (subscriber_id) references broadcasts.webhook_subscriber (id);
+
+alter table broadcasts.webhook_delivery
+    add constraint webhook_delivery_seq_fk
+    foreign key (seq) references broadcasts.event_log (seq);
+
+alter table broadcasts.webhook_delivery
+    add constraint webhook_delivery_subscriber_id_seq_unique
+    unique (subscriber_id, seq);
+
+comment on table broadcasts.webhook_delivery is
+    'Records the delivery of a single event to a single webhook subscriber. A row is '
+    'created for each active subscriber in the same transaction that records the '
+    'event, then updated with the result of each attempt to deliver it.';
+comment on column broadcasts.webhook_delivery.id is
+    'Serial ID for this delivery.';
+comment on column broadcasts.webhook_delivery.subscriber_id is
+    'ID of the subscriber to which the event is being delivered.';
+comment on column broadcasts.webhook_delivery.seq is
+    'Sequence number of the event being delivered, identifying it in the event log.';
+comment on column broadcasts.webhook_delivery.created_at is
+    'Time at which the delivery was scheduled.';
+comment on column broadcasts.webhook_delivery.num_attempts is
+    'Number of attempts made to deliver the event so far.';
+comment on column broadcasts.webhook_delivery.next_attempt_at is
+    'Time at which the next attempt should be made, or NULL if the event has been '
+    'delivered or we''ve given up on delivering it.';
+comment on column broadcasts.webhook_delivery.last_attempt_at is
+    'Time at which the most recent attempt was made, if any.';
+comment on column broadcasts.webhook_delivery.last_status is
+    'HTTP status code returned in response to the most recent attempt, or NULL if no '
+    'response was received.';
+comment on column broadcasts.webhook_delivery.last_error is
+    'Description of the reason the most recent attempt failed, if it failed.';
+comment on column broadcasts.webhook_delivery.delivered_at is
+    'Time at which the event was successfully delivered, if it has been.';
+comment on column broadcasts.webhook_delivery.failed_at is
+    'Time at which we gave up on delivering the event after exhausting all attempts, '
+    'if we have. A failed delivery may be replayed, in which case it''s scheduled for '
+    'immediate delivery and this value is cleared.';
+
+create index webhook_delivery_due_index on broadcasts.webhook_delivery (next_attempt_at)
+    where next_attempt_at is not null;
+
+create index webhook_delivery_subscriber_id_index
+    on broadcasts.webhook_delivery (subscriber_id, id);
+
+commit;
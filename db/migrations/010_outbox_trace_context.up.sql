@@ -0,0 +1,10 @@
+begin;
+
+alter table broadcasts.outbox add column trace_context jsonb not null default '{}';
+
+comment on column broadcasts.outbox.trace_context is
+    'W3C trace context (e.g. traceparent and tracestate) of the operation that '
+    'recorded this event, as a JSON object. The relay injects it into the headers of '
+    'the AMQP message so that consumers can continue the same trace.';
+
+commit;
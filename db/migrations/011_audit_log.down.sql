@@ -0,0 +1,5 @@
+begin;
+
+drop table broadcasts.audit_log;
+
+commit;
@@ -0,0 +1,56 @@
+begin;
+
+create table broadcasts.audit_log (
+    id            bigserial primary key,
+    created_at    timestamptz not null default now(),
+    actor         text not null,
+    actor_user_id text,
+    source_ip     text,
+    request_id    text,
+    action        text not null,
+    params        jsonb not null default '{}',
+    state_before  jsonb not null,
+    state_after   jsonb not null,
+    outcome       text not null,
+    error         text
+);
+
+comment on table broadcasts.audit_log is
+    'Permanent record of every attempt to change broadcast state, whether made by '
+    'hand via the admin API or automatically in response to twitch-events, along with '
+    'who made the attempt and what came of it.';
+comment on column broadcasts.audit_log.id is
+    'Serial ID for this entry; entries are listed in descending order by ID.';
+comment on column broadcasts.audit_log.created_at is
+    'Time at which the attempt was made.';
+comment on column broadcasts.audit_log.actor is
+    'Name of the user or process that made the attempt: the login of the Twitch user '
+    'for manual changes, or e.g. ''twitch-events consumer'' for automated ones.';
+comment on column broadcasts.audit_log.actor_user_id is
+    'Twitch user ID of the user that made the attempt, or NULL if it was automated.';
+comment on column broadcasts.audit_log.source_ip is
+    'IP address from which the HTTP request or WebSocket connection originated, or '
+    'NULL if the attempt was not made via HTTP.';
+comment on column broadcasts.audit_log.request_id is
+    'X-Request-Id of the HTTP request that made the attempt, if any.';
+comment on column broadcasts.audit_log.action is
+    'Name of the operation attempted, e.g. ''start-broadcast'' or ''start-screening''.';
+comment on column broadcasts.audit_log.params is
+    'JSON object describing the arguments to the operation, e.g. the ID of the tape to '
+    'be screened.';
+comment on column broadcasts.audit_log.state_before is
+    'Broadcast state immediately before the attempt, as a JSON-serialized State.';
+comment on column broadcasts.audit_log.state_after is
+    'Broadcast state immediately after the attempt, as a JSON-serialized State. If the '
+    'attempt did not succeed, this is the state as it stood once it had failed.';
+comment on column broadcasts.audit_log.outcome is
+    'Result of the attempt: ''ok'' if state was changed; ''rejected'' if the operation '
+    'was not valid in the current state; or ''error'' if it failed for any other '
+    'reason.';
+comment on column broadcasts.audit_log.error is
+    'Error message describing why the attempt did not succeed, if applicable.';
+
+create index audit_log_created_at_index on broadcasts.audit_log (created_at);
+create index audit_log_actor_index on broadcasts.audit_log (actor, id);
+
+commit;
@@ -0,0 +1,50 @@
+-- name: RecordAuditLogEntry :exec
+insert into broadcasts.audit_log (
+    created_at,
+    actor,
+    actor_user_id,
+    source_ip,
+    request_id,
+    action,
+    params,
+    state_before,
+    state_after,
+    outcome,
+    error
+) values (
+    now(),
+    sqlc.arg('actor'),
+    sqlc.narg('actor_user_id'),
+    sqlc.narg('source_ip'),
+    sqlc.narg('request_id'),
+    sqlc.arg('action'),
+    sqlc.arg('params'),
+    sqlc.arg('state_before'),
+    sqlc.arg('state_after'),
+    sqlc.arg('outcome'),
+    sqlc.narg('error')
+);
+
+-- name: GetAuditLogEntries :many
+select
+    audit_log.id,
+    audit_log.created_at,
+    audit_log.actor,
+    audit_log.actor_user_id,
+    audit_log.source_ip,
+    audit_log.request_id,
+    audit_log.action,
+    audit_log.params,
+    audit_log.state_before,
+    audit_log.state_after,
+    audit_log.outcome,
+    audit_log.error
+from broadcasts.audit_log
+where audit_log.id < coalesce(sqlc.narg('before_entry_id'), 9223372036854775807)
+    and audit_log.actor = coalesce(sqlc.narg('actor'), audit_log.actor)
+    and audit_log.action = coalesce(sqlc.narg('action'), audit_log.action)
+    and audit_log.outcome = coalesce(sqlc.narg('outcome'), audit_log.outcome)
+    and audit_log.created_at >= coalesce(sqlc.narg('since'), '-infinity'::timestamptz)
+    and audit_log.created_at < coalesce(sqlc.narg('until'), 'infinity'::timestamptz)
+order by audit_log.id desc
+limit sqlc.arg('limit');
@@ -8,10 +8,22 @@ select
             'id', screening.id,
             'tape_id', screening.tape_id,
             'started_at', screening.started_at,
-            'ended_at', coalesce(screening.ended_at, broadcast.ended_at)
+            'ended_at', screening.ended_at
         ) order by screening.started_at) filter (where screening.id is not null),
         '[]'::json
-     )::json as screenings
+     )::json as screenings,
+    coalesce(
+        (
+            select json_agg(json_build_object(
+                'started_at', broadcast_interruption.started_at,
+                'ended_at', broadcast_interruption.ended_at
+            ) order by broadcast_interruption.started_at)
+            from broadcasts.broadcast_interruption
+            where broadcast_interruption.broadcast_id = broadcast.id
+                and broadcast_interruption.ended_at is not null
+        ),
+        '[]'::json
+    )::json as interruptions
 from broadcasts.broadcast
 left join broadcasts.screening
     on screening.broadcast_id = broadcast.id
@@ -34,3 +46,11 @@ where broadcast.id = sqlc.arg('broadcast_id')
 update broadcasts.broadcast set ended_at = now()
 where broadcast.id = sqlc.arg('broadcast_id')
     and broadcast.ended_at is null;
+
+-- name: EndBroadcastAt :execresult
+update broadcasts.broadcast set ended_at = sqlc.arg('ended_at')
+where broadcast.id = sqlc.arg('broadcast_id')
+    and broadcast.ended_at is null;
+
+-- name: LockBroadcastState :exec
+select pg_advisory_xact_lock(hashtext('broadcasts.state'));
@@ -0,0 +1,25 @@
+-- name: RecordEventLogEntry :exec
+insert into broadcasts.event_log (seq, event_type, payload, occurred_at)
+values (
+    sqlc.arg('seq'),
+    sqlc.arg('event_type'),
+    sqlc.arg('payload'),
+    coalesce(sqlc.narg('occurred_at')::timestamptz, now())
+);
+
+-- name: GetEventLogSince :many
+select
+    event_log.seq,
+    event_log.payload
+from broadcasts.event_log
+where event_log.occurred_at <= sqlc.arg('at')
+    and event_log.seq >= coalesce(
+        (
+            select max(boundary.seq)
+            from broadcasts.event_log as boundary
+            where boundary.occurred_at <= sqlc.arg('at')
+                and boundary.event_type in ('broadcast-started', 'broadcast-finished')
+        ),
+        0
+    )
+order by event_log.seq;
@@ -0,0 +1,11 @@
+-- name: StartBroadcastInterruption :exec
+insert into broadcasts.broadcast_interruption (broadcast_id, started_at)
+select broadcast.id, broadcast.ended_at
+from broadcasts.broadcast
+where broadcast.id = sqlc.arg('broadcast_id')
+    and broadcast.ended_at is not null;
+
+-- name: EndBroadcastInterruption :execresult
+update broadcasts.broadcast_interruption set ended_at = now()
+where broadcast_interruption.broadcast_id = sqlc.arg('broadcast_id')
+    and broadcast_interruption.ended_at is null;
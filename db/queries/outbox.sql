@@ -0,0 +1,26 @@
+-- name: GetNextOutboxSeq :one
+select coalesce(max(outbox.seq), 0)::bigint + 1 as seq
+from broadcasts.outbox;
+
+-- name: RecordOutboxEvent :exec
+insert into broadcasts.outbox (seq, payload, trace_context, created_at)
+values (sqlc.arg('seq'), sqlc.arg('payload'), sqlc.arg('trace_context'), now());
+
+-- name: GetPendingOutboxEvents :many
+select
+    outbox.id,
+    outbox.payload,
+    outbox.trace_context
+from broadcasts.outbox
+where outbox.sent_at is null
+order by outbox.id
+limit sqlc.arg('limit')
+for update;
+
+-- name: TryLockOutboxRelay :one
+select pg_try_advisory_xact_lock(hashtext('broadcasts.outbox'))::boolean as acquired;
+
+-- name: MarkOutboxEventSent :execresult
+update broadcasts.outbox set sent_at = now()
+where outbox.id = sqlc.arg('outbox_event_id')
+    and outbox.sent_at is null;
@@ -27,3 +27,11 @@ returning screening.id, screening.started_at;
 update broadcasts.screening set ended_at = now()
 where screening.id = sqlc.arg('screening_id')
     and screening.ended_at is null;
+
+-- name: EndBroadcastScreenings :execresult
+update broadcasts.screening set ended_at = broadcast.ended_at
+from broadcasts.broadcast
+where screening.broadcast_id = broadcast.id
+    and broadcast.id = sqlc.arg('broadcast_id')
+    and broadcast.ended_at is not null
+    and screening.ended_at is null;
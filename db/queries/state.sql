@@ -0,0 +1,17 @@
+-- name: GetCurrentState :one
+select
+    coalesce((select max(outbox.seq) from broadcasts.outbox), 0)::bigint as version,
+    broadcast.id as broadcast_id,
+    broadcast.started_at as broadcast_started_at,
+    screening.id as screening_id,
+    screening.tape_id,
+    screening.started_at as screening_started_at
+from (select 1) as singleton
+left join broadcasts.broadcast
+    on broadcast.id = (select max(latest.id) from broadcasts.broadcast as latest)
+    and broadcast.ended_at is null
+left join broadcasts.screening
+    on screening.broadcast_id = broadcast.id
+    and screening.ended_at is null
+order by screening.started_at desc
+limit 1;
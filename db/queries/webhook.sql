@@ -0,0 +1,96 @@
+-- name: CreateWebhookSubscriber :one
+insert into broadcasts.webhook_subscriber (url, secret, created_at)
+values (sqlc.arg('url'), sqlc.arg('secret'), now())
+returning id, created_at;
+
+-- name: GetWebhookSubscribers :many
+select
+    webhook_subscriber.id,
+    webhook_subscriber.url,
+    webhook_subscriber.created_at
+from broadcasts.webhook_subscriber
+where webhook_subscriber.deleted_at is null
+order by webhook_subscriber.id;
+
+-- name: DeleteWebhookSubscriber :execresult
+update broadcasts.webhook_subscriber set deleted_at = now()
+where webhook_subscriber.id = sqlc.arg('subscriber_id')
+    and webhook_subscriber.deleted_at is null;
+
+-- name: ScheduleWebhookDeliveries :exec
+insert into broadcasts.webhook_delivery (subscriber_id, seq, next_attempt_at)
+select
+    webhook_subscriber.id,
+    sqlc.arg('seq')::bigint,
+    now()
+from broadcasts.webhook_subscriber
+where webhook_subscriber.deleted_at is null;
+
+-- name: GetDueWebhookDeliveries :many
+select
+    webhook_delivery.id,
+    webhook_delivery.num_attempts,
+    webhook_subscriber.url,
+    webhook_subscriber.secret,
+    event_log.payload
+from broadcasts.webhook_delivery
+join broadcasts.webhook_subscriber
+    on webhook_subscriber.id = webhook_delivery.subscriber_id
+join broadcasts.event_log
+    on event_log.seq = webhook_delivery.seq
+where webhook_delivery.next_attempt_at <= now()
+    and webhook_subscriber.deleted_at is null
+order by webhook_delivery.next_attempt_at, webhook_delivery.id
+limit sqlc.arg('limit')
+for update of webhook_delivery skip locked;
+
+-- name: MarkWebhookDeliverySucceeded :execresult
+update broadcasts.webhook_delivery set
+    num_attempts = webhook_delivery.num_attempts + 1,
+    next_attempt_at = null,
+    last_attempt_at = now(),
+    last_status = sqlc.arg('status')::integer,
+    last_error = null,
+    delivered_at = now()
+where webhook_delivery.id = sqlc.arg('delivery_id')
+    and webhook_delivery.next_attempt_at is not null;
+
+-- name: MarkWebhookDeliveryFailed :execresult
+update broadcasts.webhook_delivery set
+    num_attempts = webhook_delivery.num_attempts + 1,
+    next_attempt_at = sqlc.narg('next_attempt_at'),
+    last_attempt_at = now(),
+    last_status = sqlc.narg('status'),
+    last_error = sqlc.arg('error')::text,
+    failed_at = case when sqlc.narg('next_attempt_at')::timestamptz is null then now() end
+where webhook_delivery.id = sqlc.arg('delivery_id')
+    and webhook_delivery.next_attempt_at is not null;
+
+-- name: GetWebhookDeliveries :many
+select
+    webhook_delivery.id,
+    webhook_delivery.seq,
+    event_log.event_type,
+    webhook_delivery.created_at,
+    webhook_delivery.num_attempts,
+    webhook_delivery.next_attempt_at,
+    webhook_delivery.last_attempt_at,
+    webhook_delivery.last_status,
+    webhook_delivery.last_error,
+    webhook_delivery.delivered_at,
+    webhook_delivery.failed_at
+from broadcasts.webhook_delivery
+join broadcasts.event_log
+    on event_log.seq = webhook_delivery.seq
+where webhook_delivery.subscriber_id = sqlc.arg('subscriber_id')
+    and webhook_delivery.id < coalesce(sqlc.narg('before_delivery_id'), 9223372036854775807)
+order by webhook_delivery.id desc
+limit sqlc.arg('limit');
+
+-- name: ReplayFailedWebhookDeliveries :execresult
+update broadcasts.webhook_delivery set
+    num_attempts = 0,
+    next_attempt_at = now(),
+    failed_at = null
+where webhook_delivery.subscriber_id = sqlc.arg('subscriber_id')
+    and webhook_delivery.failed_at is not null;
@@ -0,0 +1,34 @@
+package broadcasts
+
+import "errors"
+
+// ErrBroadcastInProgress indicates that a broadcast could not be started (or resumed)
+// because a broadcast is already in progress
+var ErrBroadcastInProgress = errors.New("a broadcast is already in progress")
+
+// ErrNoBroadcastInProgress indicates that broadcast or screening state could not be
+// modified because no broadcast is in progress
+var ErrNoBroadcastInProgress = errors.New("no broadcast is currently in progress")
+
+// ErrScreeningInProgress indicates that a screening could not be started because the
+// requested tape is already being screened
+var ErrScreeningInProgress = errors.New("the desired tape is already being screened")
+
+// ErrNoScreeningInProgress indicates that a screening could not be ended because no
+// tape is being screened
+var ErrNoScreeningInProgress = errors.New("no tape is currently being screened")
+
+// ErrNoSuchBroadcast indicates that the requested broadcast does not exist
+var ErrNoSuchBroadcast = errors.New("no such broadcast")
+
+// ErrBroadcastNotResumable indicates that a broadcast could not be resumed because it
+// is not the most recent broadcast
+var ErrBroadcastNotResumable = errors.New("only the most recent broadcast may be resumed")
+
+// ErrStateUnknown indicates that broadcast state could not be resolved for a point in
+// time that precedes the event log, since no record of earlier changes exists
+var ErrStateUnknown = errors.New("broadcast state is unknown before the event log began")
+
+// ErrInvalidTransition is returned when a Transition is malformed, e.g. if it has an
+// unknown type or is missing an ID that's required in order to apply it
+var ErrInvalidTransition = errors.New("invalid transition")
@@ -0,0 +1,128 @@
+package queries_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/querytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RecordAuditLogEntry(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	querytest.AssertCount(t, tx, 0, "SELECT COUNT(*) FROM broadcasts.audit_log")
+
+	err := q.RecordAuditLogEntry(context.Background(), queries.RecordAuditLogEntryParams{
+		Actor:       "broadcaster",
+		ActorUserID: sql.NullString{Valid: true, String: "5678"},
+		SourceIp:    sql.NullString{Valid: true, String: "203.0.113.7"},
+		RequestID:   sql.NullString{Valid: true, String: "my-request"},
+		Action:      "start-screening",
+		Params:      json.RawMessage(`{"tapeId":42}`),
+		StateBefore: json.RawMessage(`{"version":1}`),
+		StateAfter:  json.RawMessage(`{"version":2}`),
+		Outcome:     "ok",
+	})
+	assert.NoError(t, err)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.audit_log
+			WHERE actor = 'broadcaster'
+			AND actor_user_id = '5678'
+			AND source_ip = '203.0.113.7'
+			AND request_id = 'my-request'
+			AND action = 'start-screening'
+			AND params = '{"tapeId":42}'::jsonb
+			AND state_before = '{"version":1}'::jsonb
+			AND state_after = '{"version":2}'::jsonb
+			AND outcome = 'ok'
+			AND error IS NULL
+	`)
+
+	// Changes made by the consumer aren't associated with a user or a request
+	err = q.RecordAuditLogEntry(context.Background(), queries.RecordAuditLogEntryParams{
+		Actor:       "twitch-events consumer",
+		Action:      "start-broadcast",
+		Params:      json.RawMessage(`{}`),
+		StateBefore: json.RawMessage(`{"version":2}`),
+		StateAfter:  json.RawMessage(`{"version":2}`),
+		Outcome:     "rejected",
+		Error:       sql.NullString{Valid: true, String: "a broadcast is already in progress"},
+	})
+	assert.NoError(t, err)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.audit_log
+			WHERE actor = 'twitch-events consumer'
+			AND actor_user_id IS NULL
+			AND source_ip IS NULL
+			AND request_id IS NULL
+			AND outcome = 'rejected'
+			AND error = 'a broadcast is already in progress'
+	`)
+}
+
+func Test_GetAuditLogEntries(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// We should have no entries initially
+	rows, err := q.GetAuditLogEntries(context.Background(), queries.GetAuditLogEntriesParams{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 0)
+
+	_, err = tx.Exec(`
+		INSERT INTO broadcasts.audit_log (id, created_at, actor, action, state_before, state_after, outcome) VALUES
+			(1, '1997-09-01 12:00:00+00', 'twitch-events consumer', 'start-broadcast', '{}', '{}', 'ok'),
+			(2, '1997-09-01 13:00:00+00', 'broadcaster', 'start-screening', '{}', '{}', 'ok'),
+			(3, '1997-09-01 14:00:00+00', 'broadcaster', 'start-screening', '{}', '{}', 'rejected'),
+			(4, '1997-09-01 15:00:00+00', 'twitch-events consumer', 'end-broadcast', '{}', '{}', 'ok');
+	`)
+	assert.NoError(t, err)
+
+	getIds := func(arg queries.GetAuditLogEntriesParams) []int64 {
+		rows, err := q.GetAuditLogEntries(context.Background(), arg)
+		assert.NoError(t, err)
+		ids := make([]int64, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return ids
+	}
+
+	// Entries should be returned most recent first, and paginated by ID
+	assert.Equal(t, []int64{4, 3, 2, 1}, getIds(queries.GetAuditLogEntriesParams{Limit: 10}))
+	assert.Equal(t, []int64{4, 3}, getIds(queries.GetAuditLogEntriesParams{Limit: 2}))
+	assert.Equal(t, []int64{2, 1}, getIds(queries.GetAuditLogEntriesParams{
+		BeforeEntryID: sql.NullInt64{Valid: true, Int64: 3},
+		Limit:         10,
+	}))
+
+	// Entries should be filterable by actor, action, and outcome
+	assert.Equal(t, []int64{3, 2}, getIds(queries.GetAuditLogEntriesParams{
+		Actor: sql.NullString{Valid: true, String: "broadcaster"},
+		Limit: 10,
+	}))
+	assert.Equal(t, []int64{4}, getIds(queries.GetAuditLogEntriesParams{
+		Action: sql.NullString{Valid: true, String: "end-broadcast"},
+		Limit:  10,
+	}))
+	assert.Equal(t, []int64{3}, getIds(queries.GetAuditLogEntriesParams{
+		Outcome: sql.NullString{Valid: true, String: "rejected"},
+		Limit:   10,
+	}))
+
+	// Entries should be filterable by time range, inclusive of since and exclusive of
+	// until
+	assert.Equal(t, []int64{3, 2}, getIds(queries.GetAuditLogEntriesParams{
+		Since: sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC)},
+		Until: sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 15, 0, 0, 0, time.UTC)},
+		Limit: 10,
+	}))
+}
@@ -22,6 +22,21 @@ func (q *Queries) EndBroadcast(ctx context.Context, broadcastID int32) (sql.Resu
 	return q.db.ExecContext(ctx, endBroadcast, broadcastID)
 }
 
+const endBroadcastAt = `-- name: EndBroadcastAt :execresult
+update broadcasts.broadcast set ended_at = $1
+where broadcast.id = $2
+    and broadcast.ended_at is null
+`
+
+type EndBroadcastAtParams struct {
+	EndedAt     sql.NullTime
+	BroadcastID int32
+}
+
+func (q *Queries) EndBroadcastAt(ctx context.Context, arg EndBroadcastAtParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, endBroadcastAt, arg.EndedAt, arg.BroadcastID)
+}
+
 const getBroadcastData = `-- name: GetBroadcastData :many
 select
     broadcast.id,
@@ -32,10 +47,22 @@ select
             'id', screening.id,
             'tape_id', screening.tape_id,
             'started_at', screening.started_at,
-            'ended_at', coalesce(screening.ended_at, broadcast.ended_at)
+            'ended_at', screening.ended_at
         ) order by screening.started_at) filter (where screening.id is not null),
         '[]'::json
-     )::json as screenings
+     )::json as screenings,
+    coalesce(
+        (
+            select json_agg(json_build_object(
+                'started_at', broadcast_interruption.started_at,
+                'ended_at', broadcast_interruption.ended_at
+            ) order by broadcast_interruption.started_at)
+            from broadcasts.broadcast_interruption
+            where broadcast_interruption.broadcast_id = broadcast.id
+                and broadcast_interruption.ended_at is not null
+        ),
+        '[]'::json
+    )::json as interruptions
 from broadcasts.broadcast
 left join broadcasts.screening
     on screening.broadcast_id = broadcast.id
@@ -51,10 +78,11 @@ type GetBroadcastDataParams struct {
 }
 
 type GetBroadcastDataRow struct {
-	ID         int32
-	StartedAt  time.Time
-	EndedAt    sql.NullTime
-	Screenings json.RawMessage
+	ID            int32
+	StartedAt     time.Time
+	EndedAt       sql.NullTime
+	Screenings    json.RawMessage
+	Interruptions json.RawMessage
 }
 
 func (q *Queries) GetBroadcastData(ctx context.Context, arg GetBroadcastDataParams) ([]GetBroadcastDataRow, error) {
@@ -71,6 +99,7 @@ func (q *Queries) GetBroadcastData(ctx context.Context, arg GetBroadcastDataPara
 			&i.StartedAt,
 			&i.EndedAt,
 			&i.Screenings,
+			&i.Interruptions,
 		); err != nil {
 			return nil, err
 		}
@@ -85,6 +114,15 @@ func (q *Queries) GetBroadcastData(ctx context.Context, arg GetBroadcastDataPara
 	return items, nil
 }
 
+const lockBroadcastState = `-- name: LockBroadcastState :exec
+select pg_advisory_xact_lock(hashtext('broadcasts.state'))
+`
+
+func (q *Queries) LockBroadcastState(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, lockBroadcastState)
+	return err
+}
+
 const resumeBroadcast = `-- name: ResumeBroadcast :execresult
 update broadcasts.broadcast set ended_at = null
 where broadcast.id = $1
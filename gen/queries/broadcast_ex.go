@@ -25,6 +25,18 @@ func (s *screeningData) toScreening() broadcasts.Screening {
 	}
 }
 
+type interruptionData struct {
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+func (i *interruptionData) toInterruption() broadcasts.Interruption {
+	return broadcasts.Interruption{
+		StartedAt: i.StartedAt,
+		EndedAt:   i.EndedAt,
+	}
+}
+
 func (q *Queries) GetBroadcastDataEx(ctx context.Context, arg GetBroadcastDataParams) ([]broadcasts.Broadcast, error) {
 	baseRows, err := q.GetBroadcastData(ctx, arg)
 	if err != nil {
@@ -41,16 +53,26 @@ func (q *Queries) GetBroadcastDataEx(ctx context.Context, arg GetBroadcastDataPa
 			screenings = append(screenings, rawScreening.toScreening())
 		}
 
+		var rawInterruptions []interruptionData
+		if err := json.Unmarshal(baseRow.Interruptions, &rawInterruptions); err != nil {
+			return nil, err
+		}
+		interruptions := make([]broadcasts.Interruption, 0, len(rawInterruptions))
+		for _, rawInterruption := range rawInterruptions {
+			interruptions = append(interruptions, rawInterruption.toInterruption())
+		}
+
 		var broadcastEndedAt *time.Time
 		if baseRow.EndedAt.Valid {
 			broadcastEndedAt = &baseRow.EndedAt.Time
 		}
 
 		rows = append(rows, broadcasts.Broadcast{
-			Id:         int(baseRow.ID),
-			StartedAt:  baseRow.StartedAt,
-			EndedAt:    broadcastEndedAt,
-			Screenings: screenings,
+			Id:            int(baseRow.ID),
+			StartedAt:     baseRow.StartedAt,
+			EndedAt:       broadcastEndedAt,
+			Screenings:    screenings,
+			Interruptions: interruptions,
 		})
 	}
 	return rows, nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/golden-vcr/broadcasts/gen/queries"
 	"github.com/golden-vcr/server-common/querytest"
@@ -136,3 +137,47 @@ func Test_EndBroadcast(t *testing.T) {
 			AND ended_at IS NOT NULL
 	`, row.ID)
 }
+
+func Test_LockBroadcastState(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Acquiring the lock should succeed, and the lock should be reentrant within the
+	// same transaction
+	err := q.LockBroadcastState(context.Background())
+	assert.NoError(t, err)
+	err = q.LockBroadcastState(context.Background())
+	assert.NoError(t, err)
+}
+
+func Test_EndBroadcastAt(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.broadcast (id, started_at, ended_at) VALUES
+			(1, '1997-09-01 12:00:00+00', NULL);
+	`)
+	assert.NoError(t, err)
+
+	result, err := q.EndBroadcastAt(context.Background(), queries.EndBroadcastAtParams{
+		EndedAt:     sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 14, 0, 0, 0, time.UTC)},
+		BroadcastID: 1,
+	})
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.broadcast
+			WHERE id = 1
+			AND ended_at = '1997-09-01 14:00:00+00'
+	`)
+
+	// A broadcast that's already ended should not be affected
+	result, err = q.EndBroadcastAt(context.Background(), queries.EndBroadcastAtParams{
+		EndedAt:     sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 15, 0, 0, 0, time.UTC)},
+		BroadcastID: 1,
+	})
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 0)
+}
@@ -0,0 +1,74 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/google/uuid"
+)
+
+// GetStateAtEx resolves broadcast state as it stood at the given time, by replaying the
+// event log up to that time. Only the events since the most recent broadcast started or
+// ended need to be replayed, since either of those events leaves us with no screening
+// in progress.
+//
+// Broadcasts and screenings that predate the event log have no events, so state can't
+// be resolved for times before the first logged event: in that case, the returned
+// error is broadcasts.ErrStateUnknown.
+func (q *Queries) GetStateAtEx(ctx context.Context, at time.Time) (broadcasts.State, error) {
+	rows, err := q.GetEventLogSince(ctx, at)
+	if err != nil {
+		return broadcasts.State{}, err
+	}
+	if len(rows) == 0 {
+		return broadcasts.State{}, broadcasts.ErrStateUnknown
+	}
+
+	var state broadcasts.State
+	for _, row := range rows {
+		var ev ebroadcast.Event
+		if err := json.Unmarshal(row.Payload, &ev); err != nil {
+			return broadcasts.State{}, fmt.Errorf("failed to unmarshal event %d from event log: %w", row.Seq, err)
+		}
+		state = replayEvent(state, &ev, row.Seq)
+	}
+	return state, nil
+}
+
+// replayEvent returns the state that results from applying the given event to the given
+// state. The log is a record of what actually happened, so an event that's not a valid
+// transition from the current state is still applied as best we can; only a malformed
+// event is skipped.
+func replayEvent(prev broadcasts.State, ev *ebroadcast.Event, seq int64) broadcasts.State {
+	t, err := broadcasts.TransitionFromEvent(ev)
+	if err != nil {
+		prev.Version = seq
+		return prev
+	}
+	next, err := t.Apply(prev.State)
+	if err != nil {
+		next = ev.ToState(prev.State)
+	}
+
+	state := broadcasts.State{
+		State:   next,
+		Version: seq,
+	}
+	if next.BroadcastId != 0 {
+		broadcastStartedAt := ev.Broadcast.StartedAt
+		state.BroadcastStartedAt = &broadcastStartedAt
+	}
+	if next.ScreeningId != uuid.Nil {
+		if ev.Screening != nil && ev.Screening.Id == next.ScreeningId {
+			screeningStartedAt := ev.Screening.StartedAt
+			state.ScreeningStartedAt = &screeningStartedAt
+		} else {
+			state.ScreeningStartedAt = prev.ScreeningStartedAt
+		}
+	}
+	return state
+}
@@ -0,0 +1,143 @@
+package queries_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/querytest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RecordEventLogEntry(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	querytest.AssertCount(t, tx, 0, "SELECT COUNT(*) FROM broadcasts.event_log")
+
+	// An entry with no explicit occurred_at time should be logged as occurring now
+	err := q.RecordEventLogEntry(context.Background(), queries.RecordEventLogEntryParams{
+		Seq:       1,
+		EventType: "broadcast-started",
+		Payload:   json.RawMessage(`{"type":"broadcast-started","seq":1}`),
+	})
+	assert.NoError(t, err)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.event_log
+			WHERE seq = 1
+			AND event_type = 'broadcast-started'
+			AND payload::jsonb = '{"type":"broadcast-started","seq":1}'::jsonb
+			AND occurred_at = now()
+	`)
+
+	// An entry may also be logged as having occurred at a specific time
+	err = q.RecordEventLogEntry(context.Background(), queries.RecordEventLogEntryParams{
+		Seq:        2,
+		EventType:  "broadcast-finished",
+		Payload:    json.RawMessage(`{"type":"broadcast-finished","seq":2}`),
+		OccurredAt: sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC)},
+	})
+	assert.NoError(t, err)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.event_log
+			WHERE seq = 2
+			AND occurred_at = '1997-09-01T13:00:00Z'::timestamptz
+	`)
+}
+
+func Test_GetEventLogSince(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// With no events, there's nothing to replay
+	rows, err := q.GetEventLogSince(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, rows, 0)
+
+	// Simulate two broadcasts, the second of which is still in progress
+	_, err = tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{}', '1997-09-01T12:00:00Z'),
+			(2, 'screening-started', '{}', '1997-09-01T12:10:00Z'),
+			(3, 'broadcast-finished', '{}', '1997-09-01T13:00:00Z'),
+			(4, 'broadcast-started', '{}', '1997-09-02T12:00:00Z'),
+			(5, 'screening-started', '{}', '1997-09-02T12:10:00Z'),
+			(6, 'screening-finished', '{}', '1997-09-02T12:40:00Z');
+	`)
+	assert.NoError(t, err)
+
+	// Before any events, there's nothing to replay
+	rows, err = q.GetEventLogSince(context.Background(), time.Date(1997, 9, 1, 11, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 0)
+
+	// During the first broadcast, we should replay from its start
+	rows, err = q.GetEventLogSince(context.Background(), time.Date(1997, 9, 1, 12, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, int64(1), rows[0].Seq)
+	assert.Equal(t, int64(2), rows[1].Seq)
+
+	// Between broadcasts, we should only need the event that ended the first one
+	rows, err = q.GetEventLogSince(context.Background(), time.Date(1997, 9, 1, 18, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, int64(3), rows[0].Seq)
+
+	// During the second broadcast, we should replay from its start, up to the given time
+	rows, err = q.GetEventLogSince(context.Background(), time.Date(1997, 9, 2, 12, 20, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, int64(4), rows[0].Seq)
+	assert.Equal(t, int64(5), rows[1].Seq)
+}
+
+func Test_GetStateAtEx(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate a broadcast in which we screened two tapes
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{"type":"broadcast-started","broadcast":{"id":12,"started_at":"1997-09-01T12:00:00Z"},"seq":1}', '1997-09-01T12:00:00Z'),
+			(2, 'screening-started', '{"type":"screening-started","broadcast":{"id":12,"started_at":"1997-09-01T12:00:00Z"},"screening":{"id":"8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f01","started_at":"1997-09-01T12:10:00Z","tape_id":10},"seq":2}', '1997-09-01T12:10:00Z'),
+			(3, 'screening-started', '{"type":"screening-started","broadcast":{"id":12,"started_at":"1997-09-01T12:00:00Z"},"screening":{"id":"8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f02","started_at":"1997-09-01T12:40:00Z","tape_id":11},"seq":3}', '1997-09-01T12:40:00Z'),
+			(4, 'screening-finished', '{"type":"screening-finished","broadcast":{"id":12,"started_at":"1997-09-01T12:00:00Z"},"seq":4}', '1997-09-01T13:10:00Z'),
+			(5, 'broadcast-finished', '{"type":"broadcast-finished","broadcast":{"id":12,"started_at":"1997-09-01T12:00:00Z"},"seq":5}', '1997-09-01T13:30:00Z');
+	`)
+	assert.NoError(t, err)
+
+	// Before the log began, state is unknown
+	_, err = q.GetStateAtEx(context.Background(), time.Date(1997, 9, 1, 11, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, broadcasts.ErrStateUnknown)
+
+	// Partway through the second screening, we should get that tape
+	state, err := q.GetStateAtEx(context.Background(), time.Date(1997, 9, 1, 12, 45, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), state.Version)
+	assert.Equal(t, 12, state.BroadcastId)
+	assert.Equal(t, time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC), *state.BroadcastStartedAt)
+	assert.Equal(t, uuid.MustParse("8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f02"), state.ScreeningId)
+	assert.Equal(t, 11, state.TapeId)
+	assert.Equal(t, time.Date(1997, 9, 1, 12, 40, 0, 0, time.UTC), *state.ScreeningStartedAt)
+
+	// After the screening ended, the broadcast should be live with nothing on screen
+	state, err = q.GetStateAtEx(context.Background(), time.Date(1997, 9, 1, 13, 20, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), state.Version)
+	assert.Equal(t, 12, state.BroadcastId)
+	assert.Equal(t, uuid.Nil, state.ScreeningId)
+	assert.Nil(t, state.ScreeningStartedAt)
+
+	// After the broadcast ended, nothing was live
+	state, err = q.GetStateAtEx(context.Background(), time.Date(1997, 9, 1, 14, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), state.Version)
+	assert.Equal(t, 0, state.BroadcastId)
+	assert.Nil(t, state.BroadcastStartedAt)
+}
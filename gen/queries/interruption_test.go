@@ -0,0 +1,79 @@
+package queries_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/querytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StartBroadcastInterruption(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	querytest.AssertCount(t, tx, 0, "SELECT COUNT(*) FROM broadcasts.broadcast_interruption")
+
+	row, err := q.StartBroadcast(context.Background())
+	assert.NoError(t, err)
+	result, err := q.EndBroadcast(context.Background(), row.ID)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	err = q.StartBroadcastInterruption(context.Background(), row.ID)
+	assert.NoError(t, err)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.broadcast_interruption
+			WHERE broadcast_id = $1
+			AND ended_at IS NULL
+	`, row.ID)
+}
+
+func Test_EndBroadcastInterruption(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate a broadcast that went offline an hour in, then came back 10 minutes
+	// later
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.broadcast (id, started_at, ended_at) VALUES
+			(1, now() - '2h'::interval, now() - '1h'::interval);
+		INSERT INTO broadcasts.broadcast_interruption (broadcast_id, started_at) VALUES
+			(1, now() - '1h'::interval);
+	`)
+	assert.NoError(t, err)
+
+	// The broadcast should not report any interruptions while the interruption is still
+	// open
+	rows, err := q.GetBroadcastDataEx(context.Background(), queries.GetBroadcastDataParams{
+		Limit: sql.NullInt32{Valid: true, Int32: 1},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Len(t, rows[0].Interruptions, 0)
+
+	// Resuming the broadcast should allow us to close the interruption
+	result, err := q.ResumeBroadcast(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	result, err = q.EndBroadcastInterruption(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+
+	// Once closed, the interruption should be reported as part of the broadcast's data
+	rows, err = q.GetBroadcastDataEx(context.Background(), queries.GetBroadcastDataParams{
+		Limit: sql.NullInt32{Valid: true, Int32: 1},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Nil(t, rows[0].EndedAt)
+	assert.Len(t, rows[0].Interruptions, 1)
+	assert.True(t, rows[0].Interruptions[0].EndedAt.After(rows[0].Interruptions[0].StartedAt))
+
+	// There should be no more open interruptions to close
+	result, err = q.EndBroadcastInterruption(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 0)
+}
@@ -6,11 +6,40 @@ package queries
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Permanent record of every attempt to change broadcast state, whether made by hand via the admin API or automatically in response to twitch-events, along with who made the attempt and what came of it.
+type BroadcastsAuditLog struct {
+	// Serial ID for this entry; entries are listed in descending order by ID.
+	ID int64
+	// Time at which the attempt was made.
+	CreatedAt time.Time
+	// Name of the user or process that made the attempt: the login of the Twitch user for manual changes, or e.g. 'twitch-events consumer' for automated ones.
+	Actor string
+	// Twitch user ID of the user that made the attempt, or NULL if it was automated.
+	ActorUserID sql.NullString
+	// IP address from which the HTTP request or WebSocket connection originated, or NULL if the attempt was not made via HTTP.
+	SourceIp sql.NullString
+	// X-Request-Id of the HTTP request that made the attempt, if any.
+	RequestID sql.NullString
+	// Name of the operation attempted, e.g. 'start-broadcast' or 'start-screening'.
+	Action string
+	// JSON object describing the arguments to the operation, e.g. the ID of the tape to be screened.
+	Params json.RawMessage
+	// Broadcast state immediately before the attempt, as a JSON-serialized State.
+	StateBefore json.RawMessage
+	// Broadcast state immediately after the attempt, as a JSON-serialized State. If the attempt did not succeed, this is the state as it stood once it had failed.
+	StateAfter json.RawMessage
+	// Result of the attempt: 'ok' if state was changed; 'rejected' if the operation was not valid in the current state; or 'error' if it failed for any other reason.
+	Outcome string
+	// Error message describing why the attempt did not succeed, if applicable.
+	Error sql.NullString
+}
+
 // Record of a broadcast that occurred (or is occurring) on the GoldenVCR Twitch channel.
 type BroadcastsBroadcast struct {
 	// Serial ID used to correlate other records with this broadcast.
@@ -23,6 +52,46 @@ type BroadcastsBroadcast struct {
 	VodUrl sql.NullString
 }
 
+// Records a period of time during which a broadcast was offline. A row is opened whenever a broadcast ends, and closed if that broadcast is later resumed: an interruption is only a true outage once it's been closed.
+type BroadcastsBroadcastInterruption struct {
+	// Serial ID for this interruption.
+	ID int32
+	// ID of the broadcast that was interrupted.
+	BroadcastID int32
+	// Time at which the broadcast went offline.
+	StartedAt time.Time
+	// Time at which the broadcast was resumed, or NULL if it has not been resumed.
+	EndedAt sql.NullTime
+}
+
+// Permanent, append-only record of every event produced for the broadcast-events queue. Replaying the log in order of seq reconstructs broadcast state as it stood at any point in time since the log began.
+type BroadcastsEventLog struct {
+	// Sequence number of the event, as embedded in its payload.
+	Seq int64
+	// Type of the event, e.g. 'broadcast-started' or 'screening-finished'.
+	EventType string
+	// JSON-serialized event, exactly as sent to broadcast-events.
+	Payload json.RawMessage
+	// Time at which the change described by the event took effect. This is usually the time at which the event was recorded, but may be earlier if the event corrects state after the fact, e.g. when a stale broadcast is ended.
+	OccurredAt time.Time
+}
+
+// Transactional outbox for the broadcast-events queue: each event that describes a change in broadcast state is recorded here in the same transaction that makes that change, then relayed to the queue asynchronously.
+type BroadcastsOutbox struct {
+	// Serial ID for this event; events are relayed in ascending order by ID.
+	ID int64
+	// JSON-serialized ebroadcast.Event to be sent to broadcast-events.
+	Payload json.RawMessage
+	// Time at which the event was recorded.
+	CreatedAt time.Time
+	// Time at which the event was successfully sent to broadcast-events, or NULL if it is still pending.
+	SentAt sql.NullTime
+	// Sequence number for this event, which is also embedded in its payload. Sequence numbers are assigned while holding the broadcast state lock, so they increase by exactly 1 with each event: consumers can use them to detect missed, duplicate, or out-of-order events.
+	Seq int64
+	// W3C trace context (e.g. traceparent and tracestate) of the operation that recorded this event, as a JSON object. The relay injects it into the headers of the AMQP message so that consumers can continue the same trace.
+	TraceContext json.RawMessage
+}
+
 // Records the fact that a particular tape was played during a broadcast.
 type BroadcastsScreening struct {
 	// Unique ID for this screening; used chiefly to associate other data with this screening.
@@ -33,6 +102,46 @@ type BroadcastsScreening struct {
 	TapeID int32
 	// Time at which the screening started.
 	StartedAt time.Time
-	// Time at which the screening ended, if it's not stil ongoing.
+	// Time at which the screening ended, if it's not stil ongoing. When a broadcast ends, any screening that's still in progress is ended at the same time.
 	EndedAt sql.NullTime
 }
+
+// Records the delivery of a single event to a single webhook subscriber. A row is created for each active subscriber in the same transaction that records the event, then updated with the result of each attempt to deliver it.
+type BroadcastsWebhookDelivery struct {
+	// Serial ID for this delivery.
+	ID int64
+	// ID of the subscriber to which the event is being delivered.
+	SubscriberID int32
+	// Sequence number of the event being delivered, identifying it in the event log.
+	Seq int64
+	// Time at which the delivery was scheduled.
+	CreatedAt time.Time
+	// Number of attempts made to deliver the event so far.
+	NumAttempts int32
+	// Time at which the next attempt should be made, or NULL if the event has been delivered or we've given up on delivering it.
+	NextAttemptAt sql.NullTime
+	// Time at which the most recent attempt was made, if any.
+	LastAttemptAt sql.NullTime
+	// HTTP status code returned in response to the most recent attempt, or NULL if no response was received.
+	LastStatus sql.NullInt32
+	// Description of the reason the most recent attempt failed, if it failed.
+	LastError sql.NullString
+	// Time at which the event was successfully delivered, if it has been.
+	DeliveredAt sql.NullTime
+	// Time at which we gave up on delivering the event after exhausting all attempts, if we have. A failed delivery may be replayed, in which case it's scheduled for immediate delivery and this value is cleared.
+	FailedAt sql.NullTime
+}
+
+// Registers an HTTP endpoint that should receive a webhook for every event produced for the broadcast-events queue, for the benefit of services that can't consume from the queue directly.
+type BroadcastsWebhookSubscriber struct {
+	// Serial ID for this subscriber.
+	ID int32
+	// Absolute URL to which each event will be POSTed.
+	Url string
+	// Shared secret used to sign each request with HMAC-SHA256, so that the subscriber can verify that the request originated from the broadcasts service.
+	Secret string
+	// Time at which the subscriber was registered.
+	CreatedAt time.Time
+	// Time at which the subscriber was removed, if applicable. Removed subscribers are retained so that their delivery history remains available, but they receive no further deliveries.
+	DeletedAt sql.NullTime
+}
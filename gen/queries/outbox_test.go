@@ -0,0 +1,152 @@
+package queries_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/querytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RecordOutboxEvent(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	querytest.AssertCount(t, tx, 0, "SELECT COUNT(*) FROM broadcasts.outbox")
+
+	err := q.RecordOutboxEvent(context.Background(), queries.RecordOutboxEventParams{
+		Seq:          1,
+		Payload:      json.RawMessage(`{"type":"broadcast-finished","seq":1}`),
+		TraceContext: json.RawMessage(`{"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}`),
+	})
+	assert.NoError(t, err)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.outbox
+			WHERE seq = 1
+			AND payload::jsonb = '{"type":"broadcast-finished","seq":1}'::jsonb
+			AND trace_context = '{"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}'::jsonb
+			AND sent_at IS NULL
+	`)
+
+	// Sequence numbers must be unique
+	err = q.RecordOutboxEvent(context.Background(), queries.RecordOutboxEventParams{
+		Seq:          1,
+		Payload:      json.RawMessage(`{"type":"broadcast-started","seq":1}`),
+		TraceContext: json.RawMessage(`{}`),
+	})
+	assert.Error(t, err)
+}
+
+func Test_GetNextOutboxSeq(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Our first event should be assigned sequence number 1
+	seq, err := q.GetNextOutboxSeq(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), seq)
+
+	// Subsequent events should follow the most recent sequence number, regardless of
+	// event IDs
+	_, err = tx.Exec(`
+		INSERT INTO broadcasts.outbox (id, seq, payload) VALUES
+			(10, 1, '{}'),
+			(12, 2, '{}');
+	`)
+	assert.NoError(t, err)
+	seq, err = q.GetNextOutboxSeq(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), seq)
+}
+
+func Test_GetPendingOutboxEvents(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// We should have no pending events initially
+	rows, err := q.GetPendingOutboxEvents(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 0)
+
+	// Simulate an event that's already been sent, followed by two pending events
+	_, err = tx.Exec(`
+		INSERT INTO broadcasts.outbox (id, seq, payload, trace_context, created_at, sent_at) VALUES
+			(1, 1, '{"n":1}', '{}', now() - '3m'::interval, now() - '3m'::interval),
+			(2, 2, '{"n":2}', '{"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}', now() - '2m'::interval, NULL),
+			(3, 3, '{"n":3}', '{}', now() - '1m'::interval, NULL);
+	`)
+	assert.NoError(t, err)
+
+	// We should get our pending events in order
+	rows, err = q.GetPendingOutboxEvents(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, int64(2), rows[0].ID)
+	assert.JSONEq(t, `{"n":2}`, string(rows[0].Payload))
+	assert.JSONEq(t, `{"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}`, string(rows[0].TraceContext))
+	assert.Equal(t, int64(3), rows[1].ID)
+	assert.JSONEq(t, `{"n":3}`, string(rows[1].Payload))
+	assert.JSONEq(t, `{}`, string(rows[1].TraceContext))
+
+	// Our limit should be respected
+	rows, err = q.GetPendingOutboxEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, int64(2), rows[0].ID)
+}
+
+func Test_MarkOutboxEventSent(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.outbox (id, seq, payload) VALUES (1, 1, '{}');
+	`)
+	assert.NoError(t, err)
+
+	result, err := q.MarkOutboxEventSent(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.outbox
+			WHERE id = 1
+			AND sent_at IS NOT NULL
+	`)
+
+	// An event that's already been sent should not be marked again
+	result, err = q.MarkOutboxEventSent(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 0)
+}
+
+func Test_TryLockOutboxRelay(t *testing.T) {
+	db := querytest.Prepare(t)
+	t.Cleanup(func() { db.Close() })
+
+	tx1, err := db.Begin()
+	assert.NoError(t, err)
+	defer tx1.Rollback()
+	tx2, err := db.Begin()
+	assert.NoError(t, err)
+	defer tx2.Rollback()
+
+	// The first transaction to try the lock should acquire it
+	acquired, err := queries.New(tx1).TryLockOutboxRelay(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// A concurrent transaction should not be able to acquire it
+	acquired, err = queries.New(tx2).TryLockOutboxRelay(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Once the first transaction is finished, the lock should be released
+	assert.NoError(t, tx1.Rollback())
+	acquired, err = queries.New(tx2).TryLockOutboxRelay(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
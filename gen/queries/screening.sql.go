@@ -14,6 +14,19 @@ import (
 	"github.com/lib/pq"
 )
 
+const endBroadcastScreenings = `-- name: EndBroadcastScreenings :execresult
+update broadcasts.screening set ended_at = broadcast.ended_at
+from broadcasts.broadcast
+where screening.broadcast_id = broadcast.id
+    and broadcast.id = $1
+    and broadcast.ended_at is not null
+    and screening.ended_at is null
+`
+
+func (q *Queries) EndBroadcastScreenings(ctx context.Context, broadcastID int32) (sql.Result, error) {
+	return q.db.ExecContext(ctx, endBroadcastScreenings, broadcastID)
+}
+
 const endScreening = `-- name: EndScreening :execresult
 update broadcasts.screening set ended_at = now()
 where screening.id = $1
@@ -116,3 +116,35 @@ func Test_EndScreening(t *testing.T) {
 			AND ended_at IS NOT NULL
 	`, screeningRow.ID, broadcastRow.ID)
 }
+
+func Test_EndBroadcastScreenings(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate a broadcast that has ended, with one screening that was properly ended
+	// and one that was left in progress
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.broadcast (id, started_at, ended_at) VALUES
+			(1, '1997-09-01 12:00:00+00', '1997-09-01 14:00:00+00');
+		INSERT INTO broadcasts.screening (id, broadcast_id, tape_id, started_at, ended_at) VALUES
+			('6c2c94e3-db0c-4367-8ce7-e86f98ac03d0', 1, 40, '1997-09-01 12:15:00+00', '1997-09-01 12:45:00+00'),
+			('638a6e4b-4225-4aba-8893-b1c5cbad4e21', 1, 50, '1997-09-01 12:45:00+00', NULL);
+	`)
+	assert.NoError(t, err)
+
+	// The open screening should be ended at the same time as the broadcast
+	result, err := q.EndBroadcastScreenings(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.screening
+			WHERE id = '638a6e4b-4225-4aba-8893-b1c5cbad4e21'
+			AND ended_at = '1997-09-01 14:00:00+00'
+	`)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.screening
+			WHERE id = '6c2c94e3-db0c-4367-8ce7-e86f98ac03d0'
+			AND ended_at = '1997-09-01 12:45:00+00'
+	`)
+}
@@ -0,0 +1,29 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/golden-vcr/broadcasts"
+)
+
+func (q *Queries) GetCurrentStateEx(ctx context.Context) (broadcasts.State, error) {
+	row, err := q.GetCurrentState(ctx)
+	if err != nil {
+		return broadcasts.State{}, err
+	}
+
+	// IDs are left zero-valued if there's no broadcast or screening in progress
+	state := broadcasts.State{
+		Version: row.Version,
+	}
+	if row.BroadcastID.Valid {
+		state.BroadcastId = int(row.BroadcastID.Int32)
+		state.BroadcastStartedAt = &row.BroadcastStartedAt.Time
+	}
+	if row.ScreeningID.Valid {
+		state.ScreeningId = row.ScreeningID.UUID
+		state.TapeId = int(row.TapeID.Int32)
+		state.ScreeningStartedAt = &row.ScreeningStartedAt.Time
+	}
+	return state, nil
+}
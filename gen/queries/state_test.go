@@ -0,0 +1,97 @@
+package queries_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/querytest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetCurrentState(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// With no broadcasts and no events, we should get a zero-valued state
+	row, err := q.GetCurrentState(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), row.Version)
+	assert.False(t, row.BroadcastID.Valid)
+	assert.False(t, row.ScreeningID.Valid)
+
+	// Simulate a past broadcast, followed by a live broadcast in which we screened one
+	// tape and are now screening another
+	_, err = tx.Exec(`
+		INSERT INTO broadcasts.broadcast (id, started_at, ended_at) VALUES
+			(1, now() - '1d'::interval, now() - '23h'::interval),
+			(2, now() - '1h'::interval, NULL);
+		INSERT INTO broadcasts.screening (id, broadcast_id, tape_id, started_at, ended_at) VALUES
+			('8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f01', 2, 10, now() - '50m'::interval, now() - '30m'::interval),
+			('8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f02', 2, 11, now() - '30m'::interval, NULL);
+		INSERT INTO broadcasts.outbox (id, seq, payload) VALUES
+			(50, 40, '{}'),
+			(51, 41, '{}');
+	`)
+	assert.NoError(t, err)
+
+	// We should get the live broadcast and its in-progress screening, along with a
+	// version that matches the sequence number of our most recent event
+	row, err = q.GetCurrentState(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(41), row.Version)
+	assert.Equal(t, int32(2), row.BroadcastID.Int32)
+	assert.True(t, row.BroadcastStartedAt.Valid)
+	assert.Equal(t, uuid.MustParse("8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f02"), row.ScreeningID.UUID)
+	assert.Equal(t, int32(11), row.TapeID.Int32)
+	assert.True(t, row.ScreeningStartedAt.Valid)
+
+	// Once the broadcast ends, we should no longer report any broadcast or screening
+	_, err = tx.Exec(`
+		UPDATE broadcasts.screening SET ended_at = now() WHERE broadcast_id = 2 AND ended_at IS NULL;
+		UPDATE broadcasts.broadcast SET ended_at = now() WHERE id = 2;
+		INSERT INTO broadcasts.outbox (id, seq, payload) VALUES (52, 42, '{}');
+	`)
+	assert.NoError(t, err)
+	row, err = q.GetCurrentState(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), row.Version)
+	assert.False(t, row.BroadcastID.Valid)
+	assert.False(t, row.BroadcastStartedAt.Valid)
+	assert.False(t, row.ScreeningID.Valid)
+	assert.False(t, row.TapeID.Valid)
+	assert.False(t, row.ScreeningStartedAt.Valid)
+}
+
+func Test_GetCurrentStateEx(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// With no broadcast in progress, our state should be zero-valued
+	state, err := q.GetCurrentStateEx(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, state.BroadcastId)
+	assert.Nil(t, state.BroadcastStartedAt)
+	assert.Equal(t, uuid.Nil, state.ScreeningId)
+	assert.Nil(t, state.ScreeningStartedAt)
+
+	// Simulate a live broadcast in which a tape is being screened
+	_, err = tx.Exec(`
+		INSERT INTO broadcasts.broadcast (id, started_at) VALUES
+			(1, now() - '1h'::interval);
+		INSERT INTO broadcasts.screening (id, broadcast_id, tape_id, started_at) VALUES
+			('8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f01', 1, 10, now() - '30m'::interval);
+		INSERT INTO broadcasts.outbox (id, seq, payload) VALUES (7, 7, '{}');
+	`)
+	assert.NoError(t, err)
+
+	state, err = q.GetCurrentStateEx(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), state.Version)
+	assert.Equal(t, 1, state.BroadcastId)
+	assert.NotNil(t, state.BroadcastStartedAt)
+	assert.Equal(t, uuid.MustParse("8d4d8d5f-5b4b-4e2a-9f2e-0d2c8c6a9f01"), state.ScreeningId)
+	assert.Equal(t, 10, state.TapeId)
+	assert.NotNil(t, state.ScreeningStartedAt)
+}
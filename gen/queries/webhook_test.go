@@ -0,0 +1,338 @@
+package queries_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/querytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CreateWebhookSubscriber(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	querytest.AssertCount(t, tx, 0, "SELECT COUNT(*) FROM broadcasts.webhook_subscriber")
+
+	row, err := q.CreateWebhookSubscriber(context.Background(), queries.CreateWebhookSubscriberParams{
+		Url:    "https://example.com/hook",
+		Secret: "my-secret",
+	})
+	assert.NoError(t, err)
+
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_subscriber
+			WHERE id = $1
+			AND url = 'https://example.com/hook'
+			AND secret = 'my-secret'
+			AND created_at = $2
+			AND deleted_at IS NULL
+	`, row.ID, row.CreatedAt)
+}
+
+func Test_GetWebhookSubscribers(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate two active subscribers and one that's been deleted
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret, created_at, deleted_at) VALUES
+			(1, 'https://a.example.com', 'a', '1997-09-01T12:00:00Z', NULL),
+			(2, 'https://b.example.com', 'b', '1997-09-01T13:00:00Z', now()),
+			(3, 'https://c.example.com', 'c', '1997-09-01T14:00:00Z', NULL);
+	`)
+	assert.NoError(t, err)
+
+	// Only active subscribers should be listed, in order by ID
+	rows, err := q.GetWebhookSubscribers(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []queries.GetWebhookSubscribersRow{
+		{ID: 1, Url: "https://a.example.com", CreatedAt: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)},
+		{ID: 3, Url: "https://c.example.com", CreatedAt: time.Date(1997, 9, 1, 14, 0, 0, 0, time.UTC)},
+	}, normalizeSubscriberRows(rows))
+}
+
+func Test_DeleteWebhookSubscriber(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret) VALUES
+			(1, 'https://a.example.com', 'a');
+	`)
+	assert.NoError(t, err)
+
+	result, err := q.DeleteWebhookSubscriber(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_subscriber
+			WHERE id = 1
+			AND deleted_at IS NOT NULL
+	`)
+
+	// A subscriber that's already been deleted should not be deleted again
+	result, err = q.DeleteWebhookSubscriber(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 0)
+}
+
+func Test_ScheduleWebhookDeliveries(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate an event, along with two active subscribers and one that's been deleted
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{}', now());
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret, deleted_at) VALUES
+			(1, 'https://a.example.com', 'a', NULL),
+			(2, 'https://b.example.com', 'b', now()),
+			(3, 'https://c.example.com', 'c', NULL);
+	`)
+	assert.NoError(t, err)
+
+	// The event should be scheduled for immediate delivery to each active subscriber
+	err = q.ScheduleWebhookDeliveries(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertCount(t, tx, 2, `
+		SELECT COUNT(*) FROM broadcasts.webhook_delivery
+			WHERE seq = 1
+			AND subscriber_id IN (1, 3)
+			AND num_attempts = 0
+			AND next_attempt_at = now()
+	`)
+	querytest.AssertCount(t, tx, 2, "SELECT COUNT(*) FROM broadcasts.webhook_delivery")
+}
+
+func Test_GetDueWebhookDeliveries(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate deliveries that are due, not yet due, already delivered, or addressed
+	// to a deleted subscriber
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{"seq":1}', now()),
+			(2, 'broadcast-finished', '{"seq":2}', now());
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret, deleted_at) VALUES
+			(1, 'https://a.example.com', 'a', NULL),
+			(2, 'https://b.example.com', 'b', now());
+		INSERT INTO broadcasts.webhook_delivery (id, subscriber_id, seq, num_attempts, next_attempt_at, delivered_at) VALUES
+			(1, 1, 1, 1, NULL, now()),
+			(2, 1, 2, 2, now() - '1m'::interval, NULL),
+			(3, 2, 2, 0, now() - '1m'::interval, NULL);
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret) VALUES
+			(3, 'https://c.example.com', 'c');
+		INSERT INTO broadcasts.webhook_delivery (id, subscriber_id, seq, num_attempts, next_attempt_at) VALUES
+			(4, 3, 1, 0, now() - '2m'::interval),
+			(5, 3, 2, 0, now() + '1m'::interval);
+	`)
+	assert.NoError(t, err)
+
+	// We should get only the due deliveries, in the order they became due
+	rows, err := q.GetDueWebhookDeliveries(context.Background(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, int64(4), rows[0].ID)
+	assert.Equal(t, "https://c.example.com", rows[0].Url)
+	assert.Equal(t, "c", rows[0].Secret)
+	assert.JSONEq(t, `{"seq":1}`, string(rows[0].Payload))
+	assert.Equal(t, int64(2), rows[1].ID)
+	assert.Equal(t, int32(2), rows[1].NumAttempts)
+	assert.JSONEq(t, `{"seq":2}`, string(rows[1].Payload))
+
+	// Our limit should be respected
+	rows, err = q.GetDueWebhookDeliveries(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, int64(4), rows[0].ID)
+}
+
+func Test_MarkWebhookDeliverySucceeded(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	prepareWebhookDelivery(t, tx)
+
+	result, err := q.MarkWebhookDeliverySucceeded(context.Background(), queries.MarkWebhookDeliverySucceededParams{
+		Status:     204,
+		DeliveryID: 1,
+	})
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_delivery
+			WHERE id = 1
+			AND num_attempts = 1
+			AND next_attempt_at IS NULL
+			AND last_attempt_at = now()
+			AND last_status = 204
+			AND last_error IS NULL
+			AND delivered_at = now()
+			AND failed_at IS NULL
+	`)
+
+	// A delivery that's no longer pending should not be updated again
+	result, err = q.MarkWebhookDeliverySucceeded(context.Background(), queries.MarkWebhookDeliverySucceededParams{
+		Status:     204,
+		DeliveryID: 1,
+	})
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 0)
+}
+
+func Test_MarkWebhookDeliveryFailed(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	prepareWebhookDelivery(t, tx)
+
+	// A failed attempt with a retry scheduled should leave the delivery pending
+	result, err := q.MarkWebhookDeliveryFailed(context.Background(), queries.MarkWebhookDeliveryFailedParams{
+		NextAttemptAt: sql.NullTime{Valid: true, Time: time.Date(2097, 9, 1, 12, 0, 0, 0, time.UTC)},
+		Status:        sql.NullInt32{Valid: true, Int32: 500},
+		Error:         "got response with status 500",
+		DeliveryID:    1,
+	})
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_delivery
+			WHERE id = 1
+			AND num_attempts = 1
+			AND next_attempt_at = '2097-09-01T12:00:00Z'::timestamptz
+			AND last_attempt_at = now()
+			AND last_status = 500
+			AND last_error = 'got response with status 500'
+			AND failed_at IS NULL
+	`)
+
+	// A failed attempt with no retry should mark the delivery as failed for good
+	result, err = q.MarkWebhookDeliveryFailed(context.Background(), queries.MarkWebhookDeliveryFailedParams{
+		Error:      "connection refused",
+		DeliveryID: 1,
+	})
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_delivery
+			WHERE id = 1
+			AND num_attempts = 2
+			AND next_attempt_at IS NULL
+			AND last_status IS NULL
+			AND last_error = 'connection refused'
+			AND failed_at = now()
+	`)
+}
+
+func Test_GetWebhookDeliveries(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate three deliveries to one subscriber and one to another
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{}', now()),
+			(2, 'screening-started', '{}', now()),
+			(3, 'broadcast-finished', '{}', now());
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret) VALUES
+			(1, 'https://a.example.com', 'a'),
+			(2, 'https://b.example.com', 'b');
+		INSERT INTO broadcasts.webhook_delivery (id, subscriber_id, seq, num_attempts, last_status, delivered_at, failed_at) VALUES
+			(1, 1, 1, 1, 204, now(), NULL),
+			(2, 2, 1, 1, 204, now(), NULL),
+			(3, 1, 2, 8, 500, NULL, now()),
+			(4, 1, 3, 1, 200, now(), NULL);
+	`)
+	assert.NoError(t, err)
+
+	// We should get the subscriber's deliveries, most recent first
+	rows, err := q.GetWebhookDeliveries(context.Background(), queries.GetWebhookDeliveriesParams{
+		SubscriberID: 1,
+		Limit:        10,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, int64(4), rows[0].ID)
+	assert.Equal(t, "broadcast-finished", rows[0].EventType)
+	assert.Equal(t, int64(3), rows[1].ID)
+	assert.Equal(t, int64(2), rows[1].Seq)
+	assert.Equal(t, int32(8), rows[1].NumAttempts)
+	assert.Equal(t, sql.NullInt32{Valid: true, Int32: 500}, rows[1].LastStatus)
+	assert.True(t, rows[1].FailedAt.Valid)
+	assert.Equal(t, int64(1), rows[2].ID)
+
+	// We should be able to paginate
+	rows, err = q.GetWebhookDeliveries(context.Background(), queries.GetWebhookDeliveriesParams{
+		SubscriberID:     1,
+		BeforeDeliveryID: sql.NullInt64{Valid: true, Int64: 4},
+		Limit:            1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, int64(3), rows[0].ID)
+}
+
+func Test_ReplayFailedWebhookDeliveries(t *testing.T) {
+	tx := querytest.PrepareTx(t)
+	q := queries.New(tx)
+
+	// Simulate a failed delivery, a delivered one, and a failed delivery to another
+	// subscriber
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{}', now()),
+			(2, 'broadcast-finished', '{}', now());
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret) VALUES
+			(1, 'https://a.example.com', 'a'),
+			(2, 'https://b.example.com', 'b');
+		INSERT INTO broadcasts.webhook_delivery (id, subscriber_id, seq, num_attempts, delivered_at, failed_at) VALUES
+			(1, 1, 1, 8, NULL, now()),
+			(2, 1, 2, 1, now(), NULL),
+			(3, 2, 1, 8, NULL, now());
+	`)
+	assert.NoError(t, err)
+
+	// Only the subscriber's failed delivery should be rescheduled
+	result, err := q.ReplayFailedWebhookDeliveries(context.Background(), 1)
+	assert.NoError(t, err)
+	querytest.AssertNumRowsChanged(t, result, 1)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_delivery
+			WHERE id = 1
+			AND num_attempts = 0
+			AND next_attempt_at = now()
+			AND failed_at IS NULL
+	`)
+	querytest.AssertCount(t, tx, 1, `
+		SELECT COUNT(*) FROM broadcasts.webhook_delivery
+			WHERE id = 3
+			AND failed_at IS NOT NULL
+	`)
+}
+
+// prepareWebhookDelivery simulates a single event that's due for delivery to a single
+// subscriber, with delivery ID 1
+func prepareWebhookDelivery(t *testing.T, tx *sql.Tx) {
+	_, err := tx.Exec(`
+		INSERT INTO broadcasts.event_log (seq, event_type, payload, occurred_at) VALUES
+			(1, 'broadcast-started', '{}', now());
+		INSERT INTO broadcasts.webhook_subscriber (id, url, secret) VALUES
+			(1, 'https://a.example.com', 'a');
+		INSERT INTO broadcasts.webhook_delivery (id, subscriber_id, seq, next_attempt_at) VALUES
+			(1, 1, 1, now());
+	`)
+	assert.NoError(t, err)
+}
+
+// normalizeSubscriberRows converts timestamps to UTC so that rows can be compared
+func normalizeSubscriberRows(rows []queries.GetWebhookSubscribersRow) []queries.GetWebhookSubscribersRow {
+	for i := range rows {
+		rows[i].CreatedAt = rows[i].CreatedAt.UTC()
+	}
+	return rows
+}
@@ -1,31 +1,48 @@
 package admin
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts/internal/audit"
 	"github.com/golden-vcr/broadcasts/internal/state"
 	"github.com/golden-vcr/server-common/entry"
 	"github.com/gorilla/mux"
 )
 
 type Server struct {
-	w state.Writer
+	w       state.Writer
+	proxies audit.TrustedProxies
 }
 
-func NewServer(w state.Writer) *Server {
+// NewServer returns a Server that modifies broadcast state via the given Writer. When
+// recording changes in the audit log, X-Forwarded-For is only believed if the request
+// was made by one of the given proxies.
+func NewServer(w state.Writer, proxies audit.TrustedProxies) *Server {
 	return &Server{
-		w: w,
+		w:       w,
+		proxies: proxies,
 	}
 }
 
 func (s *Server) RegisterRoutes(c auth.Client, r *mux.Router) {
-	// Require broadcaster access for all admin routes
+	// Require broadcaster access for all admin routes, and attribute any changes in
+	// state to the broadcaster in the audit log
 	r.Use(func(next http.Handler) http.Handler {
 		return auth.RequireAccess(c, auth.RoleBroadcaster, next)
 	})
+	r.Use(s.proxies.Middleware)
+
+	// POST /broadcast and DELETE /broadcast allow the broadcaster to start and end
+	// broadcasts by hand, and POST /broadcast/{id}/resume allows the most recent
+	// broadcast to be resumed after it's ended, in case the events that would
+	// ordinarily drive broadcast state are missed
+	r.Path("/broadcast").Methods("POST").HandlerFunc(s.handleStartBroadcast)
+	r.Path("/broadcast").Methods("DELETE").HandlerFunc(s.handleEndBroadcast)
+	r.Path("/broadcast/{id}/resume").Methods("POST").HandlerFunc(s.handleResumeBroadcast)
 
 	// POST /tape allows the broadcaster to notify the backend that we're now screening
 	// a new tape
@@ -80,3 +97,74 @@ func (s *Server) handleClearTape(res http.ResponseWriter, req *http.Request) {
 	}
 	http.Error(res, err.Error(), http.StatusInternalServerError)
 }
+
+func (s *Server) handleStartBroadcast(res http.ResponseWriter, req *http.Request) {
+	// Update the DB with our new broadcast, and propagate to broadcast-events
+	broadcast, err := s.w.StartBroadcast(req.Context())
+	if err != nil {
+		// Return 400 if a broadcast is already in progress; 500 for anything else
+		if errors.Is(err, state.ErrBroadcastInProgress) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry.Log(req).Info("Started broadcast", "broadcast", broadcast)
+	if err := json.NewEncoder(res).Encode(broadcast); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleEndBroadcast(res http.ResponseWriter, req *http.Request) {
+	// Update the DB to end the current broadcast, and propagate to broadcast-events
+	broadcast, err := s.w.EndCurrentBroadcast(req.Context())
+	if err != nil {
+		// Return 400 if there's no broadcast to end; 500 for anything else
+		if errors.Is(err, state.ErrNoBroadcastInProgress) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry.Log(req).Info("Ended broadcast", "broadcast", broadcast)
+	if err := json.NewEncoder(res).Encode(broadcast); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleResumeBroadcast(res http.ResponseWriter, req *http.Request) {
+	// Figure out which broadcast we want to resume
+	broadcastIdStr, ok := mux.Vars(req)["id"]
+	if !ok || broadcastIdStr == "" {
+		http.Error(res, "failed to parse 'id' from URL", http.StatusInternalServerError)
+		return
+	}
+	broadcastId, err := strconv.Atoi(broadcastIdStr)
+	if err != nil {
+		http.Error(res, "broadcast ID must be an integer", http.StatusBadRequest)
+		return
+	}
+
+	// Update the DB to resume the broadcast, and propagate to broadcast-events
+	broadcast, err := s.w.ResumeBroadcast(req.Context(), broadcastId)
+	if err != nil {
+		// Return 404 if the broadcast doesn't exist; 400 if our current state doesn't
+		// allow it to be resumed; 500 for anything else
+		if errors.Is(err, state.ErrNoSuchBroadcast) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, state.ErrBroadcastInProgress) || errors.Is(err, state.ErrBroadcastNotResumable) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry.Log(req).Info("Resumed broadcast", "broadcast", broadcast)
+	if err := json.NewEncoder(res).Encode(broadcast); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
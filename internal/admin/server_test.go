@@ -10,9 +10,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/golden-vcr/broadcasts"
-	"github.com/golden-vcr/broadcasts/internal/state"
-	"github.com/google/uuid"
+	"github.com/golden-vcr/broadcasts/broadcaststest"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,38 +19,37 @@ func Test_Server_handleSetTape(t *testing.T) {
 	tests := []struct {
 		name       string
 		tapeIdStr  string
-		w          *mockWriter
+		setup      func(w *broadcaststest.Writer)
 		wantStatus int
 		wantBody   string
 	}{
 		{
 			"normal usage",
 			"42",
-			&mockWriter{},
+			startBroadcast,
 			http.StatusNoContent,
 			"",
 		},
 		{
 			"URL parameter must be a valid tape ID",
 			"bad-id",
-			&mockWriter{},
+			startBroadcast,
 			http.StatusBadRequest,
 			"tape ID must be an integer",
 		},
 		{
 			"changing tape without an active broadcast is a 400",
 			"42",
-			&mockWriter{
-				err: state.ErrNoBroadcastInProgress,
-			},
+			func(w *broadcaststest.Writer) {},
 			http.StatusBadRequest,
 			"no broadcast is currently in progress",
 		},
 		{
 			"screening a tape that's already being screened is a 400",
 			"42",
-			&mockWriter{
-				err: state.ErrScreeningInProgress,
+			func(w *broadcaststest.Writer) {
+				startBroadcast(w)
+				w.StartScreening(context.Background(), 42)
 			},
 			http.StatusBadRequest,
 			"the desired tape is already being screened",
@@ -60,8 +57,8 @@ func Test_Server_handleSetTape(t *testing.T) {
 		{
 			"any other error is a 500",
 			"42",
-			&mockWriter{
-				err: fmt.Errorf("oh no"),
+			func(w *broadcaststest.Writer) {
+				w.SetError(fmt.Errorf("oh no"))
 			},
 			http.StatusInternalServerError,
 			"oh no",
@@ -69,21 +66,21 @@ func Test_Server_handleSetTape(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			t.Run(tt.name, func(t *testing.T) {
-				s := &Server{
-					w: tt.w,
-				}
-				req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/tape/%s", tt.tapeIdStr), nil)
-				req = mux.SetURLVars(req, map[string]string{"id": tt.tapeIdStr})
-				res := httptest.NewRecorder()
-				s.handleSetTape(res, req)
+			w := newWriter()
+			tt.setup(w)
+			s := &Server{
+				w: w,
+			}
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/tape/%s", tt.tapeIdStr), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.tapeIdStr})
+			res := httptest.NewRecorder()
+			s.handleSetTape(res, req)
 
-				b, err := io.ReadAll(res.Body)
-				assert.NoError(t, err)
-				body := strings.TrimSuffix(string(b), "\n")
-				assert.Equal(t, tt.wantStatus, res.Code)
-				assert.Equal(t, tt.wantBody, body)
-			})
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
 		})
 	}
 }
@@ -91,36 +88,35 @@ func Test_Server_handleSetTape(t *testing.T) {
 func Test_Server_handleClearTape(t *testing.T) {
 	tests := []struct {
 		name       string
-		w          *mockWriter
+		setup      func(w *broadcaststest.Writer)
 		wantStatus int
 		wantBody   string
 	}{
 		{
 			"normal usage",
-			&mockWriter{},
+			func(w *broadcaststest.Writer) {
+				startBroadcast(w)
+				w.StartScreening(context.Background(), 42)
+			},
 			http.StatusNoContent,
 			"",
 		},
 		{
 			"clearing tape is still successful if nothing was being screened",
-			&mockWriter{
-				err: state.ErrNoScreeningInProgress,
-			},
+			startBroadcast,
 			http.StatusNoContent,
 			"",
 		},
 		{
 			"clearing tape without an active broadcast is a 400",
-			&mockWriter{
-				err: state.ErrNoBroadcastInProgress,
-			},
+			func(w *broadcaststest.Writer) {},
 			http.StatusBadRequest,
 			"no broadcast is currently in progress",
 		},
 		{
 			"any other error is a 500",
-			&mockWriter{
-				err: fmt.Errorf("oh no"),
+			func(w *broadcaststest.Writer) {
+				w.SetError(fmt.Errorf("oh no"))
 			},
 			http.StatusInternalServerError,
 			"oh no",
@@ -128,48 +124,227 @@ func Test_Server_handleClearTape(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			t.Run(tt.name, func(t *testing.T) {
-				s := &Server{
-					w: tt.w,
-				}
-				req := httptest.NewRequest(http.MethodDelete, "/admin/tape", nil)
-				res := httptest.NewRecorder()
-				s.handleClearTape(res, req)
+			w := newWriter()
+			tt.setup(w)
+			s := &Server{
+				w: w,
+			}
+			req := httptest.NewRequest(http.MethodDelete, "/admin/tape", nil)
+			res := httptest.NewRecorder()
+			s.handleClearTape(res, req)
 
-				b, err := io.ReadAll(res.Body)
-				assert.NoError(t, err)
-				body := strings.TrimSuffix(string(b), "\n")
-				assert.Equal(t, tt.wantStatus, res.Code)
-				assert.Equal(t, tt.wantBody, body)
-			})
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
 		})
 	}
 }
 
-type mockWriter struct {
-	err error
+func Test_Server_handleStartBroadcast(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(w *broadcaststest.Writer)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"normal usage",
+			func(w *broadcaststest.Writer) {},
+			http.StatusOK,
+			`{"id":1,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[]}`,
+		},
+		{
+			"starting a broadcast while one is in progress is a 400",
+			startBroadcast,
+			http.StatusBadRequest,
+			"a broadcast is already in progress",
+		},
+		{
+			"any other error is a 500",
+			func(w *broadcaststest.Writer) {
+				w.SetError(fmt.Errorf("oh no"))
+			},
+			http.StatusInternalServerError,
+			"oh no",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWriter()
+			tt.setup(w)
+			s := &Server{
+				w: w,
+			}
+			req := httptest.NewRequest(http.MethodPost, "/admin/broadcast", nil)
+			res := httptest.NewRecorder()
+			s.handleStartBroadcast(res, req)
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+}
+
+func Test_Server_handleEndBroadcast(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(w *broadcaststest.Writer)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"normal usage",
+			startBroadcast,
+			http.StatusOK,
+			`{"id":1,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T13:00:00Z","screenings":[],"interruptions":[]}`,
+		},
+		{
+			"ending a broadcast when none is in progress is a 400",
+			func(w *broadcaststest.Writer) {},
+			http.StatusBadRequest,
+			"no broadcast is currently in progress",
+		},
+		{
+			"any other error is a 500",
+			func(w *broadcaststest.Writer) {
+				startBroadcast(w)
+				w.SetError(fmt.Errorf("oh no"))
+			},
+			http.StatusInternalServerError,
+			"oh no",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWriter()
+			tt.setup(w)
+			s := &Server{
+				w: w,
+			}
+			req := httptest.NewRequest(http.MethodDelete, "/admin/broadcast", nil)
+			res := httptest.NewRecorder()
+			s.handleEndBroadcast(res, req)
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
 }
 
-func (m *mockWriter) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
-	return nil, fmt.Errorf("not mocked")
+func Test_Server_handleResumeBroadcast(t *testing.T) {
+	tests := []struct {
+		name           string
+		broadcastIdStr string
+		setup          func(w *broadcaststest.Writer)
+		wantStatus     int
+		wantBody       string
+	}{
+		{
+			"normal usage",
+			"1",
+			func(w *broadcaststest.Writer) {
+				startBroadcast(w)
+				endBroadcast(w)
+			},
+			http.StatusOK,
+			`{"id":1,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[{"startedAt":"1997-09-01T13:00:00Z","endedAt":"1997-09-01T14:00:00Z"}]}`,
+		},
+		{
+			"URL parameter must be a valid broadcast ID",
+			"bad-id",
+			func(w *broadcaststest.Writer) {},
+			http.StatusBadRequest,
+			"broadcast ID must be an integer",
+		},
+		{
+			"resuming a nonexistent broadcast is a 404",
+			"2",
+			func(w *broadcaststest.Writer) {
+				startBroadcast(w)
+				endBroadcast(w)
+			},
+			http.StatusNotFound,
+			"no such broadcast",
+		},
+		{
+			"resuming a broadcast while one is in progress is a 400",
+			"1",
+			startBroadcast,
+			http.StatusBadRequest,
+			"a broadcast is already in progress",
+		},
+		{
+			"resuming a broadcast other than the most recent one is a 400",
+			"1",
+			func(w *broadcaststest.Writer) {
+				startBroadcast(w)
+				endBroadcast(w)
+				startBroadcast(w)
+				endBroadcast(w)
+			},
+			http.StatusBadRequest,
+			"only the most recent broadcast may be resumed",
+		},
+		{
+			"any other error is a 500",
+			"1",
+			func(w *broadcaststest.Writer) {
+				w.SetError(fmt.Errorf("oh no"))
+			},
+			http.StatusInternalServerError,
+			"oh no",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWriter()
+			tt.setup(w)
+			s := &Server{
+				w: w,
+			}
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/broadcast/%s/resume", tt.broadcastIdStr), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.broadcastIdStr})
+			res := httptest.NewRecorder()
+			s.handleResumeBroadcast(res, req)
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
 }
 
-func (m *mockWriter) EndCurrentBroadcast(ctx context.Context) error {
-	return fmt.Errorf("not mocked")
+// newWriter returns an in-memory Writer whose clock starts at 1997-09-01 12:00:00 UTC
+// and advances by an hour each time it is read
+func newWriter() *broadcaststest.Writer {
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	w := broadcaststest.NewWriter(0)
+	w.SetClock(func() time.Time {
+		t := now
+		now = now.Add(time.Hour)
+		return t
+	})
+	return w
 }
 
-func (m *mockWriter) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
-	if m.err != nil {
-		return nil, m.err
+func startBroadcast(w *broadcaststest.Writer) {
+	if _, err := w.StartBroadcast(context.Background()); err != nil {
+		panic(err)
 	}
-	return &broadcasts.Screening{
-		Id:        uuid.MustParse("df0d9c53-8a7a-4788-9d20-dc718cf4a7b3"),
-		TapeId:    tapeId,
-		StartedAt: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
-		EndedAt:   nil,
-	}, nil
 }
 
-func (m *mockWriter) EndCurrentScreening(ctx context.Context) error {
-	return m.err
+func endBroadcast(w *broadcaststest.Writer) {
+	if _, err := w.EndCurrentBroadcast(context.Background()); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,153 @@
+// Package audit records every attempt to change broadcast state in the audit_log
+// table, identifying the user or process responsible, and serves that log to the
+// broadcaster via the admin API.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/golden-vcr/auth"
+)
+
+// Names used to identify actors other than Twitch users
+const (
+	// ActorConsumer is responsible for changes made automatically in response to
+	// messages from the twitch-events queue
+	ActorConsumer = "twitch-events consumer"
+	// ActorReconciler is responsible for changes made automatically when broadcast
+	// state is found to disagree with the stream's actual status
+	ActorReconciler = "reconciler"
+	// ActorUnknown is recorded if a change is made in a context that carries no actor
+	ActorUnknown = "unknown"
+)
+
+// Actor identifies the user or process that's attempting to change broadcast state,
+// along with the details of the request through which they're doing so, if any
+type Actor struct {
+	Name      string
+	UserId    string
+	SourceIp  string
+	RequestId string
+}
+
+type contextKey struct{}
+
+// WithActor returns a copy of ctx that attributes any changes in broadcast state to the
+// given actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, contextKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx, or an actor named ActorUnknown if
+// ctx carries none
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(contextKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: ActorUnknown}
+}
+
+// NewUserActor returns an Actor that identifies the Twitch user described by claims,
+// making a request from the given IP address. The request ID is taken from ctx, where
+// it's stored by entry.Middleware.
+func NewUserActor(ctx context.Context, claims *auth.AccessClaims, sourceIp string) Actor {
+	actor := Actor{Name: ActorUnknown, SourceIp: sourceIp}
+	if claims != nil && claims.User != nil {
+		actor.Name = claims.User.Login
+		actor.UserId = claims.User.Id
+	}
+	if requestId, ok := ctx.Value("x-request-id").(string); ok {
+		actor.RequestId = requestId
+	}
+	return actor
+}
+
+// TrustedProxies identifies the reverse proxies in front of the server, whose
+// X-Forwarded-For headers can be believed when determining the IP address of a client.
+// The zero value trusts no proxies, in which case a request's source IP is always the
+// address of the peer that made it.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges (e.g. "10.0.0.0/8")
+// that identify trusted proxies
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// SourceIp returns the IP address of the client that made the given request. If the
+// request was made by a trusted proxy, X-Forwarded-For is read from right to left,
+// skipping the addresses of any further trusted proxies: the first untrusted address is
+// the client's. Anything to the left of that address was supplied by the client, and
+// could be forged, so it's ignored.
+func (p TrustedProxies) SourceIp(req *http.Request) string {
+	sourceIp, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		sourceIp = req.RemoteAddr
+	}
+	if !p.trusts(sourceIp) {
+		return sourceIp
+	}
+
+	hops := strings.Split(strings.Join(req.Header.Values("x-forwarded-for"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// We can't tell who added a malformed entry, so we stop at the last proxy
+			// we trust
+			break
+		}
+		sourceIp = hop
+		if !p.trusts(hop) {
+			break
+		}
+	}
+	return sourceIp
+}
+
+// trusts returns true if the given IP address belongs to a trusted proxy
+func (p TrustedProxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware is a mux middleware that attributes any changes in broadcast state made
+// while handling a request to the user who made that request. It must run after
+// auth.RequireAccess, which identifies the user.
+func (p TrustedProxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		claims, _ := auth.GetClaims(req)
+		actor := NewUserActor(req.Context(), claims, p.SourceIp(req))
+		next.ServeHTTP(res, req.WithContext(WithActor(req.Context(), actor)))
+	})
+}
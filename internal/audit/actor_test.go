@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/golden-vcr/auth"
+	authmock "github.com/golden-vcr/auth/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ActorFromContext(t *testing.T) {
+	assert.Equal(t, Actor{Name: ActorUnknown}, ActorFromContext(context.Background()))
+
+	ctx := WithActor(context.Background(), Actor{Name: ActorConsumer})
+	assert.Equal(t, Actor{Name: ActorConsumer}, ActorFromContext(ctx))
+}
+
+func Test_NewUserActor(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "x-request-id", "my-request")
+	claims := &auth.AccessClaims{
+		User: &auth.UserDetails{Id: "5678", Login: "broadcaster", DisplayName: "Broadcaster"},
+		Role: auth.RoleBroadcaster,
+	}
+	assert.Equal(t, Actor{
+		Name:      "broadcaster",
+		UserId:    "5678",
+		SourceIp:  "203.0.113.7",
+		RequestId: "my-request",
+	}, NewUserActor(ctx, claims, "203.0.113.7"))
+
+	// Without claims, the user can't be identified
+	assert.Equal(t, Actor{Name: ActorUnknown, SourceIp: "203.0.113.7"}, NewUserActor(context.Background(), nil, "203.0.113.7"))
+}
+
+func Test_ParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	assert.NoError(t, err)
+	assert.Equal(t, TrustedProxies{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, proxies)
+
+	_, err = ParseTrustedProxies([]string{"not-an-ip"})
+	assert.Error(t, err)
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/99"})
+	assert.Error(t, err)
+}
+
+func Test_TrustedProxies_SourceIp(t *testing.T) {
+	proxies := TrustedProxies{
+		netip.MustParsePrefix("10.0.0.0/8"),
+	}
+	tests := []struct {
+		name         string
+		proxies      TrustedProxies
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"remote address is used by default", proxies, "203.0.113.7:54321", nil, "203.0.113.7"},
+		{"IPv6 remote address is supported", proxies, "[2001:db8::1]:54321", nil, "2001:db8::1"},
+		{"remote address without a port is used verbatim", proxies, "203.0.113.7", nil, "203.0.113.7"},
+		{"header from untrusted peer is ignored", proxies, "203.0.113.7:54321", []string{"198.51.100.2"}, "203.0.113.7"},
+		{"header is ignored if no proxies are trusted", nil, "10.0.0.1:54321", []string{"198.51.100.2"}, "10.0.0.1"},
+		{"header from trusted proxy identifies client", proxies, "10.0.0.1:54321", []string{"198.51.100.2"}, "198.51.100.2"},
+		{"spoofed entries added by client are ignored", proxies, "10.0.0.1:54321", []string{"192.0.2.99, 198.51.100.2"}, "198.51.100.2"},
+		{"chain of trusted proxies is skipped", proxies, "10.0.0.1:54321", []string{"192.0.2.99, 198.51.100.2, 10.0.0.2"}, "198.51.100.2"},
+		{"multiple headers are read as one list", proxies, "10.0.0.1:54321", []string{"192.0.2.99", "198.51.100.2, 10.0.0.2"}, "198.51.100.2"},
+		{"malformed entry stops at last trusted proxy", proxies, "10.0.0.1:54321", []string{"198.51.100.2, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"trusted proxy without header is the source", proxies, "10.0.0.1:54321", nil, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("x-forwarded-for", value)
+			}
+			assert.Equal(t, tt.want, tt.proxies.SourceIp(req))
+		})
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	authClient := authmock.NewClient().AllowTwitchUserAccessToken("broadcaster-token", auth.RoleBroadcaster, auth.UserDetails{
+		Id:          "5678",
+		Login:       "broadcaster",
+		DisplayName: "Broadcaster",
+	})
+
+	// Changes made while handling a request should be attributed to the authenticated
+	// user who made it
+	var got Actor
+	proxies := TrustedProxies{netip.MustParsePrefix("10.0.0.0/8")}
+	handler := auth.RequireAccess(authClient, auth.RoleBroadcaster, proxies.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		got = ActorFromContext(req.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/tape/42", nil)
+	req.RemoteAddr = "10.0.0.1:54321"
+	req.Header.Set("x-forwarded-for", "192.0.2.99, 203.0.113.7")
+	req.Header.Set("authorization", "Bearer broadcaster-token")
+	req = req.WithContext(context.WithValue(req.Context(), "x-request-id", "my-request"))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, Actor{
+		Name:      "broadcaster",
+		UserId:    "5678",
+		SourceIp:  "203.0.113.7",
+		RequestId: "my-request",
+	}, got)
+}
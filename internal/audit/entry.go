@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+)
+
+// The operations that are recorded in the audit log, one for each method of
+// state.Writer
+const (
+	ActionStartBroadcast    = "start-broadcast"
+	ActionEndBroadcast      = "end-broadcast"
+	ActionResumeBroadcast   = "resume-broadcast"
+	ActionEndStaleBroadcast = "end-stale-broadcast"
+	ActionStartScreening    = "start-screening"
+	ActionEndScreening      = "end-screening"
+)
+
+// The possible outcomes of an attempt to change broadcast state
+const (
+	// OutcomeOk indicates that state was changed
+	OutcomeOk = "ok"
+	// OutcomeRejected indicates that the operation wasn't valid in the current state
+	OutcomeRejected = "rejected"
+	// OutcomeError indicates that the operation failed for any other reason
+	OutcomeError = "error"
+)
+
+// OutcomeOf classifies the error returned from an attempt to change broadcast state
+func OutcomeOf(err error) string {
+	if err == nil {
+		return OutcomeOk
+	}
+	if broadcasts.IsTransitionError(err) || errors.Is(err, broadcasts.ErrNoSuchBroadcast) || errors.Is(err, broadcasts.ErrBroadcastNotResumable) {
+		return OutcomeRejected
+	}
+	return OutcomeError
+}
+
+// NewEntry prepares an audit log entry recording an attempt, by the actor carried in
+// ctx, to perform the given action. params describes the arguments to the action and
+// may be nil; cause is the error that the attempt resulted in, if any.
+func NewEntry(ctx context.Context, action string, params interface{}, before broadcasts.State, after broadcasts.State, cause error) (queries.RecordAuditLogEntryParams, error) {
+	paramsData := json.RawMessage(`{}`)
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return queries.RecordAuditLogEntryParams{}, err
+		}
+		paramsData = data
+	}
+	beforeData, err := json.Marshal(before)
+	if err != nil {
+		return queries.RecordAuditLogEntryParams{}, err
+	}
+	afterData, err := json.Marshal(after)
+	if err != nil {
+		return queries.RecordAuditLogEntryParams{}, err
+	}
+
+	actor := ActorFromContext(ctx)
+	entry := queries.RecordAuditLogEntryParams{
+		Actor:       actor.Name,
+		ActorUserID: nullString(actor.UserId),
+		SourceIp:    nullString(actor.SourceIp),
+		RequestID:   nullString(actor.RequestId),
+		Action:      action,
+		Params:      paramsData,
+		StateBefore: beforeData,
+		StateAfter:  afterData,
+		Outcome:     OutcomeOf(cause),
+	}
+	if cause != nil {
+		entry.Error = nullString(cause.Error())
+	}
+	return entry, nil
+}
+
+// nullString converts an empty string to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{Valid: s != "", String: s}
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OutcomeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, OutcomeOk},
+		{broadcasts.ErrBroadcastInProgress, OutcomeRejected},
+		{broadcasts.ErrNoScreeningInProgress, OutcomeRejected},
+		{broadcasts.ErrNoSuchBroadcast, OutcomeRejected},
+		{broadcasts.ErrBroadcastNotResumable, OutcomeRejected},
+		{fmt.Errorf("wrapped: %w", broadcasts.ErrNoBroadcastInProgress), OutcomeRejected},
+		{fmt.Errorf("db is down"), OutcomeError},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.err), func(t *testing.T) {
+			assert.Equal(t, tt.want, OutcomeOf(tt.err))
+		})
+	}
+}
+
+func Test_NewEntry(t *testing.T) {
+	startedAt := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	before := broadcasts.State{
+		State:              core.State{BroadcastId: 55},
+		Version:            3,
+		BroadcastStartedAt: &startedAt,
+	}
+	after := before
+	after.TapeId = 42
+	after.Version = 4
+
+	t.Run("successful change is attributed to actor", func(t *testing.T) {
+		ctx := WithActor(context.Background(), Actor{Name: "broadcaster", UserId: "5678", SourceIp: "203.0.113.7", RequestId: "my-request"})
+		entry, err := NewEntry(ctx, ActionStartScreening, map[string]interface{}{"tapeId": 42}, before, after, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "broadcaster", entry.Actor)
+		assert.Equal(t, sql.NullString{Valid: true, String: "5678"}, entry.ActorUserID)
+		assert.Equal(t, sql.NullString{Valid: true, String: "203.0.113.7"}, entry.SourceIp)
+		assert.Equal(t, sql.NullString{Valid: true, String: "my-request"}, entry.RequestID)
+		assert.Equal(t, ActionStartScreening, entry.Action)
+		assert.JSONEq(t, `{"tapeId":42}`, string(entry.Params))
+		assertStateJSON(t, before, entry.StateBefore)
+		assertStateJSON(t, after, entry.StateAfter)
+		assert.Equal(t, OutcomeOk, entry.Outcome)
+		assert.False(t, entry.Error.Valid)
+	})
+
+	t.Run("failed change records error", func(t *testing.T) {
+		ctx := WithActor(context.Background(), Actor{Name: ActorConsumer})
+		entry, err := NewEntry(ctx, ActionStartBroadcast, nil, before, before, broadcasts.ErrBroadcastInProgress)
+		assert.NoError(t, err)
+		assert.Equal(t, queries.RecordAuditLogEntryParams{
+			Actor:       ActorConsumer,
+			Action:      ActionStartBroadcast,
+			Params:      json.RawMessage(`{}`),
+			StateBefore: entry.StateBefore,
+			StateAfter:  entry.StateAfter,
+			Outcome:     OutcomeRejected,
+			Error:       sql.NullString{Valid: true, String: broadcasts.ErrBroadcastInProgress.Error()},
+		}, entry)
+		assertStateJSON(t, before, entry.StateAfter)
+	})
+
+	t.Run("change without an actor is attributed to unknown", func(t *testing.T) {
+		entry, err := NewEntry(context.Background(), ActionEndBroadcast, nil, before, after, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, ActorUnknown, entry.Actor)
+	})
+}
+
+func assertStateJSON(t *testing.T, want broadcasts.State, got json.RawMessage) {
+	var state broadcasts.State
+	assert.NoError(t, json.Unmarshal(got, &state))
+	assert.Equal(t, want, state)
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/gorilla/mux"
+)
+
+// Queries is the subset of queries required to read the audit log
+type Queries interface {
+	GetAuditLogEntries(ctx context.Context, arg queries.GetAuditLogEntriesParams) ([]queries.GetAuditLogEntriesRow, error)
+}
+
+type Server struct {
+	q Queries
+}
+
+// NewServer returns a Server that reads the audit log from the given database
+func NewServer(q *queries.Queries) *Server {
+	return &Server{
+		q: q,
+	}
+}
+
+func (s *Server) RegisterRoutes(c auth.Client, r *mux.Router) {
+	// Require broadcaster access to read the audit log
+	r.Use(func(next http.Handler) http.Handler {
+		return auth.RequireAccess(c, auth.RoleBroadcaster, next)
+	})
+
+	// GET lists audit log entries, most recent first
+	r.Path("").Methods("GET").HandlerFunc(s.handleGetEntries)
+}
+
+func (s *Server) handleGetEntries(res http.ResponseWriter, req *http.Request) {
+	// Accept 'n' and 'before' query params to scope our request and allow pagination
+	arg := queries.GetAuditLogEntriesParams{
+		Limit: 20,
+	}
+	if nStr := req.URL.Query().Get("n"); nStr != "" {
+		if n, err := strconv.Atoi(nStr); err == nil && n > 0 && n <= 100 {
+			arg.Limit = int32(n)
+		}
+	}
+	if beforeStr := req.URL.Query().Get("before"); beforeStr != "" {
+		if before, err := strconv.ParseInt(beforeStr, 10, 64); err == nil {
+			arg.BeforeEntryID.Valid = true
+			arg.BeforeEntryID.Int64 = before
+		}
+	}
+
+	// Accept 'actor', 'action', and 'outcome' to filter for exact matches
+	if actor := req.URL.Query().Get("actor"); actor != "" {
+		arg.Actor.Valid = true
+		arg.Actor.String = actor
+	}
+	if action := req.URL.Query().Get("action"); action != "" {
+		arg.Action.Valid = true
+		arg.Action.String = action
+	}
+	if outcome := req.URL.Query().Get("outcome"); outcome != "" {
+		if outcome != OutcomeOk && outcome != OutcomeRejected && outcome != OutcomeError {
+			http.Error(res, fmt.Sprintf("'outcome' must be one of '%s', '%s', or '%s'", OutcomeOk, OutcomeRejected, OutcomeError), http.StatusBadRequest)
+			return
+		}
+		arg.Outcome.Valid = true
+		arg.Outcome.String = outcome
+	}
+
+	// Accept 'since' and 'until' as RFC3339 timestamps to filter by time
+	var err error
+	if arg.Since, err = parseTimeParam(req, "since"); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if arg.Until, err = parseTimeParam(req, "until"); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := s.q.GetAuditLogEntries(req.Context(), arg)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := Log{
+		Entries: make([]Entry, 0, len(rows)),
+	}
+	for i := range rows {
+		result.Entries = append(result.Entries, entryFromRow(&rows[i]))
+	}
+	if err := json.NewEncoder(res).Encode(result); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseTimeParam parses the named query param as an RFC3339 timestamp, returning NULL
+// if it's not supplied
+func parseTimeParam(req *http.Request, name string) (sql.NullTime, error) {
+	str := req.URL.Query().Get(name)
+	if str == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("'%s' must be an RFC3339 timestamp", name)
+	}
+	return sql.NullTime{Valid: true, Time: t}, nil
+}
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/auth"
+	authmock "github.com/golden-vcr/auth/mock"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server_RegisterRoutes(t *testing.T) {
+	authClient := authmock.NewClient().AllowTwitchUserAccessToken("viewer-token", auth.RoleViewer, auth.UserDetails{
+		Id:          "1234",
+		Login:       "viewer",
+		DisplayName: "Viewer",
+	}).AllowTwitchUserAccessToken("broadcaster-token", auth.RoleBroadcaster, auth.UserDetails{
+		Id:          "5678",
+		Login:       "broadcaster",
+		DisplayName: "Broadcaster",
+	})
+
+	// Mount our routes the same way the server does, alongside other admin routes
+	r := mux.NewRouter()
+	s := &Server{q: &mockQueries{}}
+	s.RegisterRoutes(authClient, r.PathPrefix("/admin/audit").Subrouter())
+	r.PathPrefix("/admin").Subrouter().Path("/broadcast").Methods("POST").HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"audit log can be listed", http.MethodGet, "/admin/audit", "broadcaster-token", http.StatusOK},
+		{"broadcaster access is required", http.MethodGet, "/admin/audit", "viewer-token", http.StatusForbidden},
+		{"authentication is required", http.MethodGet, "/admin/audit", "", http.StatusBadRequest},
+		{"other admin routes are unaffected", http.MethodPost, "/admin/broadcast", "", http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("authorization", "Bearer "+tt.token)
+			}
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+			assert.Equal(t, tt.wantStatus, res.Code)
+		})
+	}
+}
+
+func Test_Server_handleGetEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+		wantArg    queries.GetAuditLogEntriesParams
+	}{
+		{
+			"normal usage",
+			"",
+			&mockQueries{
+				entries: []queries.GetAuditLogEntriesRow{
+					{
+						ID:          2,
+						CreatedAt:   time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC),
+						Actor:       "broadcaster",
+						ActorUserID: sql.NullString{Valid: true, String: "5678"},
+						SourceIp:    sql.NullString{Valid: true, String: "203.0.113.7"},
+						RequestID:   sql.NullString{Valid: true, String: "my-request"},
+						Action:      ActionStartScreening,
+						Params:      json.RawMessage(`{"tapeId":42}`),
+						StateBefore: json.RawMessage(`{"version":1}`),
+						StateAfter:  json.RawMessage(`{"version":1}`),
+						Outcome:     OutcomeRejected,
+						Error:       sql.NullString{Valid: true, String: "the desired tape is already being screened"},
+					},
+					{
+						ID:          1,
+						CreatedAt:   time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
+						Actor:       ActorConsumer,
+						Action:      ActionStartBroadcast,
+						Params:      json.RawMessage(`{}`),
+						StateBefore: json.RawMessage(`{"version":0}`),
+						StateAfter:  json.RawMessage(`{"version":1}`),
+						Outcome:     OutcomeOk,
+					},
+				},
+			},
+			http.StatusOK,
+			`{"entries":[{"id":2,"createdAt":"1997-09-01T13:00:00Z","actor":"broadcaster","actorUserId":"5678","sourceIp":"203.0.113.7","requestId":"my-request","action":"start-screening","params":{"tapeId":42},"stateBefore":{"version":1},"stateAfter":{"version":1},"outcome":"rejected","error":"the desired tape is already being screened"},{"id":1,"createdAt":"1997-09-01T12:00:00Z","actor":"twitch-events consumer","action":"start-broadcast","params":{},"stateBefore":{"version":0},"stateAfter":{"version":1},"outcome":"ok"}]}`,
+			queries.GetAuditLogEntriesParams{Limit: 20},
+		},
+		{
+			"pagination parameters are respected",
+			"?n=5&before=12",
+			&mockQueries{},
+			http.StatusOK,
+			`{"entries":[]}`,
+			queries.GetAuditLogEntriesParams{
+				BeforeEntryID: sql.NullInt64{Valid: true, Int64: 12},
+				Limit:         5,
+			},
+		},
+		{
+			"filters are respected",
+			"?actor=broadcaster&action=end-broadcast&outcome=error&since=1997-09-01T12:00:00Z&until=1997-09-02T12:00:00Z",
+			&mockQueries{},
+			http.StatusOK,
+			`{"entries":[]}`,
+			queries.GetAuditLogEntriesParams{
+				Actor:   sql.NullString{Valid: true, String: "broadcaster"},
+				Action:  sql.NullString{Valid: true, String: "end-broadcast"},
+				Outcome: sql.NullString{Valid: true, String: "error"},
+				Since:   sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)},
+				Until:   sql.NullTime{Valid: true, Time: time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC)},
+				Limit:   20,
+			},
+		},
+		{
+			"invalid outcome is a 400",
+			"?outcome=meh",
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'outcome' must be one of 'ok', 'rejected', or 'error'",
+			queries.GetAuditLogEntriesParams{},
+		},
+		{
+			"invalid timestamp is a 400",
+			"?since=yesterday",
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'since' must be an RFC3339 timestamp",
+			queries.GetAuditLogEntriesParams{},
+		},
+		{
+			"database error is a 500",
+			"",
+			&mockQueries{err: fmt.Errorf("db is down")},
+			http.StatusInternalServerError,
+			"db is down",
+			queries.GetAuditLogEntriesParams{Limit: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{q: tt.q}
+			req := httptest.NewRequest(http.MethodGet, "/admin/audit"+tt.query, nil)
+			res := httptest.NewRecorder()
+			s.handleGetEntries(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, readBody(t, res))
+			assert.Equal(t, tt.wantArg, tt.q.entriesArg)
+		})
+	}
+}
+
+type mockQueries struct {
+	err        error
+	entries    []queries.GetAuditLogEntriesRow
+	entriesArg queries.GetAuditLogEntriesParams
+}
+
+func (m *mockQueries) GetAuditLogEntries(ctx context.Context, arg queries.GetAuditLogEntriesParams) ([]queries.GetAuditLogEntriesRow, error) {
+	m.entriesArg = arg
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.entries, nil
+}
+
+func readBody(t *testing.T, res *httptest.ResponseRecorder) string {
+	b, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return strings.TrimSuffix(string(b), "\n")
+}
@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+)
+
+// Entry describes a single attempt to change broadcast state
+type Entry struct {
+	Id          int64           `json:"id"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Actor       string          `json:"actor"`
+	ActorUserId string          `json:"actorUserId,omitempty"`
+	SourceIp    string          `json:"sourceIp,omitempty"`
+	RequestId   string          `json:"requestId,omitempty"`
+	Action      string          `json:"action"`
+	Params      json.RawMessage `json:"params"`
+	StateBefore json.RawMessage `json:"stateBefore"`
+	StateAfter  json.RawMessage `json:"stateAfter"`
+	Outcome     string          `json:"outcome"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Log is the result of listing audit log entries, most recent first
+type Log struct {
+	Entries []Entry `json:"entries"`
+}
+
+// entryFromRow converts a row from the audit log to its JSON representation
+func entryFromRow(row *queries.GetAuditLogEntriesRow) Entry {
+	return Entry{
+		Id:          row.ID,
+		CreatedAt:   row.CreatedAt,
+		Actor:       row.Actor,
+		ActorUserId: row.ActorUserID.String,
+		SourceIp:    row.SourceIp.String,
+		RequestId:   row.RequestID.String,
+		Action:      row.Action,
+		Params:      row.Params,
+		StateBefore: row.StateBefore,
+		StateAfter:  row.StateAfter,
+		Outcome:     row.Outcome,
+		Error:       row.Error.String,
+	}
+}
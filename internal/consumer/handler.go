@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/metrics"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
+	etwitch "github.com/golden-vcr/schemas/twitch-events"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/exp/slog"
+)
+
+// Handler consumes messages from the twitch-events queue, starting and ending
+// broadcasts as the stream goes online and offline
+type Handler struct {
+	logger *slog.Logger
+	w      state.Writer
+}
+
+// NewHandler initializes a Handler that will modify broadcast state via the given
+// writer
+func NewHandler(logger *slog.Logger, w state.Writer) *Handler {
+	return &Handler{
+		logger: logger,
+		w:      w,
+	}
+}
+
+// Run handles each message received from the twitch-events queue, one at a time and
+// strictly in the order in which they were delivered: we only read the next message
+// once the previous one has been fully handled, so a stream that quickly goes offline
+// and back online can't leave us with the wrong broadcast in progress. Run blocks until
+// the context is canceled or the channel is closed, and returns an error only if a
+// message can't be parsed. Any changes in state are attributed to the twitch-events
+// consumer in the audit log.
+func (h *Handler) Run(ctx context.Context, deliveries <-chan amqp.Delivery) error {
+	ctx = audit.WithActor(ctx, audit.Actor{Name: audit.ActorConsumer})
+	for {
+		select {
+		case <-ctx.Done():
+			h.logger.Info("Consumer context canceled; exiting main loop")
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				h.logger.Info("Channel is closed; exiting main loop")
+				return nil
+			}
+			start := time.Now()
+			var ev etwitch.Event
+			if err := json.Unmarshal(d.Body, &ev); err != nil {
+				return err
+			}
+			handleCtx, span := tracing.StartConsume(ctx, "twitch-events", &d)
+			h.handleEvent(handleCtx, &ev)
+			span.End()
+			metrics.ObserveConsumed("twitch-events", d.Timestamp, time.Since(start))
+		}
+	}
+}
+
+// handleEvent updates broadcast state in response to a single twitch-events message
+func (h *Handler) handleEvent(ctx context.Context, ev *etwitch.Event) {
+	switch ev.Type {
+	case etwitch.EventTypeStreamStarted:
+		broadcast, err := h.w.StartBroadcast(ctx)
+		if broadcasts.IsTransitionError(err) {
+			h.logger.Warn("Ignoring stream start that doesn't apply to current broadcast state", "error", err)
+		} else if err != nil {
+			h.logger.Error("Failed to start broadcast", "error", err)
+		} else {
+			h.logger.Info("Started broadcast", "broadcast", broadcast)
+		}
+	case etwitch.EventTypeStreamEnded:
+		broadcast, err := h.w.EndCurrentBroadcast(ctx)
+		if broadcasts.IsTransitionError(err) {
+			h.logger.Warn("Ignoring stream end that doesn't apply to current broadcast state", "error", err)
+		} else if err != nil {
+			h.logger.Error("Failed to end broadcast", "error", err)
+		} else {
+			h.logger.Info("Ended broadcast", "broadcast", broadcast)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/broadcaststest"
+	"github.com/golden-vcr/schemas/core"
+	etwitch "github.com/golden-vcr/schemas/twitch-events"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+func Test_Handler_Run(t *testing.T) {
+	tests := []struct {
+		name           string
+		resumeGrace    time.Duration
+		events         []etwitch.EventType
+		wantState      core.State
+		wantBroadcasts []wantBroadcast
+	}{
+		{
+			"stream goes online",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+			},
+			core.State{BroadcastId: 1},
+			[]wantBroadcast{
+				{id: 1, ended: false},
+			},
+		},
+		{
+			"stream goes online then offline",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+			},
+			core.State{},
+			[]wantBroadcast{
+				{id: 1, ended: true},
+			},
+		},
+		{
+			"stream flaps offline and back online",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+				etwitch.EventTypeStreamStarted,
+			},
+			core.State{BroadcastId: 2},
+			[]wantBroadcast{
+				{id: 2, ended: false},
+				{id: 1, ended: true},
+			},
+		},
+		{
+			"stream flaps repeatedly and finally goes offline",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+			},
+			core.State{},
+			[]wantBroadcast{
+				{id: 3, ended: true},
+				{id: 2, ended: true},
+				{id: 1, ended: true},
+			},
+		},
+		{
+			"stream that flaps within the grace period resumes the same broadcast",
+			10 * time.Minute,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+				etwitch.EventTypeStreamStarted,
+			},
+			core.State{BroadcastId: 1},
+			[]wantBroadcast{
+				{id: 1, ended: false},
+			},
+		},
+		{
+			"repeated stream start is ignored",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamStarted,
+			},
+			core.State{BroadcastId: 1},
+			[]wantBroadcast{
+				{id: 1, ended: false},
+			},
+		},
+		{
+			"repeated stream end is ignored",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeStreamEnded,
+				etwitch.EventTypeStreamEnded,
+			},
+			core.State{},
+			[]wantBroadcast{
+				{id: 1, ended: true},
+			},
+		},
+		{
+			"stream end that arrives before stream start is ignored",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeStreamEnded,
+				etwitch.EventTypeStreamStarted,
+			},
+			core.State{BroadcastId: 1},
+			[]wantBroadcast{
+				{id: 1, ended: false},
+			},
+		},
+		{
+			"unrelated events are ignored",
+			0,
+			[]etwitch.EventType{
+				etwitch.EventTypeViewerFollowed,
+				etwitch.EventTypeStreamStarted,
+				etwitch.EventTypeViewerFollowed,
+			},
+			core.State{BroadcastId: 1},
+			[]wantBroadcast{
+				{id: 1, ended: false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := broadcaststest.NewWriter(tt.resumeGrace)
+			w.SetClock(newClock())
+			h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), w)
+
+			err := h.Run(context.Background(), deliver(t, tt.events...))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantState, w.State())
+			assert.Equal(t, tt.wantBroadcasts, summarize(w))
+		})
+	}
+}
+
+func Test_Handler_Run_writer_error(t *testing.T) {
+	// A failure to modify state should be logged, not treated as fatal: the handler
+	// should carry on with subsequent events
+	w := &failFirstStart{Writer: broadcaststest.NewWriter(0)}
+	w.SetClock(newClock())
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), w)
+
+	err := h.Run(context.Background(), deliver(t,
+		etwitch.EventTypeStreamStarted,
+		etwitch.EventTypeStreamStarted,
+	))
+	assert.NoError(t, err)
+	assert.True(t, w.failed)
+	assert.Equal(t, core.State{BroadcastId: 1}, w.State())
+	assert.Equal(t, []wantBroadcast{{id: 1, ended: false}}, summarize(w.Writer))
+}
+
+func Test_Handler_Run_invalid_message(t *testing.T) {
+	w := broadcaststest.NewWriter(0)
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), w)
+
+	deliveries := make(chan amqp.Delivery, 1)
+	deliveries <- amqp.Delivery{Body: []byte("not-json")}
+	close(deliveries)
+
+	err := h.Run(context.Background(), deliveries)
+	assert.Error(t, err)
+	assert.Equal(t, core.State{}, w.State())
+}
+
+// failFirstStart is a fake writer whose first call to StartBroadcast fails, as if the
+// database were briefly unavailable
+type failFirstStart struct {
+	*broadcaststest.Writer
+	failed bool
+}
+
+func (w *failFirstStart) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	if !w.failed {
+		w.failed = true
+		return nil, fmt.Errorf("db is down")
+	}
+	return w.Writer.StartBroadcast(ctx)
+}
+
+// wantBroadcast summarizes a broadcast recorded by the fake writer
+type wantBroadcast struct {
+	id    int
+	ended bool
+}
+
+// summarize returns the ID of each broadcast recorded by the given writer, most recent
+// first, along with whether it's ended
+func summarize(w *broadcaststest.Writer) []wantBroadcast {
+	var summary []wantBroadcast
+	for _, b := range w.Broadcasts() {
+		summary = append(summary, wantBroadcast{id: b.Id, ended: b.EndedAt != nil})
+	}
+	return summary
+}
+
+// deliver returns a closed channel from which a twitch-events message of each of the
+// given types can be received, in order
+func deliver(t *testing.T, eventTypes ...etwitch.EventType) <-chan amqp.Delivery {
+	deliveries := make(chan amqp.Delivery, len(eventTypes))
+	for _, eventType := range eventTypes {
+		body, err := json.Marshal(etwitch.Event{Type: eventType})
+		assert.NoError(t, err)
+		deliveries <- amqp.Delivery{Body: body}
+	}
+	close(deliveries)
+	return deliveries
+}
+
+// newClock returns a clock that advances by one minute each time it's read, so that
+// each change in state happens at a distinct time
+func newClock() func() time.Time {
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrAmqpConnectionClosed indicates that our connection to the AMQP server has been
+// closed; the client does not reconnect, so the process must be restarted
+var ErrAmqpConnectionClosed = errors.New("AMQP connection is closed")
+
+// ErrTaskNotRunning indicates that a long-running task has exited
+var ErrTaskNotRunning = errors.New("task is not running")
+
+// CheckDB returns a Check that pings the given database
+func CheckDB(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// CheckAmqp returns a Check that verifies that the given AMQP connection is still open
+// and can open channels
+func CheckAmqp(conn *amqp.Connection) Check {
+	return func(ctx context.Context) error {
+		if conn.IsClosed() {
+			return ErrAmqpConnectionClosed
+		}
+		ch, err := conn.Channel()
+		if err != nil {
+			return err
+		}
+		return ch.Close()
+	}
+}
+
+// Task keeps track of whether a long-running function, such as a loop that receives
+// messages from a queue, is still running
+type Task struct {
+	running atomic.Bool
+}
+
+// Go runs f in a new goroutine, marking the task as running until f returns
+func (t *Task) Go(f func()) {
+	t.running.Store(true)
+	go func() {
+		defer t.running.Store(false)
+		f()
+	}()
+}
+
+// Run calls f, marking the task as running until f returns, and returns its result
+func (t *Task) Run(f func() error) error {
+	t.running.Store(true)
+	defer t.running.Store(false)
+	return f()
+}
+
+// Check reports ErrTaskNotRunning unless the task is running
+func (t *Task) Check(ctx context.Context) error {
+	if !t.running.Load() {
+		return ErrTaskNotRunning
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Task(t *testing.T) {
+	t.Run("task is not running until started", func(t *testing.T) {
+		task := &Task{}
+		assert.ErrorIs(t, task.Check(context.Background()), ErrTaskNotRunning)
+	})
+
+	t.Run("task started with Go is running until its function returns", func(t *testing.T) {
+		task := &Task{}
+		release := make(chan struct{})
+		done := make(chan struct{})
+		task.Go(func() {
+			<-release
+			close(done)
+		})
+		assert.NoError(t, task.Check(context.Background()))
+
+		close(release)
+		<-done
+		assert.Eventually(t, func() bool {
+			return task.Check(context.Background()) != nil
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("task started with Run is running until its function returns", func(t *testing.T) {
+		task := &Task{}
+		err := task.Run(func() error {
+			assert.NoError(t, task.Check(context.Background()))
+			return errors.New("loop exited")
+		})
+		assert.EqualError(t, err, "loop exited")
+		assert.ErrorIs(t, task.Check(context.Background()), ErrTaskNotRunning)
+	})
+}
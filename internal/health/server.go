@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// checkTimeout is the maximum amount of time we'll wait for any single check to
+// complete before considering that dependency unhealthy
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a single dependency is healthy, returning an error that
+// describes the problem if not
+type Check func(ctx context.Context) error
+
+// Status is the result of a health check: the overall status is "ok" only if every
+// individual check is ok
+type Status struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckStatus `json:"checks"`
+}
+
+// CheckStatus is the result of a single check
+type CheckStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Server reports the health of this process via GET /healthz and GET /readyz. Liveness
+// checks cover problems that can only be resolved by restarting the process, such as
+// a closed AMQP connection or a recv loop that's exited; they're run by both endpoints.
+// Readiness checks cover dependencies that may recover on their own, such as the
+// database; they're only run by /readyz.
+type Server struct {
+	livenessChecks  map[string]Check
+	readinessChecks map[string]Check
+}
+
+// NewServer initializes a Server with no checks
+func NewServer() *Server {
+	return &Server{
+		livenessChecks:  make(map[string]Check),
+		readinessChecks: make(map[string]Check),
+	}
+}
+
+// AddLivenessCheck registers a check that must pass in order for the process to be
+// considered alive
+func (s *Server) AddLivenessCheck(name string, check Check) {
+	s.livenessChecks[name] = check
+}
+
+// AddReadinessCheck registers a check that must pass in order for the process to be
+// considered ready to handle requests
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.readinessChecks[name] = check
+}
+
+func (s *Server) RegisterRoutes(r *mux.Router) {
+	r.Path("/healthz").Methods("GET").HandlerFunc(s.handleHealthz)
+	r.Path("/readyz").Methods("GET").HandlerFunc(s.handleReadyz)
+}
+
+func (s *Server) handleHealthz(res http.ResponseWriter, req *http.Request) {
+	respond(res, runChecks(req.Context(), s.livenessChecks))
+}
+
+func (s *Server) handleReadyz(res http.ResponseWriter, req *http.Request) {
+	checks := make(map[string]Check, len(s.livenessChecks)+len(s.readinessChecks))
+	for name, check := range s.livenessChecks {
+		checks[name] = check
+	}
+	for name, check := range s.readinessChecks {
+		checks[name] = check
+	}
+	respond(res, runChecks(req.Context(), checks))
+}
+
+// runChecks runs all the given checks concurrently, each with a timeout, and returns
+// the combined result
+func runChecks(ctx context.Context, checks map[string]Check) Status {
+	result := Status{
+		Status: "ok",
+		Checks: make(map[string]CheckStatus, len(checks)),
+	}
+
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			err := check(checkCtx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Status = "unhealthy"
+				result.Checks[name] = CheckStatus{Status: "unhealthy", Error: err.Error()}
+			} else {
+				result.Checks[name] = CheckStatus{Status: "ok"}
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return result
+}
+
+// respond writes the result of our checks, with a 503 status if any check failed
+func respond(res http.ResponseWriter, status Status) {
+	res.Header().Set("content-type", "application/json")
+	if status.Status != "ok" {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(res).Encode(status); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(message string) Check {
+		return func(ctx context.Context) error { return errors.New(message) }
+	}
+
+	tests := []struct {
+		name       string
+		liveness   map[string]Check
+		readiness  map[string]Check
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"healthz is ok if all liveness checks pass",
+			map[string]Check{"amqp": ok},
+			map[string]Check{"postgres": fail("connection refused")},
+			"/healthz",
+			http.StatusOK,
+			`{"status":"ok","checks":{"amqp":{"status":"ok"}}}`,
+		},
+		{
+			"healthz is unhealthy if any liveness check fails",
+			map[string]Check{"amqp": ok, "twitch-events": fail("task is not running")},
+			map[string]Check{"postgres": ok},
+			"/healthz",
+			http.StatusServiceUnavailable,
+			`{"status":"unhealthy","checks":{"amqp":{"status":"ok"},"twitch-events":{"status":"unhealthy","error":"task is not running"}}}`,
+		},
+		{
+			"readyz is ok if all checks pass",
+			map[string]Check{"amqp": ok},
+			map[string]Check{"postgres": ok},
+			"/readyz",
+			http.StatusOK,
+			`{"status":"ok","checks":{"amqp":{"status":"ok"},"postgres":{"status":"ok"}}}`,
+		},
+		{
+			"readyz is unhealthy if a readiness check fails",
+			map[string]Check{"amqp": ok},
+			map[string]Check{"postgres": fail("connection refused")},
+			"/readyz",
+			http.StatusServiceUnavailable,
+			`{"status":"unhealthy","checks":{"amqp":{"status":"ok"},"postgres":{"status":"unhealthy","error":"connection refused"}}}`,
+		},
+		{
+			"readyz is unhealthy if a liveness check fails",
+			map[string]Check{"amqp": fail("AMQP connection is closed")},
+			map[string]Check{"postgres": ok},
+			"/readyz",
+			http.StatusServiceUnavailable,
+			`{"status":"unhealthy","checks":{"amqp":{"status":"unhealthy","error":"AMQP connection is closed"},"postgres":{"status":"ok"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			for name, check := range tt.liveness {
+				s.AddLivenessCheck(name, check)
+			}
+			for name, check := range tt.readiness {
+				s.AddReadinessCheck(name, check)
+			}
+			r := mux.NewRouter()
+			s.RegisterRoutes(r)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, "application/json", res.Header().Get("content-type"))
+			assert.JSONEq(t, tt.wantBody, res.Body.String())
+		})
+	}
+
+	t.Run("slow checks time out", func(t *testing.T) {
+		status := runChecks(context.Background(), map[string]Check{
+			"slow": func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		})
+		assert.Equal(t, "unhealthy", status.Status)
+		assert.Equal(t, CheckStatus{Status: "unhealthy", Error: "context deadline exceeded"}, status.Checks["slow"])
+	})
+}
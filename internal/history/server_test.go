@@ -48,17 +48,19 @@ func Test_handleGetHistory(t *testing.T) {
 								EndedAt:   &screening101EndTime,
 							},
 						},
+						Interruptions: []broadcasts.Interruption{},
 					},
 					{
-						Id:         43,
-						StartedAt:  time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC),
-						EndedAt:    nil,
-						Screenings: []broadcasts.Screening{},
+						Id:            43,
+						StartedAt:     time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC),
+						EndedAt:       nil,
+						Screenings:    []broadcasts.Screening{},
+						Interruptions: []broadcasts.Interruption{},
 					},
 				},
 			},
 			http.StatusOK,
-			`{"broadcasts":[{"id":43,"startedAt":"1997-09-02T12:00:00Z","endedAt":null,"screenings":[]},{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T14:00:00Z","screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":"1997-09-01T12:15:00Z"}]}]}`,
+			`{"broadcasts":[{"id":43,"startedAt":"1997-09-02T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[]},{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T14:00:00Z","screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":"1997-09-01T12:15:00Z"}],"interruptions":[]}]}`,
 		},
 		{
 			"restricted to broadcasts before a certain ID",
@@ -78,17 +80,19 @@ func Test_handleGetHistory(t *testing.T) {
 								EndedAt:   &screening101EndTime,
 							},
 						},
+						Interruptions: []broadcasts.Interruption{},
 					},
 					{
-						Id:         43,
-						StartedAt:  time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC),
-						EndedAt:    nil,
-						Screenings: []broadcasts.Screening{},
+						Id:            43,
+						StartedAt:     time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC),
+						EndedAt:       nil,
+						Screenings:    []broadcasts.Screening{},
+						Interruptions: []broadcasts.Interruption{},
 					},
 				},
 			},
 			http.StatusOK,
-			`{"broadcasts":[{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T14:00:00Z","screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":"1997-09-01T12:15:00Z"}]}]}`,
+			`{"broadcasts":[{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T14:00:00Z","screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":"1997-09-01T12:15:00Z"}],"interruptions":[]}]}`,
 		},
 		{
 			"restricted to only 1 result",
@@ -108,17 +112,19 @@ func Test_handleGetHistory(t *testing.T) {
 								EndedAt:   &screening101EndTime,
 							},
 						},
+						Interruptions: []broadcasts.Interruption{},
 					},
 					{
-						Id:         43,
-						StartedAt:  time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC),
-						EndedAt:    nil,
-						Screenings: []broadcasts.Screening{},
+						Id:            43,
+						StartedAt:     time.Date(1997, 9, 2, 12, 0, 0, 0, time.UTC),
+						EndedAt:       nil,
+						Screenings:    []broadcasts.Screening{},
+						Interruptions: []broadcasts.Interruption{},
 					},
 				},
 			},
 			http.StatusOK,
-			`{"broadcasts":[{"id":43,"startedAt":"1997-09-02T12:00:00Z","endedAt":null,"screenings":[]}]}`,
+			`{"broadcasts":[{"id":43,"startedAt":"1997-09-02T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[]}]}`,
 		},
 		{
 			"range with no data",
@@ -182,11 +188,12 @@ func Test_handleGetHistoryById(t *testing.T) {
 								EndedAt:   &screening101EndTime,
 							},
 						},
+						Interruptions: []broadcasts.Interruption{},
 					},
 				},
 			},
 			http.StatusOK,
-			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T14:00:00Z","screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":"1997-09-01T12:15:00Z"}]}`,
+			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":"1997-09-01T14:00:00Z","screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":"1997-09-01T12:15:00Z"}],"interruptions":[]}`,
 		},
 		{
 			"normal usage: in-progress broadcast",
@@ -205,15 +212,33 @@ func Test_handleGetHistoryById(t *testing.T) {
 								EndedAt:   nil,
 							},
 						},
+						Interruptions: []broadcasts.Interruption{},
 					},
 				},
 			},
 			http.StatusOK,
-			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":null}]}`,
+			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[{"id":"bc5c85f6-fe55-4169-ae06-4b390ac13e80","tapeId":101,"startedAt":"1997-09-01T12:15:00Z","endedAt":null}],"interruptions":[]}`,
 		},
 		{
 			"normal usage: no screenings",
 			"42",
+			&mockQueries{
+				broadcasts: []broadcasts.Broadcast{
+					{
+						Id:            42,
+						StartedAt:     time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
+						EndedAt:       nil,
+						Screenings:    []broadcasts.Screening{},
+						Interruptions: []broadcasts.Interruption{},
+					},
+				},
+			},
+			http.StatusOK,
+			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[]}`,
+		},
+		{
+			"normal usage: resumed broadcast with interruption",
+			"42",
 			&mockQueries{
 				broadcasts: []broadcasts.Broadcast{
 					{
@@ -221,11 +246,17 @@ func Test_handleGetHistoryById(t *testing.T) {
 						StartedAt:  time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
 						EndedAt:    nil,
 						Screenings: []broadcasts.Screening{},
+						Interruptions: []broadcasts.Interruption{
+							{
+								StartedAt: time.Date(1997, 9, 1, 12, 30, 0, 0, time.UTC),
+								EndedAt:   time.Date(1997, 9, 1, 12, 30, 45, 0, time.UTC),
+							},
+						},
 					},
 				},
 			},
 			http.StatusOK,
-			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[]}`,
+			`{"id":42,"startedAt":"1997-09-01T12:00:00Z","endedAt":null,"screenings":[],"interruptions":[{"startedAt":"1997-09-01T12:30:00Z","endedAt":"1997-09-01T12:30:45Z"}]}`,
 		},
 		{
 			"URL parameter must be a valid broadcast ID",
@@ -251,6 +282,7 @@ func Test_handleGetHistoryById(t *testing.T) {
 								EndedAt:   &screening101EndTime,
 							},
 						},
+						Interruptions: []broadcasts.Interruption{},
 					},
 				},
 			},
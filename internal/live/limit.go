@@ -0,0 +1,43 @@
+package live
+
+import (
+	"net/http"
+	"sync"
+)
+
+// subscriberLimiter wraps a handler that serves long-lived connections, refusing new
+// connections with a 503 while the maximum number of connections are already open
+type subscriberLimiter struct {
+	next           http.Handler
+	maxSubscribers int
+
+	numSubscribers int
+	mu             sync.Mutex
+}
+
+func (l *subscriberLimiter) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if !l.acquire() {
+		http.Error(res, "too many subscribers", http.StatusServiceUnavailable)
+		return
+	}
+	defer l.release()
+	l.next.ServeHTTP(res, req)
+}
+
+func (l *subscriberLimiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.numSubscribers >= l.maxSubscribers {
+		return false
+	}
+	l.numSubscribers++
+	return true
+}
+
+func (l *subscriberLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.numSubscribers--
+}
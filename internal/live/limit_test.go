@@ -0,0 +1,53 @@
+package live
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_subscriberLimiter(t *testing.T) {
+	// Prepare a handler that blocks until we tell it to return, so that we can hold
+	// connections open
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	l := &subscriberLimiter{
+		next: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			entered <- struct{}{}
+			<-release
+			res.WriteHeader(http.StatusOK)
+		}),
+		maxSubscribers: 2,
+	}
+	serve := func() *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		l.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/state/stream", nil))
+		return res
+	}
+
+	// Open two connections, which should both be accepted
+	results := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- serve().Code
+		}()
+		<-entered
+	}
+
+	// A third connection should be refused while the first two are still open
+	assert.Equal(t, http.StatusServiceUnavailable, serve().Code)
+
+	// Once a connection closes, another should be accepted in its place
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-results)
+	go func() {
+		results <- serve().Code
+	}()
+	<-entered
+	release <- struct{}{}
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-results)
+	assert.Equal(t, http.StatusOK, <-results)
+}
@@ -0,0 +1,142 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/sse"
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/slog"
+)
+
+type Queries interface {
+	GetCurrentStateEx(ctx context.Context) (broadcasts.State, error)
+	GetStateAtEx(ctx context.Context, at time.Time) (broadcasts.State, error)
+}
+
+type Server struct {
+	ctx    context.Context
+	logger *slog.Logger
+	q      Queries
+	stream http.Handler
+}
+
+// NewServer initializes a Server that reports current broadcast state on request, and
+// that pushes each new state received from the states channel to all clients that are
+// subscribed to the state stream, up to maxSubscribers clients at once
+func NewServer(ctx context.Context, logger *slog.Logger, q *queries.Queries, states <-chan broadcasts.State, maxSubscribers int) *Server {
+	s := &Server{
+		ctx:    ctx,
+		logger: logger,
+		q:      q,
+	}
+	h := sse.NewHandler[broadcasts.State](ctx, states)
+	h.ResolveEventId = func(state broadcasts.State) string {
+		return strconv.FormatInt(state.Version, 10)
+	}
+	h.OnConnect = s.resolveInitialStates
+	s.stream = &subscriberLimiter{
+		next:           h,
+		maxSubscribers: maxSubscribers,
+	}
+	return s
+}
+
+func (s *Server) RegisterRoutes(r *mux.Router) {
+	r.Path("/state").Methods("GET").HandlerFunc(s.handleGetState)
+	r.Path("/state/stream").Methods("GET").Handler(s.stream)
+	r.Path("/state/at").Methods("GET").HandlerFunc(s.handleGetStateAt)
+}
+
+func (s *Server) handleGetState(res http.ResponseWriter, req *http.Request) {
+	// Query the current state of the broadcast, along with its version
+	state, err := s.q.GetCurrentStateEx(req.Context())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The state's version serves as its ETag: if the client already has the current
+	// version, we don't need to send it again
+	etag := fmt.Sprintf(`"%d"`, state.Version)
+	res.Header().Set("etag", etag)
+	res.Header().Set("cache-control", "no-cache")
+	if matchesETag(req.Header.Get("if-none-match"), etag) {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Return the state JSON-serialized
+	if err := json.NewEncoder(res).Encode(state); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleGetStateAt(res http.ResponseWriter, req *http.Request) {
+	// Require a 't' query param indicating the time at which we want to know state
+	tStr := req.URL.Query().Get("t")
+	if tStr == "" {
+		http.Error(res, "'t' query param is required", http.StatusBadRequest)
+		return
+	}
+	at, err := time.Parse(time.RFC3339, tStr)
+	if err != nil {
+		http.Error(res, "'t' must be an RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	// Replay the event log to resolve the state of the broadcast at that time
+	state, err := s.q.GetStateAtEx(req.Context(), at)
+	if errors.Is(err, broadcasts.ErrStateUnknown) {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Return the state JSON-serialized
+	if err := json.NewEncoder(res).Encode(state); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// resolveInitialStates is called when a client connects to the state stream, in order
+// to determine what state it should be sent immediately. A client that's reconnecting
+// will supply the version of the last state it received as its Last-Event-ID: if that
+// state is still current, we don't need to send it again.
+func (s *Server) resolveInitialStates(lastEventId string) []broadcasts.State {
+	state, err := s.q.GetCurrentStateEx(s.ctx)
+	if err != nil {
+		s.logger.Error("Failed to get broadcast state for new subscriber", "error", err)
+		return nil
+	}
+	if lastEventId != "" {
+		lastVersion, err := strconv.ParseInt(lastEventId, 10, 64)
+		if err == nil && lastVersion >= state.Version {
+			return nil
+		}
+	}
+	return []broadcasts.State{state}
+}
+
+// matchesETag returns true if the value of an If-None-Match header indicates that the
+// client already has the representation identified by etag
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,353 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+func Test_handleGetState(t *testing.T) {
+	broadcastStartedAt := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	screeningStartedAt := time.Date(1997, 9, 1, 12, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"no broadcast in progress",
+			&mockQueries{
+				state: broadcasts.State{
+					Version: 41,
+				},
+			},
+			http.StatusOK,
+			`{"broadcast_id":0,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":41,"broadcast_started_at":null,"screening_started_at":null}`,
+		},
+		{
+			"broadcast in progress with no screening",
+			&mockQueries{
+				state: broadcasts.State{
+					State: core.State{
+						BroadcastId: 12,
+					},
+					Version:            42,
+					BroadcastStartedAt: &broadcastStartedAt,
+				},
+			},
+			http.StatusOK,
+			`{"broadcast_id":12,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":42,"broadcast_started_at":"1997-09-01T12:00:00Z","screening_started_at":null}`,
+		},
+		{
+			"broadcast in progress with screening",
+			&mockQueries{
+				state: broadcasts.State{
+					State: core.State{
+						BroadcastId: 12,
+						ScreeningId: uuid.MustParse("df0d9c53-8a7a-4788-9d20-dc718cf4a7b3"),
+						TapeId:      50,
+					},
+					Version:            43,
+					BroadcastStartedAt: &broadcastStartedAt,
+					ScreeningStartedAt: &screeningStartedAt,
+				},
+			},
+			http.StatusOK,
+			`{"broadcast_id":12,"screening_id":"df0d9c53-8a7a-4788-9d20-dc718cf4a7b3","tape_id":50,"version":43,"broadcast_started_at":"1997-09-01T12:00:00Z","screening_started_at":"1997-09-01T12:15:00Z"}`,
+		},
+		{
+			"database error is a 500",
+			&mockQueries{
+				err: fmt.Errorf("oh no"),
+			},
+			http.StatusInternalServerError,
+			"oh no",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				q: tt.q,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/state", nil)
+			res := httptest.NewRecorder()
+			s.handleGetState(res, req)
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+}
+
+func Test_handleGetState_conditional(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			"unconditional request gets current state",
+			"",
+			http.StatusOK,
+			`{"broadcast_id":0,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":42,"broadcast_started_at":null,"screening_started_at":null}`,
+		},
+		{
+			"request with stale ETag gets current state",
+			`"41"`,
+			http.StatusOK,
+			`{"broadcast_id":0,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":42,"broadcast_started_at":null,"screening_started_at":null}`,
+		},
+		{
+			"request with current ETag is not modified",
+			`"42"`,
+			http.StatusNotModified,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				q: &mockQueries{
+					state: broadcasts.State{Version: 42},
+				},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/state", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("if-none-match", tt.ifNoneMatch)
+			}
+			res := httptest.NewRecorder()
+			s.handleGetState(res, req)
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, `"42"`, res.Header().Get("etag"))
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+}
+
+func Test_matchesETag(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{``, false},
+		{`"42"`, true},
+		{`"41"`, false},
+		{`W/"42"`, true},
+		{`"40", "42"`, true},
+		{`"40", "41"`, false},
+		{`*`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ifNoneMatch, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchesETag(tt.ifNoneMatch, `"42"`))
+		})
+	}
+}
+
+func Test_Server_resolveInitialStates(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventId string
+		q           *mockQueries
+		wantStates  []broadcasts.State
+	}{
+		{
+			"new client is sent current state",
+			"",
+			&mockQueries{
+				state: broadcasts.State{Version: 42},
+			},
+			[]broadcasts.State{{Version: 42}},
+		},
+		{
+			"reconnecting client is sent current state if it's missed a change",
+			"41",
+			&mockQueries{
+				state: broadcasts.State{Version: 42},
+			},
+			[]broadcasts.State{{Version: 42}},
+		},
+		{
+			"reconnecting client is not sent current state if it's already up to date",
+			"42",
+			&mockQueries{
+				state: broadcasts.State{Version: 42},
+			},
+			nil,
+		},
+		{
+			"invalid Last-Event-ID is ignored",
+			"bad-id",
+			&mockQueries{
+				state: broadcasts.State{Version: 42},
+			},
+			[]broadcasts.State{{Version: 42}},
+		},
+		{
+			"database error results in no initial state",
+			"",
+			&mockQueries{
+				err: fmt.Errorf("oh no"),
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				ctx:    context.Background(),
+				logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+				q:      tt.q,
+			}
+			got := s.resolveInitialStates(tt.lastEventId)
+			assert.Equal(t, tt.wantStates, got)
+		})
+	}
+}
+
+func Test_handleGetStateAt(t *testing.T) {
+	broadcastStartedAt := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		tStr       string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+		wantAt     time.Time
+	}{
+		{
+			"normal usage",
+			"1997-09-01T12:30:00Z",
+			&mockQueries{
+				state: broadcasts.State{
+					State: core.State{
+						BroadcastId: 12,
+					},
+					Version:            42,
+					BroadcastStartedAt: &broadcastStartedAt,
+				},
+			},
+			http.StatusOK,
+			`{"broadcast_id":12,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":42,"broadcast_started_at":"1997-09-01T12:00:00Z","screening_started_at":null}`,
+			time.Date(1997, 9, 1, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			"time with offset is accepted",
+			"1997-09-01T08:30:00-04:00",
+			&mockQueries{},
+			http.StatusOK,
+			`{"broadcast_id":0,"screening_id":"00000000-0000-0000-0000-000000000000","tape_id":0,"version":0,"broadcast_started_at":null,"screening_started_at":null}`,
+			time.Date(1997, 9, 1, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			"time is required",
+			"",
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'t' query param is required",
+			time.Time{},
+		},
+		{
+			"time must be RFC3339",
+			"yesterday",
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'t' must be an RFC3339 timestamp",
+			time.Time{},
+		},
+		{
+			"time before the event log began is a 404",
+			"1997-09-01T12:30:00Z",
+			&mockQueries{
+				err: broadcasts.ErrStateUnknown,
+			},
+			http.StatusNotFound,
+			"broadcast state is unknown before the event log began",
+			time.Date(1997, 9, 1, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			"database error is a 500",
+			"1997-09-01T12:30:00Z",
+			&mockQueries{
+				err: fmt.Errorf("oh no"),
+			},
+			http.StatusInternalServerError,
+			"oh no",
+			time.Date(1997, 9, 1, 12, 30, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				q: tt.q,
+			}
+			req := httptest.NewRequest(http.MethodGet, "/state/at?t="+url.QueryEscape(tt.tStr), nil)
+			res := httptest.NewRecorder()
+			s.handleGetStateAt(res, req)
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			body := strings.TrimSuffix(string(b), "\n")
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, body)
+			assert.True(t, tt.wantAt.Equal(tt.q.at))
+		})
+	}
+}
+
+type mockQueries struct {
+	err   error
+	state broadcasts.State
+	at    time.Time
+	mu    sync.Mutex
+}
+
+func (m *mockQueries) GetCurrentStateEx(ctx context.Context) (broadcasts.State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return broadcasts.State{}, m.err
+	}
+	return m.state, nil
+}
+
+func (m *mockQueries) GetStateAtEx(ctx context.Context, at time.Time) (broadcasts.State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.at = at
+	if m.err != nil {
+		return broadcasts.State{}, m.err
+	}
+	return m.state, nil
+}
+
+func (m *mockQueries) setVersion(version int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.state.Version = version
+}
@@ -0,0 +1,91 @@
+package live
+
+import (
+	"context"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/internal/metrics"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/exp/slog"
+)
+
+// Watcher keeps track of changes to broadcast state as they're announced via the
+// broadcast-events queue, resolving the new state from the database each time so that
+// subscribers are always given complete, authoritative state
+type Watcher struct {
+	logger      *slog.Logger
+	q           Queries
+	lastVersion int64
+}
+
+// NewWatcher initializes a Watcher that will query current broadcast state using q
+func NewWatcher(logger *slog.Logger, q Queries) *Watcher {
+	return &Watcher{
+		logger: logger,
+		q:      q,
+	}
+}
+
+// Run blocks until the given context is canceled or the deliveries channel is closed,
+// sending the new broadcast state to each of the given channels whenever a message from
+// broadcast-events indicates that state has changed. Because the relay may deliver an
+// event more than once, and because several events may arrive before we query the
+// database, a state is only sent if its version is newer than that of the last state
+// sent.
+func (w *Watcher) Run(ctx context.Context, deliveries <-chan amqp.Delivery, chs ...chan<- broadcasts.State) {
+	// Resolve the version of our starting state, so that we don't announce a change
+	// until the state has actually changed
+	if state, err := w.q.GetCurrentStateEx(ctx); err != nil {
+		w.logger.Error("Failed to get initial broadcast state", "error", err)
+	} else {
+		w.lastVersion = state.Version
+		metrics.SetState(&state)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Context canceled; broadcast state watcher shutting down")
+			return
+		case d, ok := <-deliveries:
+			if !ok {
+				w.logger.Info("Channel is closed; broadcast state watcher shutting down")
+				return
+			}
+			start := time.Now()
+			pollCtx, span := tracing.StartConsume(ctx, "broadcast-events", &d)
+			state, changed, err := w.poll(pollCtx)
+			tracing.End(span, err)
+			metrics.ObserveConsumed("broadcast-events", d.Timestamp, time.Since(start))
+			if err != nil {
+				w.logger.Error("Failed to get broadcast state", "error", err)
+				continue
+			}
+			if changed {
+				metrics.SetState(state)
+				for _, ch := range chs {
+					select {
+					case ch <- *state:
+					case <-ctx.Done():
+					}
+				}
+			}
+		}
+	}
+}
+
+// poll queries the current state, returning it along with a flag that indicates
+// whether it's newer than the last state we've seen
+func (w *Watcher) poll(ctx context.Context) (*broadcasts.State, bool, error) {
+	state, err := w.q.GetCurrentStateEx(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if state.Version <= w.lastVersion {
+		return nil, false, nil
+	}
+	w.lastVersion = state.Version
+	return &state, true, nil
+}
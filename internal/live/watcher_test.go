@@ -0,0 +1,61 @@
+package live
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+func Test_Watcher_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := &mockQueries{
+		state: broadcasts.State{Version: 10},
+	}
+	w := NewWatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), q)
+	deliveries := make(chan amqp.Delivery)
+	states := make(chan broadcasts.State, 8)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, deliveries, states)
+		close(done)
+	}()
+
+	// A message that doesn't coincide with a new version of the state should not
+	// result in any change being announced
+	deliveries <- amqp.Delivery{}
+
+	// Once state has changed, we should announce the new state exactly once, even if
+	// the event that announces it is delivered more than once
+	q.setVersion(11)
+	deliveries <- amqp.Delivery{}
+	deliveries <- amqp.Delivery{}
+
+	// If several changes have occurred by the time we receive an event, we should jump
+	// straight to the latest state, and subsequent events should be ignored
+	q.setVersion(13)
+	deliveries <- amqp.Delivery{}
+	deliveries <- amqp.Delivery{}
+
+	// Closing the channel should shut down the watcher
+	close(deliveries)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not shut down")
+	}
+	close(states)
+
+	versions := make([]int64, 0)
+	for state := range states {
+		versions = append(versions, state.Version)
+	}
+	assert.Equal(t, []int64{11, 13}, versions)
+}
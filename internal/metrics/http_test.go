@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Middleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware)
+	r.Path("/history/{id}").Methods("GET").HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if mux.Vars(req)["id"] == "0" {
+			http.Error(res, "no such broadcast", http.StatusNotFound)
+			return
+		}
+		res.Write([]byte("{}"))
+	})
+	r.Path("/stream").Methods("GET").HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		_, ok := res.(http.Flusher)
+		assert.True(t, ok)
+		_, ok = res.(http.Hijacker)
+		assert.True(t, ok)
+		res.WriteHeader(http.StatusNoContent)
+	})
+
+	okCount := httpRequestsTotal.WithLabelValues("/history/{id}", "GET", "200")
+	notFoundCount := httpRequestsTotal.WithLabelValues("/history/{id}", "GET", "404")
+	streamCount := httpRequestsTotal.WithLabelValues("/stream", "GET", "204")
+	okBefore := testutil.ToFloat64(okCount)
+	notFoundBefore := testutil.ToFloat64(notFoundCount)
+	streamBefore := testutil.ToFloat64(streamCount)
+
+	for _, path := range []string{"/history/41", "/history/42", "/history/0", "/stream"} {
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests should be counted by route template rather than by concrete path, and
+	// the status code written by the handler should be recorded
+	assert.Equal(t, okBefore+2, testutil.ToFloat64(okCount))
+	assert.Equal(t, notFoundBefore+1, testutil.ToFloat64(notFoundCount))
+	assert.Equal(t, streamBefore+1, testutil.ToFloat64(streamCount))
+}
@@ -0,0 +1,131 @@
+// Package metrics defines the Prometheus metrics reported by the broadcasts server and
+// consumer, along with helpers that record them. All metrics are registered with the
+// default Prometheus registry and served by Handler.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "broadcasts"
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests handled, by route, method, and response status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by route and method. Streaming routes report the lifetime of each connection.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	writerOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "writer",
+		Name:      "operations_total",
+		Help:      "Number of attempts to modify broadcast state, by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	writerOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "writer",
+		Name:      "operation_duration_seconds",
+		Help:      "Time taken to modify broadcast state, by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	amqpPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "amqp",
+		Name:      "published_total",
+		Help:      "Number of messages successfully published, by exchange.",
+	}, []string{"exchange"})
+
+	amqpPublishFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "amqp",
+		Name:      "publish_failures_total",
+		Help:      "Number of failed attempts to publish a message, by exchange.",
+	}, []string{"exchange"})
+
+	consumerProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "processing_duration_seconds",
+		Help:      "Time taken to handle each message consumed, by exchange.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"exchange"})
+
+	consumerLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "lag_seconds",
+		Help:      "Time between each message being published and being handled, by exchange. Only observed for messages that carry a timestamp.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"exchange"})
+
+	consumerLastProcessed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "last_processed_timestamp_seconds",
+		Help:      "Unix time at which a message was most recently handled, by exchange.",
+	}, []string{"exchange"})
+)
+
+// The state gauges are only registered once SetState is first called, so that they're
+// not reported (with meaningless zero values) by processes that don't track state
+var (
+	liveBroadcastId = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "live_broadcast_id",
+		Help:      "ID of the broadcast that's currently in progress, or 0 if none.",
+	})
+
+	currentTapeId = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "current_tape_id",
+		Help:      "ID of the tape that's currently being screened, or 0 if none.",
+	})
+
+	registerStateGauges sync.Once
+)
+
+// Handler serves all registered metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveConsumed records that a message from the given exchange was handled, taking
+// the given amount of time. If publishedAt is nonzero, the time elapsed since then is
+// recorded as consumer lag.
+func ObserveConsumed(exchange string, publishedAt time.Time, duration time.Duration) {
+	now := time.Now()
+	consumerProcessingDuration.WithLabelValues(exchange).Observe(duration.Seconds())
+	consumerLastProcessed.WithLabelValues(exchange).Set(float64(now.Unix()))
+	if !publishedAt.IsZero() {
+		consumerLag.WithLabelValues(exchange).Observe(now.Sub(publishedAt).Seconds())
+	}
+}
+
+// SetState updates the gauges that report current broadcast state, registering them
+// if this is the first call
+func SetState(state *broadcasts.State) {
+	registerStateGauges.Do(func() {
+		prometheus.MustRegister(liveBroadcastId, currentTapeId)
+	})
+	liveBroadcastId.Set(float64(state.BroadcastId))
+	currentTapeId.Set(float64(state.TapeId))
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ObserveConsumed(t *testing.T) {
+	// Lag is only observed for messages that carry a timestamp
+	before := testutil.CollectAndCount(consumerLag)
+	ObserveConsumed("test-events", time.Time{}, time.Millisecond)
+	assert.Equal(t, before, testutil.CollectAndCount(consumerLag))
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(consumerLastProcessed.WithLabelValues("test-events")), 1)
+
+	ObserveConsumed("test-events", time.Now().Add(-time.Second), time.Millisecond)
+	assert.Equal(t, before+1, testutil.CollectAndCount(consumerLag))
+}
+
+func Test_SetState(t *testing.T) {
+	// State gauges should not be reported until state is first set
+	assert.False(t, isRegistered(t, "broadcasts_live_broadcast_id"))
+	assert.False(t, isRegistered(t, "broadcasts_current_tape_id"))
+
+	SetState(&broadcasts.State{State: core.State{BroadcastId: 42, TapeId: 50}})
+	assert.Equal(t, float64(42), testutil.ToFloat64(liveBroadcastId))
+	assert.Equal(t, float64(50), testutil.ToFloat64(currentTapeId))
+
+	assert.True(t, isRegistered(t, "broadcasts_live_broadcast_id"))
+	assert.True(t, isRegistered(t, "broadcasts_current_tape_id"))
+
+	SetState(&broadcasts.State{})
+	assert.Equal(t, float64(0), testutil.ToFloat64(liveBroadcastId))
+	assert.Equal(t, float64(0), testutil.ToFloat64(currentTapeId))
+}
+
+// isRegistered returns true if a metric with the given name is reported by the default
+// registry
+func isRegistered(t *testing.T, name string) bool {
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
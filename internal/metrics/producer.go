@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/golden-vcr/server-common/rmq"
+)
+
+// InstrumentProducer wraps an rmq.Producer so that each successful or failed attempt to
+// publish to the given exchange is counted
+func InstrumentProducer(p rmq.Producer, exchange string) rmq.Producer {
+	return &instrumentedProducer{
+		p:        p,
+		exchange: exchange,
+	}
+}
+
+type instrumentedProducer struct {
+	p        rmq.Producer
+	exchange string
+}
+
+func (i *instrumentedProducer) Send(ctx context.Context, jsonData []byte) error {
+	if err := i.p.Send(ctx, jsonData); err != nil {
+		amqpPublishFailuresTotal.WithLabelValues(i.exchange).Inc()
+		return err
+	}
+	amqpPublishedTotal.WithLabelValues(i.exchange).Inc()
+	return nil
+}
+
+var _ rmq.Producer = (*instrumentedProducer)(nil)
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InstrumentProducer(t *testing.T) {
+	p := &mockProducer{}
+	ip := InstrumentProducer(p, "test-exchange")
+
+	published := amqpPublishedTotal.WithLabelValues("test-exchange")
+	failures := amqpPublishFailuresTotal.WithLabelValues("test-exchange")
+	publishedBefore := testutil.ToFloat64(published)
+	failuresBefore := testutil.ToFloat64(failures)
+
+	err := ip.Send(context.Background(), []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, publishedBefore+1, testutil.ToFloat64(published))
+	assert.Equal(t, failuresBefore, testutil.ToFloat64(failures))
+
+	p.err = fmt.Errorf("channel closed")
+	err = ip.Send(context.Background(), []byte(`{}`))
+	assert.EqualError(t, err, "channel closed")
+	assert.Equal(t, publishedBefore+1, testutil.ToFloat64(published))
+	assert.Equal(t, failuresBefore+1, testutil.ToFloat64(failures))
+}
+
+type mockProducer struct {
+	err error
+}
+
+func (m *mockProducer) Send(ctx context.Context, jsonData []byte) error {
+	return m.err
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/internal/state"
+)
+
+// outcomes maps each error that a state.Writer may return to signal that a change in
+// state isn't allowed to the outcome label under which it's counted
+var outcomes = []struct {
+	err   error
+	label string
+}{
+	{broadcasts.ErrBroadcastInProgress, "broadcast_in_progress"},
+	{broadcasts.ErrNoBroadcastInProgress, "no_broadcast_in_progress"},
+	{broadcasts.ErrScreeningInProgress, "screening_in_progress"},
+	{broadcasts.ErrNoScreeningInProgress, "no_screening_in_progress"},
+	{broadcasts.ErrNoSuchBroadcast, "no_such_broadcast"},
+	{broadcasts.ErrBroadcastNotResumable, "broadcast_not_resumable"},
+	{broadcasts.ErrInvalidTransition, "invalid_transition"},
+}
+
+// InstrumentWriter wraps a state.Writer so that the outcome and duration of each
+// operation is recorded
+func InstrumentWriter(w state.Writer) state.Writer {
+	return &instrumentedWriter{w: w}
+}
+
+type instrumentedWriter struct {
+	w state.Writer
+}
+
+func (i *instrumentedWriter) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	defer observeWriterOperation("start_broadcast", time.Now())
+	broadcast, err := i.w.StartBroadcast(ctx)
+	countWriterOperation("start_broadcast", err)
+	return broadcast, err
+}
+
+func (i *instrumentedWriter) EndCurrentBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	defer observeWriterOperation("end_broadcast", time.Now())
+	broadcast, err := i.w.EndCurrentBroadcast(ctx)
+	countWriterOperation("end_broadcast", err)
+	return broadcast, err
+}
+
+func (i *instrumentedWriter) ResumeBroadcast(ctx context.Context, broadcastId int) (*broadcasts.Broadcast, error) {
+	defer observeWriterOperation("resume_broadcast", time.Now())
+	broadcast, err := i.w.ResumeBroadcast(ctx, broadcastId)
+	countWriterOperation("resume_broadcast", err)
+	return broadcast, err
+}
+
+func (i *instrumentedWriter) EndStaleBroadcast(ctx context.Context, broadcastId int, endedAt time.Time) error {
+	defer observeWriterOperation("end_stale_broadcast", time.Now())
+	err := i.w.EndStaleBroadcast(ctx, broadcastId, endedAt)
+	countWriterOperation("end_stale_broadcast", err)
+	return err
+}
+
+func (i *instrumentedWriter) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
+	defer observeWriterOperation("start_screening", time.Now())
+	screening, err := i.w.StartScreening(ctx, tapeId)
+	countWriterOperation("start_screening", err)
+	return screening, err
+}
+
+func (i *instrumentedWriter) EndCurrentScreening(ctx context.Context) error {
+	defer observeWriterOperation("end_screening", time.Now())
+	err := i.w.EndCurrentScreening(ctx)
+	countWriterOperation("end_screening", err)
+	return err
+}
+
+// countWriterOperation increments the count of operations with the outcome indicated
+// by err
+func countWriterOperation(operation string, err error) {
+	writerOperationsTotal.WithLabelValues(operation, writerOutcome(err)).Inc()
+}
+
+// observeWriterOperation records the time elapsed since an operation started
+func observeWriterOperation(operation string, start time.Time) {
+	writerOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// writerOutcome returns the outcome label for an operation that returned err: "ok" if
+// it succeeded, a label identifying the sentinel error if it was rejected, or "error"
+// if it failed for any other reason
+func writerOutcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, o := range outcomes {
+		if errors.Is(err, o.err) {
+			return o.label
+		}
+	}
+	return "error"
+}
+
+var _ state.Writer = (*instrumentedWriter)(nil)
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/broadcaststest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InstrumentWriter(t *testing.T) {
+	w := broadcaststest.NewWriter(0)
+	iw := InstrumentWriter(w)
+
+	okCount := writerOperationsTotal.WithLabelValues("start_broadcast", "ok")
+	inProgressCount := writerOperationsTotal.WithLabelValues("start_broadcast", "broadcast_in_progress")
+	errorCount := writerOperationsTotal.WithLabelValues("end_screening", "error")
+	okBefore := testutil.ToFloat64(okCount)
+	inProgressBefore := testutil.ToFloat64(inProgressCount)
+	errorBefore := testutil.ToFloat64(errorCount)
+
+	// A successful call should be counted as ok, and its result passed through
+	broadcast, err := iw.StartBroadcast(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, broadcast.Id)
+	assert.Equal(t, okBefore+1, testutil.ToFloat64(okCount))
+
+	// A call that's rejected due to current state should be counted by sentinel
+	_, err = iw.StartBroadcast(context.Background())
+	assert.ErrorIs(t, err, broadcasts.ErrBroadcastInProgress)
+	assert.Equal(t, inProgressBefore+1, testutil.ToFloat64(inProgressCount))
+
+	// Any other failure should be counted as an error
+	w.SetError(fmt.Errorf("db is down"))
+	err = iw.EndCurrentScreening(context.Background())
+	assert.EqualError(t, err, "db is down")
+	assert.Equal(t, errorBefore+1, testutil.ToFloat64(errorCount))
+}
+
+func Test_writerOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "ok"},
+		{broadcasts.ErrBroadcastInProgress, "broadcast_in_progress"},
+		{broadcasts.ErrNoBroadcastInProgress, "no_broadcast_in_progress"},
+		{broadcasts.ErrScreeningInProgress, "screening_in_progress"},
+		{broadcasts.ErrNoScreeningInProgress, "no_screening_in_progress"},
+		{broadcasts.ErrNoSuchBroadcast, "no_such_broadcast"},
+		{broadcasts.ErrBroadcastNotResumable, "broadcast_not_resumable"},
+		{broadcasts.ErrInvalidTransition, "invalid_transition"},
+		{fmt.Errorf("failed to start: %w", broadcasts.ErrBroadcastInProgress), "broadcast_in_progress"},
+		{fmt.Errorf("db is down"), "error"},
+		{context.DeadlineExceeded, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, writerOutcome(tt.err))
+		})
+	}
+}
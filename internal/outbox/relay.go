@@ -0,0 +1,176 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
+	"github.com/golden-vcr/server-common/rmq"
+	"golang.org/x/exp/slog"
+)
+
+// batchSize is the maximum number of pending events that we'll relay in a single
+// transaction
+const batchSize = 100
+
+// pollInterval is how frequently we'll check for pending events in the absence of any
+// notifications: this ensures that events recorded by other processes (or left over
+// from a crash) are eventually relayed
+const pollInterval = 5 * time.Second
+
+// Notifier is notified whenever a transaction that's recorded one or more events to the
+// outbox has been committed, so that those events can be relayed without delay
+type Notifier interface {
+	Notify()
+}
+
+// Notifiers is a Notifier that notifies each of several Notifiers in turn, so that
+// multiple processes that read from the outbox can all be woken by the same commit
+type Notifiers []Notifier
+
+// Notify notifies each Notifier; it never blocks so long as they don't
+func (n Notifiers) Notify() {
+	for _, notifier := range n {
+		notifier.Notify()
+	}
+}
+
+// Queries is the subset of queries required to relay events from the outbox
+type Queries interface {
+	TryLockOutboxRelay(ctx context.Context) (bool, error)
+	GetPendingOutboxEvents(ctx context.Context, limit int32) ([]queries.GetPendingOutboxEventsRow, error)
+	MarkOutboxEventSent(ctx context.Context, outboxEventID int64) (sql.Result, error)
+}
+
+// Relay sends pending events from the broadcasts.outbox table to the broadcast-events
+// queue, marking each event as sent once it's been successfully delivered. If a send
+// fails, the event remains pending and will be retried, so delivery is at-least-once.
+//
+// Several processes may each run a Relay against the same outbox, but only one of them
+// relays events at any given time, so that events are always sent in order.
+type Relay struct {
+	logger   *slog.Logger
+	db       *sql.DB
+	producer rmq.Producer
+	notifyCh chan struct{}
+}
+
+// NewRelay initializes a Relay that will read pending events from the given database
+// and send them via the given producer, once Run is called
+func NewRelay(logger *slog.Logger, db *sql.DB, producer rmq.Producer) *Relay {
+	return &Relay{
+		logger:   logger,
+		db:       db,
+		producer: producer,
+		notifyCh: make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the relay so that it will immediately check for pending events; it never
+// blocks
+func (r *Relay) Notify() {
+	select {
+	case r.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run blocks until the given context is canceled, relaying events whenever notified
+// and at a regular interval
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	r.flush(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Context canceled; outbox relay shutting down")
+			return
+		case <-r.notifyCh:
+			r.flush(ctx)
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// flush relays batches of pending events until none remain or an error occurs
+func (r *Relay) flush(ctx context.Context) {
+	for {
+		numSent, err := r.relayBatch(ctx)
+		if err != nil {
+			r.logger.Error("Failed to relay events from outbox", "error", err)
+			return
+		}
+		if numSent < batchSize {
+			return
+		}
+	}
+}
+
+// relayBatch opens a transaction in which a batch of pending events is locked, sent,
+// and marked as sent, returning the number of events that were successfully sent
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	numSent, sendErr := relayEvents(ctx, queries.New(tracing.WrapDB(tx)), r.producer)
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return numSent, sendErr
+}
+
+// relayEvents sends each pending event in order, marking it as sent as it goes. If an
+// event can't be sent, it stops there, so that events are never delivered out of order.
+// Each event is sent in the context of the trace in which it was recorded.
+//
+// If another relay is already sending events, relayEvents sends nothing: otherwise,
+// with each relay sending a different batch, later events could overtake earlier ones.
+// The relay that holds the lock will keep going until no events remain, and any events
+// it misses will be picked up the next time a relay polls the outbox.
+func relayEvents(ctx context.Context, q Queries, producer rmq.Producer) (int, error) {
+	acquired, err := q.TryLockOutboxRelay(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !acquired {
+		return 0, nil
+	}
+
+	rows, err := q.GetPendingOutboxEvents(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	numSent := 0
+	for _, row := range rows {
+		sendCtx := tracing.UnmarshalTraceContext(ctx, row.TraceContext)
+		if err := producer.Send(sendCtx, row.Payload); err != nil {
+			return numSent, fmt.Errorf("failed to send outbox event %d: %w", row.ID, err)
+		}
+		result, err := q.MarkOutboxEventSent(ctx, row.ID)
+		if err != nil {
+			return numSent, err
+		}
+		numRowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return numSent, err
+		}
+		if numRowsAffected != int64(1) {
+			return numSent, fmt.Errorf("failed to mark outbox event %d as sent: expected to affect 1 rows; instead affected %d", row.ID, numRowsAffected)
+		}
+		numSent++
+	}
+	return numSent, nil
+}
+
+var _ Notifier = (*Relay)(nil)
+var _ Notifier = Notifiers(nil)
@@ -0,0 +1,231 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_relayEvents(t *testing.T) {
+	tests := []struct {
+		name         string
+		q            *mockQueries
+		p            *mockProducer
+		wantNumSent  int
+		wantErr      string
+		wantSent     []string
+		wantMarkedAs []int64
+	}{
+		{
+			"no pending events",
+			&mockQueries{},
+			&mockProducer{},
+			0,
+			"",
+			nil,
+			nil,
+		},
+		{
+			"all pending events are sent in order",
+			&mockQueries{
+				pending: []queries.GetPendingOutboxEventsRow{
+					{ID: 4, Payload: json.RawMessage(`{"n":4}`)},
+					{ID: 5, Payload: json.RawMessage(`{"n":5}`)},
+				},
+			},
+			&mockProducer{},
+			2,
+			"",
+			[]string{`{"n":4}`, `{"n":5}`},
+			[]int64{4, 5},
+		},
+		{
+			"failure to send stops relaying at the failed event",
+			&mockQueries{
+				pending: []queries.GetPendingOutboxEventsRow{
+					{ID: 4, Payload: json.RawMessage(`{"n":4}`)},
+					{ID: 5, Payload: json.RawMessage(`{"n":5}`)},
+					{ID: 6, Payload: json.RawMessage(`{"n":6}`)},
+				},
+			},
+			&mockProducer{
+				failOn: `{"n":5}`,
+			},
+			1,
+			"failed to send outbox event 5: oh no",
+			[]string{`{"n":4}`},
+			[]int64{4},
+		},
+		{
+			"failure to query pending events is an error",
+			&mockQueries{
+				err: fmt.Errorf("db is down"),
+			},
+			&mockProducer{},
+			0,
+			"db is down",
+			nil,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			numSent, err := relayEvents(context.Background(), tt.q, tt.p)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantNumSent, numSent)
+			assert.Equal(t, tt.wantSent, tt.p.sent)
+			assert.Equal(t, tt.wantMarkedAs, tt.q.marked)
+		})
+	}
+}
+
+func Test_relayEvents_traceContext(t *testing.T) {
+	// Each event should be sent in the context of the trace in which it was recorded,
+	// so that the producer can propagate it to consumers
+	q := &mockQueries{
+		pending: []queries.GetPendingOutboxEventsRow{
+			{ID: 1, Payload: json.RawMessage(`{"n":1}`), TraceContext: json.RawMessage(`{"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}`)},
+			{ID: 2, Payload: json.RawMessage(`{"n":2}`), TraceContext: json.RawMessage(`{}`)},
+		},
+	}
+	p := &mockProducer{}
+	numSent, err := relayEvents(context.Background(), q, p)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numSent)
+	assert.Equal(t, []string{"4bf92f3577b34da6a3ce929d0e0e4736", ""}, p.traceIds)
+}
+
+func Test_relayEvents_concurrentRelays(t *testing.T) {
+	// Two relays are reading from the same outbox: while the first is sending events,
+	// the second should be locked out rather than sending later events ahead of them
+	lock := &mockLock{}
+	pending := []queries.GetPendingOutboxEventsRow{
+		{ID: 1, Payload: json.RawMessage(`{"n":1}`)},
+		{ID: 2, Payload: json.RawMessage(`{"n":2}`)},
+		{ID: 3, Payload: json.RawMessage(`{"n":3}`)},
+	}
+	q1 := &mockQueries{lock: lock, pending: pending}
+	q2 := &mockQueries{lock: lock, pending: pending[1:]}
+	p := &mockProducer{}
+
+	// Run the second relay from within the first relay's first send
+	var secondNumSent int
+	var secondErr error
+	p.onSend = func() {
+		p.onSend = nil
+		secondNumSent, secondErr = relayEvents(context.Background(), q2, p)
+	}
+	numSent, err := relayEvents(context.Background(), q1, p)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, numSent)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, 0, secondNumSent)
+	assert.Equal(t, []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}, p.sent)
+	assert.Equal(t, []int64{1, 2, 3}, q1.marked)
+	assert.Nil(t, q2.marked)
+
+	// Once the first relay's transaction is finished, the second relay can proceed
+	lock.held = false
+	q2.pending = nil
+	numSent, err = relayEvents(context.Background(), q2, p)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, numSent)
+	assert.True(t, lock.held)
+}
+
+func Test_Notifiers(t *testing.T) {
+	a := &countingNotifier{}
+	b := &countingNotifier{}
+	n := Notifiers{a, b}
+	n.Notify()
+	n.Notify()
+	assert.Equal(t, 2, a.count)
+	assert.Equal(t, 2, b.count)
+}
+
+type mockQueries struct {
+	err     error
+	lock    *mockLock
+	pending []queries.GetPendingOutboxEventsRow
+	marked  []int64
+}
+
+// mockLock simulates the advisory lock that's shared by all relays, which is held until
+// the end of the transaction in which it's acquired
+type mockLock struct {
+	held bool
+}
+
+func (m *mockQueries) TryLockOutboxRelay(ctx context.Context) (bool, error) {
+	if m.lock == nil {
+		return true, nil
+	}
+	if m.lock.held {
+		return false, nil
+	}
+	m.lock.held = true
+	return true, nil
+}
+
+func (m *mockQueries) GetPendingOutboxEvents(ctx context.Context, limit int32) ([]queries.GetPendingOutboxEventsRow, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.pending, nil
+}
+
+func (m *mockQueries) MarkOutboxEventSent(ctx context.Context, outboxEventID int64) (sql.Result, error) {
+	m.marked = append(m.marked, outboxEventID)
+	return driverResult(1), nil
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) {
+	return 0, fmt.Errorf("not supported")
+}
+
+func (r driverResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+type mockProducer struct {
+	onSend   func()
+	failOn   string
+	sent     []string
+	traceIds []string
+}
+
+func (m *mockProducer) Send(ctx context.Context, jsonData []byte) error {
+	if m.onSend != nil {
+		m.onSend()
+	}
+	if m.failOn != "" && string(jsonData) == m.failOn {
+		return fmt.Errorf("oh no")
+	}
+	m.sent = append(m.sent, string(jsonData))
+	traceId := ""
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		traceId = spanContext.TraceID().String()
+	}
+	m.traceIds = append(m.traceIds, traceId)
+	return nil
+}
+
+type countingNotifier struct {
+	count int
+}
+
+func (c *countingNotifier) Notify() {
+	c.count++
+}
@@ -0,0 +1,164 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"golang.org/x/exp/slog"
+)
+
+type Queries interface {
+	GetBroadcastDataEx(ctx context.Context, arg queries.GetBroadcastDataParams) ([]broadcasts.Broadcast, error)
+}
+
+// Reconciler periodically checks the broadcast state recorded in our database against
+// an external source of truth for whether the stream is live. If a broadcast has been
+// left open while the stream is offline (e.g. because the service crashed, or because
+// a stream-ended event was lost), the reconciler ends that broadcast, along with any
+// screening left in progress, and emits corrective broadcast-events.
+//
+// Since the status source may briefly misreport a live stream as offline, a broadcast
+// is only ended once the stream has been observed offline continuously for at least
+// the reconciler's grace period.
+type Reconciler struct {
+	logger   *slog.Logger
+	q        Queries
+	w        state.Writer
+	status   StatusSource
+	interval time.Duration
+	grace    time.Duration
+
+	// lastSeenLiveAt is the most recent time at which we observed that the stream was
+	// live, if ever
+	lastSeenLiveAt time.Time
+
+	// offlineSince is the time at which we first observed that the stream was offline,
+	// in the current run of consecutive observations, or zero if we last observed the
+	// stream as being live
+	offlineSince time.Time
+}
+
+// NewReconciler initializes a Reconciler that will check state every interval once Run
+// is called, ending any open broadcast once the stream has been offline for grace. A
+// grace of zero ends an open broadcast as soon as the stream is observed offline.
+func NewReconciler(logger *slog.Logger, q Queries, w state.Writer, status StatusSource, interval time.Duration, grace time.Duration) *Reconciler {
+	return &Reconciler{
+		logger:   logger,
+		q:        q,
+		w:        w,
+		status:   status,
+		interval: interval,
+		grace:    grace,
+	}
+}
+
+// Run reconciles state immediately, then again at every interval, until the context is
+// canceled. Any changes in state are attributed to the reconciler in the audit log.
+func (r *Reconciler) Run(ctx context.Context) {
+	ctx = audit.WithActor(ctx, audit.Actor{Name: audit.ActorReconciler})
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.reconcile(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Context canceled; reconciler shutting down")
+			return
+		case <-ticker.C:
+			r.reconcile(ctx, time.Now())
+		}
+	}
+}
+
+// reconcile makes a single check against the status source, ending the current
+// broadcast if it's stale
+func (r *Reconciler) reconcile(ctx context.Context, now time.Time) {
+	// Find out whether the stream is live
+	status, err := r.status.GetStreamStatus(ctx)
+	if err != nil {
+		r.logger.Error("Failed to get stream status for reconciliation", "error", err)
+		return
+	}
+
+	// If the stream is live, we have nothing to correct: just make a note of when we
+	// last saw it live
+	if status.IsLive {
+		r.lastSeenLiveAt = now
+		r.offlineSince = time.Time{}
+		return
+	}
+	if r.offlineSince.IsZero() {
+		r.offlineSince = now
+	}
+
+	// Don't take any action until the stream has been offline for long enough that it
+	// can't just be a momentary blip
+	if now.Sub(r.offlineSince) < r.grace {
+		return
+	}
+
+	// Find the most recent broadcast: if it's ended, we're in agreement
+	rows, err := r.q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+		Limit: sql.NullInt32{Valid: true, Int32: 1},
+	})
+	if err != nil {
+		r.logger.Error("Failed to get broadcast data for reconciliation", "error", err)
+		return
+	}
+	if len(rows) == 0 || rows[0].EndedAt != nil {
+		return
+	}
+
+	// If the broadcast was started after the stream went offline, then the status
+	// source may simply be lagging behind: give it the benefit of the doubt until the
+	// next check
+	broadcast := rows[0]
+	if !broadcast.StartedAt.Before(r.offlineSince) {
+		return
+	}
+
+	// The broadcast is stale: end it at the last time we know it was live, or, failing
+	// that (e.g. if it was already open when we started), at the last activity we have
+	// on record for it
+	endedAt := lastActivityAt(&broadcast)
+	if r.lastSeenLiveAt.After(endedAt) {
+		endedAt = r.lastSeenLiveAt
+	}
+	r.logger.Warn("Ending stale broadcast", "broadcastId", broadcast.Id, "endedAt", endedAt)
+	if err := r.w.EndStaleBroadcast(ctx, broadcast.Id, endedAt); err != nil {
+		if errors.Is(err, state.ErrNoBroadcastInProgress) {
+			r.logger.Info("Stale broadcast was already ended", "broadcastId", broadcast.Id)
+			return
+		}
+		r.logger.Error("Failed to end stale broadcast", "broadcastId", broadcast.Id, "error", err)
+	}
+}
+
+// lastActivityAt returns the most recent time at which we have any record of activity
+// in the given broadcast: i.e. the latest of its start time and the start or end of any
+// of its screenings or interruptions
+func lastActivityAt(broadcast *broadcasts.Broadcast) time.Time {
+	latest := broadcast.StartedAt
+	observe := func(t time.Time) {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	for _, screening := range broadcast.Screenings {
+		observe(screening.StartedAt)
+		if screening.EndedAt != nil {
+			observe(*screening.EndedAt)
+		}
+	}
+	for _, interruption := range broadcast.Interruptions {
+		observe(interruption.EndedAt)
+	}
+	return latest
+}
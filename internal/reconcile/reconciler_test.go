@@ -0,0 +1,221 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+var t0 = time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+
+const grace = 10 * time.Minute
+
+func Test_Reconciler_reconcile(t *testing.T) {
+	type check struct {
+		at     time.Time
+		isLive bool
+	}
+	tests := []struct {
+		name       string
+		broadcasts []broadcasts.Broadcast
+		checks     []check
+		wantEnded  []staleEnd
+	}{
+		{
+			"live stream with open broadcast is left alone",
+			[]broadcasts.Broadcast{
+				{Id: 1, StartedAt: t0},
+			},
+			[]check{
+				{t0.Add(time.Hour), true},
+			},
+			nil,
+		},
+		{
+			"offline stream with ended broadcast is left alone",
+			[]broadcasts.Broadcast{
+				{Id: 1, StartedAt: t0, EndedAt: timePtr(t0.Add(time.Hour))},
+			},
+			[]check{
+				{t0.Add(2 * time.Hour), false},
+			},
+			nil,
+		},
+		{
+			"offline stream with no broadcasts is left alone",
+			nil,
+			[]check{
+				{t0, false},
+			},
+			nil,
+		},
+		{
+			"open broadcast found at startup while offline is not ended within the grace period",
+			[]broadcasts.Broadcast{
+				{Id: 1, StartedAt: t0},
+			},
+			[]check{
+				{t0.Add(3 * time.Hour), false},
+				{t0.Add(3*time.Hour + grace/2), false},
+			},
+			nil,
+		},
+		{
+			"open broadcast found at startup while offline is ended at its last recorded activity",
+			[]broadcasts.Broadcast{
+				{
+					Id:        1,
+					StartedAt: t0,
+					Screenings: []broadcasts.Screening{
+						{TapeId: 109, StartedAt: t0.Add(10 * time.Minute), EndedAt: timePtr(t0.Add(40 * time.Minute))},
+						{TapeId: 110, StartedAt: t0.Add(50 * time.Minute)},
+					},
+					Interruptions: []broadcasts.Interruption{
+						{StartedAt: t0.Add(20 * time.Minute), EndedAt: t0.Add(25 * time.Minute)},
+					},
+				},
+			},
+			[]check{
+				{t0.Add(3 * time.Hour), false},
+				{t0.Add(3*time.Hour + grace), false},
+			},
+			[]staleEnd{
+				{broadcastId: 1, endedAt: t0.Add(50 * time.Minute)},
+			},
+		},
+		{
+			"open broadcast is ended at the last time the stream was seen live",
+			[]broadcasts.Broadcast{
+				{Id: 1, StartedAt: t0},
+			},
+			[]check{
+				{t0.Add(1 * time.Hour), true},
+				{t0.Add(2 * time.Hour), true},
+				{t0.Add(3 * time.Hour), false},
+				{t0.Add(3*time.Hour + grace), false},
+			},
+			[]staleEnd{
+				{broadcastId: 1, endedAt: t0.Add(2 * time.Hour)},
+			},
+		},
+		{
+			"momentary offline reading does not end a live broadcast",
+			[]broadcasts.Broadcast{
+				{Id: 1, StartedAt: t0},
+			},
+			[]check{
+				{t0.Add(1 * time.Hour), true},
+				{t0.Add(2 * time.Hour), false},
+				{t0.Add(2*time.Hour + grace/2), true},
+				{t0.Add(2*time.Hour + grace), false},
+				{t0.Add(2*time.Hour + 3*grace/2), true},
+			},
+			nil,
+		},
+		{
+			"broadcast started after stream was observed offline is given the benefit of the doubt",
+			[]broadcasts.Broadcast{
+				{Id: 1, StartedAt: t0.Add(30 * time.Minute)},
+			},
+			[]check{
+				{t0, false},
+				{t0.Add(grace), false},
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &mockStatusSource{}
+			w := &mockWriter{}
+			r := NewReconciler(slog.New(slog.NewTextHandler(io.Discard, nil)), &mockQueries{broadcasts: tt.broadcasts}, w, status, time.Minute, grace)
+			for _, c := range tt.checks {
+				status.isLive = c.isLive
+				r.reconcile(context.Background(), c.at)
+			}
+			assert.Equal(t, tt.wantEnded, w.ended)
+		})
+	}
+}
+
+func Test_Reconciler_reconcile_status_error(t *testing.T) {
+	w := &mockWriter{}
+	r := NewReconciler(slog.New(slog.NewTextHandler(io.Discard, nil)), &mockQueries{
+		broadcasts: []broadcasts.Broadcast{
+			{Id: 1, StartedAt: t0},
+		},
+	}, w, &mockStatusSource{err: fmt.Errorf("status unavailable")}, time.Minute, 0)
+	r.reconcile(context.Background(), t0.Add(time.Hour))
+	assert.Nil(t, w.ended)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+type mockStatusSource struct {
+	isLive bool
+	err    error
+}
+
+func (m *mockStatusSource) GetStreamStatus(ctx context.Context) (*StreamStatus, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &StreamStatus{IsLive: m.isLive}, nil
+}
+
+type mockQueries struct {
+	broadcasts []broadcasts.Broadcast
+}
+
+func (m *mockQueries) GetBroadcastDataEx(ctx context.Context, arg queries.GetBroadcastDataParams) ([]broadcasts.Broadcast, error) {
+	if len(m.broadcasts) == 0 {
+		return []broadcasts.Broadcast{}, nil
+	}
+	return m.broadcasts[len(m.broadcasts)-1:], nil
+}
+
+type staleEnd struct {
+	broadcastId int
+	endedAt     time.Time
+}
+
+type mockWriter struct {
+	ended []staleEnd
+}
+
+func (m *mockWriter) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) EndCurrentBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) ResumeBroadcast(ctx context.Context, broadcastId int) (*broadcasts.Broadcast, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) EndStaleBroadcast(ctx context.Context, broadcastId int, endedAt time.Time) error {
+	m.ended = append(m.ended, staleEnd{broadcastId: broadcastId, endedAt: endedAt})
+	return nil
+}
+
+func (m *mockWriter) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) EndCurrentScreening(ctx context.Context) error {
+	return fmt.Errorf("not mocked")
+}
+
+var _ state.Writer = (*mockWriter)(nil)
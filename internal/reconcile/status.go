@@ -0,0 +1,55 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StreamStatus describes whether the stream is currently live, according to some
+// source of truth other than our own database
+type StreamStatus struct {
+	IsLive bool `json:"isLive"`
+}
+
+// StatusSource can report whether the stream is currently live
+type StatusSource interface {
+	GetStreamStatus(ctx context.Context) (*StreamStatus, error)
+}
+
+// NewStatusClient returns a StatusSource that makes a GET request to the given URL,
+// which is expected to respond with a JSON-encoded StreamStatus, e.g.
+// {"isLive": true}. Any service that can determine whether the stream is live can
+// expose such an endpoint, and for local development, a simple stub that serves a
+// static file will suffice.
+func NewStatusClient(statusUrl string) StatusSource {
+	return &statusClient{
+		statusUrl: statusUrl,
+	}
+}
+
+type statusClient struct {
+	http.Client
+	statusUrl string
+}
+
+func (c *statusClient) GetStreamStatus(ctx context.Context) (*StreamStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.statusUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got response %d from %s", res.StatusCode, c.statusUrl)
+	}
+	var status StreamStatus
+	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode response body from %s: %w", c.statusUrl, err)
+	}
+	return &status, nil
+}
@@ -0,0 +1,67 @@
+package reconcile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_statusClient_GetStreamStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantIsLive bool
+		wantErr    bool
+	}{
+		{
+			"stream is live",
+			http.StatusOK,
+			`{"isLive":true}`,
+			true,
+			false,
+		},
+		{
+			"stream is offline",
+			http.StatusOK,
+			`{"isLive":false}`,
+			false,
+			false,
+		},
+		{
+			"non-200 response is an error",
+			http.StatusServiceUnavailable,
+			"",
+			false,
+			true,
+		},
+		{
+			"invalid response body is an error",
+			http.StatusOK,
+			"not-json",
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				res.WriteHeader(tt.status)
+				res.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := NewStatusClient(srv.URL)
+			status, err := c.GetStreamStatus(context.Background())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantIsLive, status.IsLive)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
+	"github.com/lib/pq"
+)
+
+// maxTxAttempts is the number of times we'll attempt a state-changing transaction
+// before giving up, if it keeps failing due to conflicts with concurrent transactions
+const maxTxAttempts = 5
+
+// txRetryDelay is how long we wait before retrying a failed transaction, multiplied by
+// the number of attempts made so far
+const txRetryDelay = 20 * time.Millisecond
+
+// runInTx calls the given function with a Queries struct that's scoped to a new
+// serializable transaction, committing the transaction only if the function succeeds.
+// Before calling the function, we acquire a transaction-scoped advisory lock, so that
+// all changes to broadcast state are made one at a time, even across processes. If
+// the transaction fails due to a serialization failure or deadlock, it's retried from
+// the beginning. Once committed, the notifier is notified so that any events recorded
+// to the outbox during the transaction can be relayed.
+//
+// Every attempt is recorded in the audit log as an instance of the given action,
+// attributed to the actor carried in ctx: if the attempt succeeds, the entry is
+// recorded in the same transaction as the change in state.
+func (w *writer) runInTx(ctx context.Context, action string, params interface{}, f func(q *queries.Queries) error) error {
+	var before *broadcasts.State
+	err := retryOnConflict(ctx, func() error {
+		tx, err := w.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		q := queries.New(tracing.WrapDB(tx))
+		if err := q.LockBroadcastState(ctx); err != nil {
+			return err
+		}
+		state, err := q.GetCurrentStateEx(ctx)
+		if err != nil {
+			return err
+		}
+		before = &state
+		if err := f(q); err != nil {
+			return err
+		}
+		after, err := q.GetCurrentStateEx(ctx)
+		if err != nil {
+			return err
+		}
+		entry, err := audit.NewEntry(ctx, action, params, state, after, nil)
+		if err != nil {
+			return err
+		}
+		if err := q.RecordAuditLogEntry(ctx, entry); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		w.recordFailure(ctx, action, params, before, err)
+		return err
+	}
+	w.notifier.Notify()
+	return nil
+}
+
+// recordFailure records an unsuccessful attempt to change state in the audit log.
+// Since the attempt's transaction has been rolled back, the entry is recorded on its
+// own, with the state as it now stands: this is best-effort, so if the entry can't be
+// recorded (e.g. because the database is unreachable), the attempt goes unrecorded.
+func (w *writer) recordFailure(ctx context.Context, action string, params interface{}, before *broadcasts.State, cause error) {
+	// Record the failure even if it was caused by the caller's context being canceled
+	ctx = context.WithoutCancel(ctx)
+
+	q := queries.New(tracing.WrapDB(w.db))
+	after, err := q.GetCurrentStateEx(ctx)
+	if err != nil {
+		return
+	}
+	if before == nil {
+		before = &after
+	}
+	entry, err := audit.NewEntry(ctx, action, params, *before, after, cause)
+	if err != nil {
+		return
+	}
+	_ = q.RecordAuditLogEntry(ctx, entry)
+}
+
+// retryOnConflict calls f until it succeeds, it fails with an error that can't be
+// resolved by retrying, or we run out of attempts
+func retryOnConflict(ctx context.Context, f func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = f()
+		if err == nil || !isConflict(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+	return err
+}
+
+// isConflict returns true if the given error indicates that a transaction was aborted
+// due to a conflict with another concurrent transaction, such that it may succeed if
+// retried
+func isConflict(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
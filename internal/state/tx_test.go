@@ -0,0 +1,81 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_retryOnConflict(t *testing.T) {
+	serializationFailure := &pq.Error{Code: "40001"}
+	deadlock := &pq.Error{Code: "40P01"}
+	uniqueViolation := &pq.Error{Code: "23505"}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			"success on first attempt",
+			[]error{nil},
+			nil,
+			1,
+		},
+		{
+			"serialization failures are retried",
+			[]error{serializationFailure, serializationFailure, nil},
+			nil,
+			3,
+		},
+		{
+			"deadlocks are retried",
+			[]error{deadlock, nil},
+			nil,
+			2,
+		},
+		{
+			"wrapped conflicts are retried",
+			[]error{fmt.Errorf("commit failed: %w", serializationFailure), nil},
+			nil,
+			2,
+		},
+		{
+			"other database errors are not retried",
+			[]error{uniqueViolation, nil},
+			uniqueViolation,
+			1,
+		},
+		{
+			"state errors are not retried",
+			[]error{ErrNoBroadcastInProgress, nil},
+			ErrNoBroadcastInProgress,
+			1,
+		},
+		{
+			"we give up after the maximum number of attempts",
+			[]error{serializationFailure, serializationFailure, serializationFailure, serializationFailure, serializationFailure, nil},
+			serializationFailure,
+			maxTxAttempts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryOnConflict(context.Background(), func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
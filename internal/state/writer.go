@@ -4,43 +4,116 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"time"
 
 	"github.com/golden-vcr/broadcasts"
 	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/outbox"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
 	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
-	"github.com/golden-vcr/server-common/rmq"
+	"github.com/golden-vcr/schemas/core"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var ErrBroadcastInProgress = errors.New("a broadcast is already in progress")
-var ErrNoBroadcastInProgress = errors.New("no broadcast is currently in progress")
-var ErrScreeningInProgress = errors.New("the desired tape is already being screened")
-var ErrNoScreeningInProgress = errors.New("no tape is currently being screened")
+// The errors returned by a Writer are defined in the root broadcasts package, so that
+// API clients can identify them as well
+var ErrBroadcastInProgress = broadcasts.ErrBroadcastInProgress
+var ErrNoBroadcastInProgress = broadcasts.ErrNoBroadcastInProgress
+var ErrScreeningInProgress = broadcasts.ErrScreeningInProgress
+var ErrNoScreeningInProgress = broadcasts.ErrNoScreeningInProgress
+var ErrNoSuchBroadcast = broadcasts.ErrNoSuchBroadcast
+var ErrBroadcastNotResumable = broadcasts.ErrBroadcastNotResumable
 
-type Writer interface {
-	StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error)
-	EndCurrentBroadcast(ctx context.Context) error
-	StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error)
-	EndCurrentScreening(ctx context.Context) error
-}
+// Writer is likewise defined in the root broadcasts package, so that fakes outside this
+// module (see broadcaststest) can implement it
+type Writer = broadcasts.Writer
 
-func NewWriter(q *queries.Queries, producer rmq.Producer) Writer {
+// NewWriter returns a Writer that modifies broadcast state in the given database. Each
+// change in state is made in a single serializable transaction, in which the
+// corresponding broadcast-events message is also recorded to the outbox; the notifier
+// is then notified so that the message can be relayed to the queue. Every attempt to
+// change state, whether or not it succeeds, is recorded in the audit log, attributed
+// to the actor carried in the context (see audit.WithActor).
+//
+// If resumeGrace is nonzero, then starting a broadcast within that amount of time after
+// the previous broadcast ended will resume that broadcast instead of starting a new
+// one, so that a brief outage doesn't split a single stream into multiple broadcasts.
+func NewWriter(db *sql.DB, notifier outbox.Notifier, resumeGrace time.Duration) Writer {
 	return &writer{
-		q:        q,
-		producer: producer,
+		db:          db,
+		notifier:    notifier,
+		resumeGrace: resumeGrace,
 	}
 }
 
 type writer struct {
-	q        *queries.Queries
-	producer rmq.Producer
+	db          *sql.DB
+	notifier    outbox.Notifier
+	resumeGrace time.Duration
 }
 
 func (w *writer) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	var broadcast *broadcasts.Broadcast
+	err := w.runInTx(ctx, audit.ActionStartBroadcast, nil, func(q *queries.Queries) error {
+		var err error
+		broadcast, err = w.startBroadcast(ctx, q)
+		return err
+	})
+	return broadcast, err
+}
+
+func (w *writer) EndCurrentBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	var broadcast *broadcasts.Broadcast
+	err := w.runInTx(ctx, audit.ActionEndBroadcast, nil, func(q *queries.Queries) error {
+		var err error
+		broadcast, err = w.endCurrentBroadcast(ctx, q)
+		return err
+	})
+	return broadcast, err
+}
+
+func (w *writer) ResumeBroadcast(ctx context.Context, broadcastId int) (*broadcasts.Broadcast, error) {
+	var broadcast *broadcasts.Broadcast
+	params := map[string]interface{}{"broadcastId": broadcastId}
+	err := w.runInTx(ctx, audit.ActionResumeBroadcast, params, func(q *queries.Queries) error {
+		var err error
+		broadcast, err = w.resumePreviousBroadcast(ctx, q, broadcastId)
+		return err
+	})
+	return broadcast, err
+}
+
+func (w *writer) EndStaleBroadcast(ctx context.Context, broadcastId int, endedAt time.Time) error {
+	params := map[string]interface{}{"broadcastId": broadcastId, "endedAt": endedAt}
+	return w.runInTx(ctx, audit.ActionEndStaleBroadcast, params, func(q *queries.Queries) error {
+		return w.endStaleBroadcast(ctx, q, broadcastId, endedAt)
+	})
+}
+
+func (w *writer) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
+	var screening *broadcasts.Screening
+	params := map[string]interface{}{"tapeId": tapeId}
+	err := w.runInTx(ctx, audit.ActionStartScreening, params, func(q *queries.Queries) error {
+		var err error
+		screening, err = w.startScreening(ctx, q, tapeId)
+		return err
+	})
+	return screening, err
+}
+
+func (w *writer) EndCurrentScreening(ctx context.Context) error {
+	return w.runInTx(ctx, audit.ActionEndScreening, nil, func(q *queries.Queries) error {
+		return w.endCurrentScreening(ctx, q)
+	})
+}
+
+func (w *writer) startBroadcast(ctx context.Context, q *queries.Queries) (*broadcasts.Broadcast, error) {
 	// Query the data for the most recent broadcast, if any
-	rows, err := w.q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
 		Limit: sql.NullInt32{Valid: true, Int32: 1},
 	})
 	if err != nil {
@@ -48,21 +121,28 @@ func (w *writer) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, err
 	}
 
 	// If we have an existing broadcast that's still in progress, do nothing
-	if len(rows) > 0 && rows[0].EndedAt == nil {
-		return nil, ErrBroadcastInProgress
+	t := broadcasts.Transition{Type: broadcasts.TransitionStartBroadcast}
+	if err := t.Validate(currentState(rows)); err != nil {
+		return nil, err
+	}
+
+	// If the most recent broadcast ended only a short time ago, assume that it was
+	// interrupted by a brief outage, and resume it rather than starting a new one
+	if len(rows) > 0 && canResume(&rows[0], w.resumeGrace, time.Now()) {
+		return w.resumeBroadcast(ctx, q, rows[0].Id)
 	}
 
 	// Record the start of a new broadcast in the database, getting back its ID and
 	// started-at timestamp
-	row, err := w.q.StartBroadcast(ctx)
+	row, err := q.StartBroadcast(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Produce an event to the broadcast-events queue, indicating to all downstream
+	// Record an event for the broadcast-events queue, indicating to all downstream
 	// services that we've started a new broadcast (in which we are not yet screening
 	// any tapes)
-	if err := w.produce(ctx, &ebroadcast.Event{
+	if err := w.produce(ctx, q, &ebroadcast.Event{
 		Type: ebroadcast.EventTypeBroadcastStarted,
 		Broadcast: ebroadcast.BroadcastData{
 			Id:        int(row.ID),
@@ -74,79 +154,265 @@ func (w *writer) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, err
 
 	// Success; return the details of the new broadcast
 	return &broadcasts.Broadcast{
-		Id:         int(row.ID),
-		StartedAt:  row.StartedAt,
-		EndedAt:    nil,
-		Screenings: []broadcasts.Screening{},
+		Id:            int(row.ID),
+		StartedAt:     row.StartedAt,
+		EndedAt:       nil,
+		Screenings:    []broadcasts.Screening{},
+		Interruptions: []broadcasts.Interruption{},
 	}, nil
 }
 
-func (w *writer) EndCurrentBroadcast(ctx context.Context) error {
+func (w *writer) resumeBroadcast(ctx context.Context, q *queries.Queries, id int) (*broadcasts.Broadcast, error) {
+	// Update the database to indicate that the broadcast is once again in progress
+	result, err := q.ResumeBroadcast(ctx, int32(id))
+	if err != nil {
+		return nil, err
+	}
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if numRowsAffected != int64(1) {
+		return nil, fmt.Errorf("failed to resume broadcast: expected to affect 1 rows; instead affected %d", numRowsAffected)
+	}
+
+	// Close out the interruption that was opened when the broadcast ended, so that we
+	// have a record of how long the broadcast was offline
+	result, err = q.EndBroadcastInterruption(ctx, int32(id))
+	if err != nil {
+		return nil, err
+	}
+	numRowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if numRowsAffected != int64(1) {
+		return nil, fmt.Errorf("failed to end broadcast interruption: expected to affect 1 rows; instead affected %d", numRowsAffected)
+	}
+
+	// Query the data for the resumed broadcast, so that we can return it as it now
+	// stands
+	broadcast, err := getBroadcast(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	if broadcast == nil {
+		return nil, fmt.Errorf("failed to resume broadcast: broadcast %d not found", id)
+	}
+
+	// Record an event for the broadcast-events queue, indicating to all downstream
+	// services that the broadcast is live again
+	if err := w.produceAt(ctx, q, resumedEvent(broadcast), sql.NullTime{}); err != nil {
+		return nil, err
+	}
+
+	// Success; return the details of the resumed broadcast
+	return broadcast, nil
+}
+
+// resumedEvent returns the broadcast-events message that announces that the given
+// broadcast has been resumed. It reuses the broadcast's original ID and start time, and
+// it's flagged as resumed so that consumers can tell it apart from the start of a new
+// broadcast. Any screening was ended along with the broadcast, so we're not screening
+// anything.
+func resumedEvent(broadcast *broadcasts.Broadcast) *broadcasts.SequencedEvent {
+	return &broadcasts.SequencedEvent{
+		Event: ebroadcast.Event{
+			Type: ebroadcast.EventTypeBroadcastStarted,
+			Broadcast: ebroadcast.BroadcastData{
+				Id:        broadcast.Id,
+				StartedAt: broadcast.StartedAt,
+			},
+		},
+		Resumed: true,
+	}
+}
+
+func (w *writer) resumePreviousBroadcast(ctx context.Context, q *queries.Queries, broadcastId int) (*broadcasts.Broadcast, error) {
 	// Query the data for the most recent broadcast, if any
-	rows, err := w.q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
 		Limit: sql.NullInt32{Valid: true, Int32: 1},
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoSuchBroadcast
+	}
+
+	// We can't resume a broadcast while another one (or the same one) is in progress
+	t := broadcasts.Transition{Type: broadcasts.TransitionStartBroadcast}
+	if err := t.Validate(currentState(rows)); err != nil {
+		return nil, err
+	}
+
+	// Only the most recent broadcast may be resumed, since resuming an older one would
+	// leave it overlapping with the broadcasts that followed it
+	if rows[0].Id != broadcastId {
+		broadcast, err := getBroadcast(ctx, q, broadcastId)
+		if err != nil {
+			return nil, err
+		}
+		if broadcast == nil {
+			return nil, ErrNoSuchBroadcast
+		}
+		return nil, ErrBroadcastNotResumable
+	}
+
+	// The requested broadcast is the most recent one and it's ended, so we can resume
+	// it regardless of how long ago it ended
+	return w.resumeBroadcast(ctx, q, broadcastId)
+}
+
+func (w *writer) endCurrentBroadcast(ctx context.Context, q *queries.Queries) (*broadcasts.Broadcast, error) {
+	// Query the data for the most recent broadcast, if any
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+		Limit: sql.NullInt32{Valid: true, Int32: 1},
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// If there's no in-progress broadcast to end, abort with an error
-	if len(rows) == 0 || rows[0].EndedAt != nil {
-		return ErrNoBroadcastInProgress
+	t := broadcasts.Transition{Type: broadcasts.TransitionEndBroadcast}
+	if err := t.Validate(currentState(rows)); err != nil {
+		return nil, err
+	}
+
+	// If a screening is still in progress, end it along with the broadcast
+	broadcast := rows[0]
+	lastScreening := getScreeningInProgress(&broadcast)
+	if lastScreening != nil {
+		if err := w.endScreening(ctx, q, lastScreening.Id); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update the database to reflect the fact that the current broadcast has now ended
-	if err := w.endBroadcast(ctx, rows[0].Id); err != nil {
-		return err
+	if err := w.endBroadcast(ctx, q, broadcast.Id); err != nil {
+		return nil, err
 	}
 
-	// Produce an event to the broadcast-events queue, indicating to all downstream
-	// services that we are no longer broadcasting or screening anything
-	return w.produce(ctx, &ebroadcast.Event{
+	// Record an event for the broadcast-events queue, indicating to all downstream
+	// services that we are no longer broadcasting or screening anything, and carrying
+	// the details of the screening that was ended, if any
+	if err := w.produce(ctx, q, &ebroadcast.Event{
 		Type: ebroadcast.EventTypeBroadcastFinished,
+		Broadcast: ebroadcast.BroadcastData{
+			Id:        broadcast.Id,
+			StartedAt: broadcast.StartedAt,
+		},
+		Screening: toScreeningData(lastScreening),
+	}); err != nil {
+		return nil, err
+	}
+
+	// Success; return the details of the broadcast as it now stands
+	ended, err := getBroadcast(ctx, q, broadcast.Id)
+	if err != nil {
+		return nil, err
+	}
+	if ended == nil {
+		return nil, fmt.Errorf("failed to end broadcast: broadcast %d not found", broadcast.Id)
+	}
+	return ended, nil
+}
+
+func (w *writer) endStaleBroadcast(ctx context.Context, q *queries.Queries, broadcastId int, endedAt time.Time) error {
+	// Query the data for the most recent broadcast, if any
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+		Limit: sql.NullInt32{Valid: true, Int32: 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Require that the stale broadcast is the one currently in progress: if it's
+	// already been ended (or superseded by a new broadcast), there's nothing to do
+	t := broadcasts.Transition{Type: broadcasts.TransitionEndBroadcast, BroadcastId: broadcastId}
+	if err := t.Validate(currentState(rows)); err != nil {
+		return err
+	}
+
+	// We can't end the broadcast before it started, nor can we end it before any of its
+	// screenings started
+	broadcast := rows[0]
+	if endedAt.Before(broadcast.StartedAt) {
+		endedAt = broadcast.StartedAt
+	}
+	for _, screening := range broadcast.Screenings {
+		if endedAt.Before(screening.StartedAt) {
+			endedAt = screening.StartedAt
+		}
+	}
+
+	// Update the database to record that the broadcast ended at the given time, and
+	// close out any screening that was left in progress at that same time
+	result, err := q.EndBroadcastAt(ctx, queries.EndBroadcastAtParams{
+		EndedAt:     sql.NullTime{Valid: true, Time: endedAt},
+		BroadcastID: int32(broadcastId),
 	})
+	if err != nil {
+		return err
+	}
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRowsAffected != int64(1) {
+		return fmt.Errorf("failed to end stale broadcast: expected to affect 1 rows; instead affected %d", numRowsAffected)
+	}
+	if _, err := q.EndBroadcastScreenings(ctx, int32(broadcastId)); err != nil {
+		return err
+	}
+	if err := q.StartBroadcastInterruption(ctx, int32(broadcastId)); err != nil {
+		return err
+	}
+
+	// Record a corrective event for the broadcast-events queue, indicating to all
+	// downstream services that we are no longer broadcasting or screening anything, and
+	// log that the broadcast actually ended at the stale time
+	return w.produceAt(ctx, q, &broadcasts.SequencedEvent{
+		Event: ebroadcast.Event{
+			Type: ebroadcast.EventTypeBroadcastFinished,
+			Broadcast: ebroadcast.BroadcastData{
+				Id:        broadcast.Id,
+				StartedAt: broadcast.StartedAt,
+			},
+			Screening: toScreeningData(getScreeningInProgress(&broadcast)),
+		},
+	}, sql.NullTime{Valid: true, Time: endedAt})
 }
 
-func (w *writer) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
+func (w *writer) startScreening(ctx context.Context, q *queries.Queries, tapeId int) (*broadcasts.Screening, error) {
 	// Query the data for the most recent broadcast, if any, with its list of screenings
-	rows, err := w.q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
 		Limit: sql.NullInt32{Valid: true, Int32: 1},
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Require that we have a broadcast in progress in order to screen tapes
-	if len(rows) == 0 || rows[0].EndedAt != nil {
-		return nil, ErrNoBroadcastInProgress
-	}
-
-	// If our current broadcast has any existing screenings, check their state
-	if len(rows[0].Screenings) > 0 {
-		// If the most recent screening is still in progress, we need to implicitly end
-		// it before starting a new one - unless this is a subsequent request to screen
-		// the tape that's already being screened, in which case we want to refuse
-		lastScreening := rows[0].Screenings[len(rows[0].Screenings)-1]
-		if lastScreening.EndedAt == nil {
-			// If ending the existing screening to create the new one would result in
-			// two back-to-back screenings of the same tape, don't comply with the
-			// request
-			if lastScreening.TapeId == tapeId {
-				return nil, ErrScreeningInProgress
-			}
-
-			// Otherwise, we need to end the current screening before we can start the
-			// next one
-			if err := w.endScreening(ctx, lastScreening.Id); err != nil {
-				return nil, err
-			}
+	// Require that we have a broadcast in progress in order to screen tapes, and refuse
+	// any subsequent request to screen the tape that's already being screened, since
+	// that would result in two back-to-back screenings of the same tape
+	t := broadcasts.Transition{Type: broadcasts.TransitionStartScreening, TapeId: tapeId}
+	if err := t.Validate(currentState(rows)); err != nil {
+		return nil, err
+	}
+
+	// If the most recent screening is still in progress, we need to implicitly end it
+	// before we can start the next one
+	if lastScreening := getScreeningInProgress(&rows[0]); lastScreening != nil {
+		if err := w.endScreening(ctx, q, lastScreening.Id); err != nil {
+			return nil, err
 		}
 	}
 
 	// State is clean; record a new screening in the database under the current
 	// broadcast, with the requested tape ID, and get back the screening ID and
 	// started-at timestamp
-	screeningRow, err := w.q.StartScreening(ctx, queries.StartScreeningParams{
+	screeningRow, err := q.StartScreening(ctx, queries.StartScreeningParams{
 		BroadcastID: int32(rows[0].Id),
 		TapeID:      int32(tapeId),
 	})
@@ -154,9 +420,9 @@ func (w *writer) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Sc
 		return nil, err
 	}
 
-	// Produce an event to the broadcast-events queue, indicating to all downstream
+	// Record an event for the broadcast-events queue, indicating to all downstream
 	// services that we're now screening a new tape
-	if err := w.produce(ctx, &ebroadcast.Event{
+	if err := w.produce(ctx, q, &ebroadcast.Event{
 		Type: ebroadcast.EventTypeScreeningStarted,
 		Broadcast: ebroadcast.BroadcastData{
 			Id:        rows[0].Id,
@@ -180,37 +446,30 @@ func (w *writer) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Sc
 	}, nil
 }
 
-func (w *writer) EndCurrentScreening(ctx context.Context) error {
+func (w *writer) endCurrentScreening(ctx context.Context, q *queries.Queries) error {
 	// Query the data for the most recent broadcast, if any, with its list of screenings
-	rows, err := w.q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
 		Limit: sql.NullInt32{Valid: true, Int32: 1},
 	})
 	if err != nil {
 		return err
 	}
 
-	// Require that we have a broadcast in progress in order to modify screening state
-	if len(rows) == 0 || rows[0].EndedAt != nil {
-		return ErrNoBroadcastInProgress
-	}
-
-	// Require that we have a screening in progress in order to end it
-	var lastScreening *broadcasts.Screening
-	if len(rows[0].Screenings) > 0 {
-		lastScreening = &rows[0].Screenings[len(rows[0].Screenings)-1]
-	}
-	if lastScreening == nil || lastScreening.EndedAt != nil {
-		return ErrNoScreeningInProgress
+	// Require that we have a broadcast in progress, with a screening in progress, in
+	// order to end that screening
+	t := broadcasts.Transition{Type: broadcasts.TransitionEndScreening}
+	if err := t.Validate(currentState(rows)); err != nil {
+		return err
 	}
 
 	// Update the database to reflect the fact that this screening has ended
-	if err := w.endScreening(ctx, lastScreening.Id); err != nil {
+	if err := w.endScreening(ctx, q, getScreeningInProgress(&rows[0]).Id); err != nil {
 		return err
 	}
 
-	// Produce an event to the broadcast-events queue, indicating to all downstream
+	// Record an event for the broadcast-events queue, indicating to all downstream
 	// services that we're no longer screening a tape (but the broadcast remains live)
-	return w.produce(ctx, &ebroadcast.Event{
+	return w.produce(ctx, q, &ebroadcast.Event{
 		Type: ebroadcast.EventTypeScreeningFinished,
 		Broadcast: ebroadcast.BroadcastData{
 			Id:        rows[0].Id,
@@ -219,8 +478,75 @@ func (w *writer) EndCurrentScreening(ctx context.Context) error {
 	})
 }
 
-func (w *writer) endBroadcast(ctx context.Context, id int) error {
-	result, err := w.q.EndBroadcast(ctx, int32(id))
+// getBroadcast returns the data for the broadcast with the given ID, or nil if no such
+// broadcast exists
+func getBroadcast(ctx context.Context, q *queries.Queries, id int) (*broadcasts.Broadcast, error) {
+	// Our query returns broadcast data in descending order by ID, so we can ask for a
+	// single result that appears before (id + 1) to get the desired broadcast
+	rows, err := q.GetBroadcastDataEx(ctx, queries.GetBroadcastDataParams{
+		BeforeBroadcastID: sql.NullInt32{Valid: true, Int32: int32(id + 1)},
+		Limit:             sql.NullInt32{Valid: true, Int32: 1},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || rows[0].Id != id {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// currentState returns the broadcast state implied by the given broadcast data, which is
+// expected to hold only the most recent broadcast (if any)
+func currentState(rows []broadcasts.Broadcast) core.State {
+	if len(rows) == 0 || rows[0].EndedAt != nil {
+		return core.State{}
+	}
+	state := core.State{BroadcastId: rows[0].Id}
+	if screening := getScreeningInProgress(&rows[0]); screening != nil {
+		state.ScreeningId = screening.Id
+		state.TapeId = screening.TapeId
+	}
+	return state
+}
+
+// getScreeningInProgress returns the last screening in the given broadcast, if it has
+// not yet ended
+func getScreeningInProgress(b *broadcasts.Broadcast) *broadcasts.Screening {
+	if len(b.Screenings) == 0 {
+		return nil
+	}
+	lastScreening := &b.Screenings[len(b.Screenings)-1]
+	if lastScreening.EndedAt != nil {
+		return nil
+	}
+	return lastScreening
+}
+
+// toScreeningData converts a screening to the representation used in broadcast-events,
+// returning nil if the screening is nil
+func toScreeningData(s *broadcasts.Screening) *ebroadcast.ScreeningData {
+	if s == nil {
+		return nil
+	}
+	return &ebroadcast.ScreeningData{
+		Id:        s.Id,
+		StartedAt: s.StartedAt,
+		TapeId:    s.TapeId,
+	}
+}
+
+// canResume returns true if the given broadcast has ended, and if it ended no more than
+// resumeGrace before now
+func canResume(b *broadcasts.Broadcast, resumeGrace time.Duration, now time.Time) bool {
+	if resumeGrace <= 0 || b.EndedAt == nil {
+		return false
+	}
+	return now.Sub(*b.EndedAt) <= resumeGrace
+}
+
+func (w *writer) endBroadcast(ctx context.Context, q *queries.Queries, id int) error {
+	result, err := q.EndBroadcast(ctx, int32(id))
 	if err != nil {
 		return err
 	}
@@ -231,11 +557,14 @@ func (w *writer) endBroadcast(ctx context.Context, id int) error {
 	if numRowsAffected != int64(1) {
 		return fmt.Errorf("failed to end broadcast: expected to affect 1 rows; instead affected %d", numRowsAffected)
 	}
-	return nil
+
+	// Open an interruption for the broadcast, which will be closed if the broadcast is
+	// ever resumed
+	return q.StartBroadcastInterruption(ctx, int32(id))
 }
 
-func (w *writer) endScreening(ctx context.Context, id uuid.UUID) error {
-	result, err := w.q.EndScreening(ctx, id)
+func (w *writer) endScreening(ctx context.Context, q *queries.Queries, id uuid.UUID) error {
+	result, err := q.EndScreening(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -249,10 +578,55 @@ func (w *writer) endScreening(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (w *writer) produce(ctx context.Context, ev *ebroadcast.Event) error {
+// produce records an event to the outbox, to be sent to the broadcast-events queue once
+// the current transaction has been committed, appends it to the event log, and
+// schedules its delivery to all registered webhook subscribers. Each event is assigned
+// the next sequence number: since we hold the broadcast state lock, no other
+// transaction can claim the same number, and if this transaction is rolled back, the
+// number is never used.
+func (w *writer) produce(ctx context.Context, q *queries.Queries, ev *ebroadcast.Event) error {
+	return w.produceAt(ctx, q, &broadcasts.SequencedEvent{Event: *ev}, sql.NullTime{})
+}
+
+// produceAt is identical to produce, but it accepts a SequencedEvent (whose Seq will be
+// assigned), and if occurredAt is valid, it records in the event log that the change
+// took effect at that time, rather than at the time of the current transaction. The
+// current trace context is stored with the event in the outbox, so that the message
+// sent to broadcast-events will belong to the same trace.
+func (w *writer) produceAt(ctx context.Context, q *queries.Queries, ev *broadcasts.SequencedEvent, occurredAt sql.NullTime) (err error) {
+	ctx, span := tracing.Start(ctx, "writer.produce", trace.WithAttributes(
+		attribute.String("broadcasts.event_type", string(ev.Type)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	seq, err := q.GetNextOutboxSeq(ctx)
+	if err != nil {
+		return err
+	}
+	ev.Seq = seq
 	data, err := json.Marshal(ev)
 	if err != nil {
 		return err
 	}
-	return w.producer.Send(ctx, data)
+	span.SetAttributes(attribute.Int64("broadcasts.seq", seq))
+	traceContext, err := tracing.MarshalTraceContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := q.RecordOutboxEvent(ctx, queries.RecordOutboxEventParams{
+		Seq:          seq,
+		Payload:      data,
+		TraceContext: traceContext,
+	}); err != nil {
+		return err
+	}
+	if err := q.RecordEventLogEntry(ctx, queries.RecordEventLogEntryParams{
+		Seq:        seq,
+		EventType:  string(ev.Type),
+		Payload:    data,
+		OccurredAt: occurredAt,
+	}); err != nil {
+		return err
+	}
+	return q.ScheduleWebhookDeliveries(ctx, seq)
 }
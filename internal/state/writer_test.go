@@ -0,0 +1,179 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts"
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_canResume(t *testing.T) {
+	now := time.Date(1997, 9, 1, 14, 0, 0, 0, time.UTC)
+	endedAt := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	tests := []struct {
+		name        string
+		b           broadcasts.Broadcast
+		resumeGrace time.Duration
+		want        bool
+	}{
+		{
+			"broadcast that ended within the grace period can be resumed",
+			broadcasts.Broadcast{EndedAt: endedAt(30 * time.Second)},
+			10 * time.Minute,
+			true,
+		},
+		{
+			"broadcast that ended exactly at the end of the grace period can be resumed",
+			broadcasts.Broadcast{EndedAt: endedAt(10 * time.Minute)},
+			10 * time.Minute,
+			true,
+		},
+		{
+			"broadcast that ended before the grace period can't be resumed",
+			broadcasts.Broadcast{EndedAt: endedAt(11 * time.Minute)},
+			10 * time.Minute,
+			false,
+		},
+		{
+			"broadcast that's still in progress can't be resumed",
+			broadcasts.Broadcast{EndedAt: nil},
+			10 * time.Minute,
+			false,
+		},
+		{
+			"no broadcast can be resumed if the grace period is zero",
+			broadcasts.Broadcast{EndedAt: endedAt(0)},
+			0,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := canResume(&tt.b, tt.resumeGrace, now)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_resumedEvent(t *testing.T) {
+	startedAt := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	endedAt := time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC)
+	ev := resumedEvent(&broadcasts.Broadcast{
+		Id:        12,
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+	})
+
+	// A resumed broadcast is announced with its original ID and start time, and is
+	// distinguishable from a new broadcast
+	assert.Equal(t, &broadcasts.SequencedEvent{
+		Event: ebroadcast.Event{
+			Type: ebroadcast.EventTypeBroadcastStarted,
+			Broadcast: ebroadcast.BroadcastData{
+				Id:        12,
+				StartedAt: startedAt,
+			},
+		},
+		Resumed: true,
+	}, ev)
+}
+
+func Test_getScreeningInProgress(t *testing.T) {
+	screeningEndedAt := time.Date(1997, 9, 1, 12, 45, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		b      broadcasts.Broadcast
+		wantId uuid.UUID
+	}{
+		{
+			"broadcast with no screenings",
+			broadcasts.Broadcast{
+				Screenings: []broadcasts.Screening{},
+			},
+			uuid.Nil,
+		},
+		{
+			"broadcast whose last screening has ended",
+			broadcasts.Broadcast{
+				Screenings: []broadcasts.Screening{
+					{Id: uuid.MustParse("6c2c94e3-db0c-4367-8ce7-e86f98ac03d0"), EndedAt: &screeningEndedAt},
+				},
+			},
+			uuid.Nil,
+		},
+		{
+			"broadcast whose last screening is in progress",
+			broadcasts.Broadcast{
+				Screenings: []broadcasts.Screening{
+					{Id: uuid.MustParse("6c2c94e3-db0c-4367-8ce7-e86f98ac03d0"), EndedAt: &screeningEndedAt},
+					{Id: uuid.MustParse("638a6e4b-4225-4aba-8893-b1c5cbad4e21"), EndedAt: nil},
+				},
+			},
+			uuid.MustParse("638a6e4b-4225-4aba-8893-b1c5cbad4e21"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getScreeningInProgress(&tt.b)
+			if tt.wantId == uuid.Nil {
+				assert.Nil(t, got)
+			} else {
+				assert.NotNil(t, got)
+				assert.Equal(t, tt.wantId, got.Id)
+			}
+		})
+	}
+}
+
+func Test_currentState(t *testing.T) {
+	endedAt := time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC)
+	screeningId := uuid.MustParse("638a6e4b-4225-4aba-8893-b1c5cbad4e21")
+	tests := []struct {
+		name string
+		rows []broadcasts.Broadcast
+		want core.State
+	}{
+		{
+			"no broadcasts",
+			[]broadcasts.Broadcast{},
+			core.State{},
+		},
+		{
+			"most recent broadcast has ended",
+			[]broadcasts.Broadcast{
+				{Id: 12, EndedAt: &endedAt},
+			},
+			core.State{},
+		},
+		{
+			"broadcast in progress with no screening",
+			[]broadcasts.Broadcast{
+				{Id: 12, Screenings: []broadcasts.Screening{
+					{Id: uuid.MustParse("6c2c94e3-db0c-4367-8ce7-e86f98ac03d0"), TapeId: 41, EndedAt: &endedAt},
+				}},
+			},
+			core.State{BroadcastId: 12},
+		},
+		{
+			"broadcast in progress with screening",
+			[]broadcasts.Broadcast{
+				{Id: 12, Screenings: []broadcasts.Screening{
+					{Id: screeningId, TapeId: 42},
+				}},
+			},
+			core.State{BroadcastId: 12, ScreeningId: screeningId, TapeId: 42},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, currentState(tt.rows))
+		})
+	}
+}
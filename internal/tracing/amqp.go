@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golden-vcr/server-common/rmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewProducer initializes an rmq.Producer that sends messages to the fanout exchange
+// with the given name, exactly as rmq.NewProducer does, except that each message is
+// recorded as a span and carries the trace context of that span in its headers. Each
+// message is also stamped with the time at which it was published, so that consumers
+// can measure lag.
+func NewProducer(conn *amqp.Connection, exchange string) (rmq.Producer, error) {
+	// rmq.NewProducer declares the exchange, but its producer can't set headers, so
+	// we only use it for that side effect
+	if _, err := rmq.NewProducer(conn, exchange); err != nil {
+		return nil, err
+	}
+	return &producer{
+		conn:     conn,
+		exchange: exchange,
+	}, nil
+}
+
+type producer struct {
+	conn     *amqp.Connection
+	exchange string
+}
+
+func (p *producer) Send(ctx context.Context, jsonData []byte) error {
+	ctx, span := Start(ctx, p.exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingDestinationName(p.exchange),
+			semconv.MessagingOperationPublish,
+		),
+	)
+	err := p.send(ctx, jsonData)
+	End(span, err)
+	return err
+}
+
+func (p *producer) send(ctx context.Context, jsonData []byte) error {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+	defer ch.Close()
+
+	mandatory := false
+	immediate := false
+	return ch.PublishWithContext(ctx, p.exchange, "", mandatory, immediate, newPublishing(ctx, jsonData, time.Now()))
+}
+
+// newPublishing prepares a message with the given JSON body, published at the given
+// time, carrying the trace context from ctx in its headers
+func newPublishing(ctx context.Context, jsonData []byte, now time.Time) amqp.Publishing {
+	headers := amqp.Table{}
+	propagator.Inject(ctx, headerCarrier(headers))
+	return amqp.Publishing{
+		ContentType: "application/json",
+		Headers:     headers,
+		Timestamp:   now,
+		Body:        jsonData,
+	}
+}
+
+// StartConsume begins a span for the handling of a message consumed from the given
+// exchange, continuing the trace whose context is carried in the message's headers
+func StartConsume(ctx context.Context, exchange string, d *amqp.Delivery) (context.Context, trace.Span) {
+	if d.Headers != nil {
+		ctx = propagator.Extract(ctx, headerCarrier(d.Headers))
+	}
+	return Start(ctx, exchange+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingDestinationName(exchange),
+			attribute.String("messaging.operation", "process"),
+		),
+	)
+}
+
+// headerCarrier adapts the headers of an AMQP message so that trace context can be
+// read from and written to them
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	if value, ok := c[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_headerCarrier(t *testing.T) {
+	recorder := recordSpans(t)
+
+	// Trace context injected into message headers by a producer should be extracted
+	// by a consumer, so that the consumer's span belongs to the same trace
+	ctx, producerSpan := Start(context.Background(), "broadcast-events publish")
+	headers := amqp.Table{}
+	propagator.Inject(ctx, headerCarrier(headers))
+	producerSpan.End()
+	assert.Contains(t, headers, "traceparent")
+
+	_, consumerSpan := StartConsume(context.Background(), "broadcast-events", &amqp.Delivery{Headers: headers})
+	consumerSpan.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "broadcast-events process", spans[1].Name())
+	assert.Equal(t, trace.SpanKindConsumer, spans[1].SpanKind())
+	assert.Equal(t, producerSpan.SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	assert.Equal(t, producerSpan.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	assert.True(t, spans[1].Parent().IsRemote())
+}
+
+func Test_newPublishing(t *testing.T) {
+	ctx := UnmarshalTraceContext(context.Background(), []byte(`{"traceparent":"`+traceparent+`"}`))
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	publishing := newPublishing(ctx, []byte(`{"n":1}`), now)
+	assert.Equal(t, "application/json", publishing.ContentType)
+	assert.Equal(t, traceparent, publishing.Headers["traceparent"])
+	assert.Equal(t, now, publishing.Timestamp)
+	assert.Equal(t, []byte(`{"n":1}`), publishing.Body)
+}
+
+func Test_StartConsume(t *testing.T) {
+	recorder := recordSpans(t)
+
+	t.Run("message with traceparent header continues trace", func(t *testing.T) {
+		_, span := StartConsume(context.Background(), "twitch-events", &amqp.Delivery{
+			Headers: amqp.Table{"traceparent": traceparent},
+		})
+		span.End()
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	})
+
+	t.Run("message without headers starts a new trace", func(t *testing.T) {
+		_, span := StartConsume(context.Background(), "twitch-events", &amqp.Delivery{})
+		span.End()
+		assert.True(t, span.SpanContext().IsValid())
+		assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	})
+
+	t.Run("non-string header values are ignored", func(t *testing.T) {
+		_, span := StartConsume(context.Background(), "twitch-events", &amqp.Delivery{
+			Headers: amqp.Table{"traceparent": int32(42)},
+		})
+		span.End()
+		assert.False(t, recorder.Ended()[len(recorder.Ended())-1].Parent().IsValid())
+	})
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WrapDB wraps a database or transaction so that each query run through it is recorded
+// as a span. Spans are named for the sqlc query, e.g. GetBroadcastDataEx, as
+// identified by the '-- name:' comment at the start of each generated statement.
+//
+// A span for QueryContext or QueryRowContext covers the time taken to execute the
+// query, not the time taken to read its results.
+func WrapDB(db queries.DBTX) queries.DBTX {
+	return &tracedDB{db}
+}
+
+type tracedDB struct {
+	db queries.DBTX
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	result, err := t.db.ExecContext(ctx, query, args...)
+	End(span, err)
+	return result, err
+}
+
+func (t *tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuery(ctx, query)
+	stmt, err := t.db.PrepareContext(ctx, query)
+	End(span, err)
+	return stmt, err
+}
+
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	End(span, err)
+	return rows, err
+}
+
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := t.db.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	End(span, err)
+	return row
+}
+
+// startQuery begins a client span for the given SQL statement
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(name),
+			attribute.String("db.statement", query),
+		),
+	)
+}
+
+// queryName returns the name of an sqlc query, given a statement that begins with a
+// comment of the form '-- name: GetFoo :one'; or "query" for any other statement
+func queryName(query string) string {
+	const prefix = "-- name: "
+	if !strings.HasPrefix(query, prefix) {
+		return "query"
+	}
+	fields := strings.Fields(query[len(prefix):])
+	if len(fields) == 0 {
+		return "query"
+	}
+	return fields[0]
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_queryName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"-- name: GetBroadcastDataEx :many\nselect 1", "GetBroadcastDataEx"},
+		{"-- name: EndScreening :execresult\nupdate foo", "EndScreening"},
+		{"-- name: ", "query"},
+		{"select 1", "query"},
+		{"", "query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, queryName(tt.query))
+		})
+	}
+}
+
+func Test_WrapDB(t *testing.T) {
+	recorder := recordSpans(t)
+	ctx, parent := Start(context.Background(), "parent")
+
+	mock := &mockDBTX{}
+	db := WrapDB(mock)
+	_, err := db.ExecContext(ctx, "-- name: EndScreening :execresult\nupdate foo", 1)
+	assert.NoError(t, err)
+	mock.err = fmt.Errorf("db is down")
+	_, err = db.ExecContext(ctx, "-- name: EndBroadcast :execresult\nupdate bar", 2)
+	assert.EqualError(t, err, "db is down")
+	parent.End()
+
+	// Each query should be recorded as a child of the caller's span, and the
+	// underlying database should be called with a context that carries that span
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "EndScreening", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.system", "postgresql"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.operation", "EndScreening"))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, "EndBroadcast", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, []trace.SpanID{spans[0].SpanContext().SpanID(), spans[1].SpanContext().SpanID()}, mock.spanIds)
+}
+
+type mockDBTX struct {
+	err     error
+	spanIds []trace.SpanID
+}
+
+func (m *mockDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	m.spanIds = append(m.spanIds, trace.SpanContextFromContext(ctx).SpanID())
+	return nil, m.err
+}
+
+func (m *mockDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (m *mockDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (m *mockDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golden-vcr/auth"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware is a mux middleware that records a span for each request, continuing the
+// caller's trace if the request carries a traceparent header. Spans are named for the
+// route's path template, e.g. GET /history/{id}.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route := "unknown"
+		if r := mux.CurrentRoute(req); r != nil {
+			if template, err := r.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := Start(ctx, req.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(req.Method),
+				semconv.HTTPRoute(route),
+			),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: res}
+		next.ServeHTTP(recorder, req.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// statusRecorder wraps an http.ResponseWriter in order to intercept the HTTP status
+// code for the response to a request
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// InstrumentAuthClient wraps an auth.Client so that each call to the auth API is
+// recorded as a span
+func InstrumentAuthClient(c auth.Client) auth.Client {
+	return &tracedAuthClient{c}
+}
+
+type tracedAuthClient struct {
+	c auth.Client
+}
+
+func (t *tracedAuthClient) CheckAccess(ctx context.Context, accessToken string) (*auth.AccessClaims, error) {
+	ctx, span := Start(ctx, "auth.CheckAccess", trace.WithSpanKind(trace.SpanKindClient))
+	claims, err := t.c.CheckAccess(ctx, accessToken)
+	if err == nil {
+		span.SetAttributes(attribute.String("auth.role", string(claims.Role)))
+	}
+	End(span, err)
+	return claims, err
+}
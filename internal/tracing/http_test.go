@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golden-vcr/auth"
+	authmock "github.com/golden-vcr/auth/mock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_Middleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		header         http.Header
+		wantName       string
+		wantStatus     int64
+		wantStatusCode codes.Code
+		wantRemote     bool
+	}{
+		{
+			"span is named for route template",
+			http.MethodGet,
+			"/history/42",
+			nil,
+			"GET /history/{id}",
+			http.StatusOK,
+			codes.Unset,
+			false,
+		},
+		{
+			"server error marks span as failed",
+			http.MethodPost,
+			"/fail",
+			nil,
+			"POST /fail",
+			http.StatusInternalServerError,
+			codes.Error,
+			false,
+		},
+		{
+			"client error does not mark span as failed",
+			http.MethodGet,
+			"/history/nope",
+			nil,
+			"GET /history/{id}",
+			http.StatusBadRequest,
+			codes.Unset,
+			false,
+		},
+		{
+			"caller's trace is continued",
+			http.MethodGet,
+			"/history/42",
+			http.Header{"Traceparent": {traceparent}},
+			"GET /history/{id}",
+			http.StatusOK,
+			codes.Unset,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+
+			var handlerSpan trace.SpanContext
+			r := mux.NewRouter()
+			r.Use(Middleware)
+			r.Path("/history/{id}").Methods("GET").HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(req.Context())
+				if mux.Vars(req)["id"] == "nope" {
+					http.Error(res, "bad id", http.StatusBadRequest)
+				}
+			})
+			r.Path("/fail").Methods("POST").HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(req.Context())
+				http.Error(res, "oh no", http.StatusInternalServerError)
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+
+			spans := recorder.Ended()
+			assert.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tt.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Contains(t, span.Attributes(), attribute.Int64("http.status_code", tt.wantStatus))
+			assert.Equal(t, tt.wantStatusCode, span.Status().Code)
+			assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID())
+			assert.Equal(t, tt.wantRemote, span.Parent().IsRemote())
+			if tt.wantRemote {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+			}
+		})
+	}
+}
+
+func Test_InstrumentAuthClient(t *testing.T) {
+	recorder := recordSpans(t)
+	c := InstrumentAuthClient(authmock.NewClient().AllowTwitchUserAccessToken("mock-token", auth.RoleBroadcaster, auth.UserDetails{}))
+
+	claims, err := c.CheckAccess(context.Background(), "mock-token")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.RoleBroadcaster, claims.Role)
+
+	_, err = c.CheckAccess(context.Background(), "bad-token")
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "auth.CheckAccess", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("auth.role", string(auth.RoleBroadcaster)))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, "auth.CheckAccess", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
@@ -0,0 +1,113 @@
+// Package tracing configures OpenTelemetry tracing for the broadcasts server and
+// consumer, and provides helpers that record spans for HTTP requests, database queries,
+// and AMQP messages. Trace context is propagated in W3C Trace Context format, both in
+// HTTP headers and in the headers of messages sent to broadcast-events.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exporters that can be selected via Init
+const (
+	// ExporterNone disables tracing: trace context is still propagated, but no spans are
+	// recorded
+	ExporterNone = "none"
+	// ExporterOtlp sends spans to an OTLP collector over HTTP, configured via the
+	// standard OTEL_EXPORTER_OTLP_* environment variables
+	ExporterOtlp = "otlp"
+	// ExporterStdout writes spans to stdout, for local development
+	ExporterStdout = "stdout"
+)
+
+// instrumentationName identifies the spans recorded by this module
+const instrumentationName = "github.com/golden-vcr/broadcasts"
+
+// propagator reads and writes trace context in W3C Trace Context format
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init configures the global tracer provider to export spans via the named exporter,
+// identifying them as originating from the given service. The returned function
+// flushes any buffered spans and should be called before the process exits.
+func Init(ctx context.Context, serviceName string, exporter string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOtlp:
+		e, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize OTLP exporter: %w", err)
+		}
+		spanExporter = e
+	case ExporterStdout:
+		e, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize stdout exporter: %w", err)
+		}
+		spanExporter = e
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter '%s'", exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start begins a new span with the given name, as a child of any span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End ends the given span, marking it as failed if err is non-nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// MarshalTraceContext serializes the trace context carried by ctx as a JSON object,
+// so that it can be stored alongside data that will be processed later
+func MarshalTraceContext(ctx context.Context) (json.RawMessage, error) {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return json.Marshal(carrier)
+}
+
+// UnmarshalTraceContext returns a copy of ctx that carries the trace context encoded
+// by MarshalTraceContext. If data is empty or invalid, ctx is returned unmodified.
+func UnmarshalTraceContext(ctx context.Context, data json.RawMessage) context.Context {
+	carrier := propagation.MapCarrier{}
+	if len(data) == 0 || json.Unmarshal(data, &carrier) != nil {
+		return ctx
+	}
+	return propagator.Extract(ctx, carrier)
+}
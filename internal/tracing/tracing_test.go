@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceparent is a valid W3C traceparent header value, used as the remote parent of
+// spans recorded in tests
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func Test_Init(t *testing.T) {
+	t.Run("no exporter is a no-op", func(t *testing.T) {
+		shutdown, err := Init(context.Background(), "test", ExporterNone)
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+	t.Run("unknown exporter is an error", func(t *testing.T) {
+		_, err := Init(context.Background(), "test", "carrier-pigeon")
+		assert.EqualError(t, err, "unsupported trace exporter 'carrier-pigeon'")
+	})
+}
+
+func Test_End(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = Start(context.Background(), "failed")
+	End(span, fmt.Errorf("oh no"))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "oh no", spans[1].Status().Description)
+}
+
+func Test_TraceContext(t *testing.T) {
+	recorder := recordSpans(t)
+
+	t.Run("trace context survives a round trip", func(t *testing.T) {
+		ctx, span := Start(context.Background(), "original")
+		data, err := MarshalTraceContext(ctx)
+		span.End()
+		assert.NoError(t, err)
+
+		restored := UnmarshalTraceContext(context.Background(), data)
+		_, child := Start(restored, "child")
+		child.End()
+
+		spans := recorder.Ended()
+		assert.Equal(t, span.SpanContext().TraceID(), spans[len(spans)-1].SpanContext().TraceID())
+		assert.Equal(t, span.SpanContext().SpanID(), spans[len(spans)-1].Parent().SpanID())
+	})
+
+	t.Run("context without a span marshals to an empty object", func(t *testing.T) {
+		data, err := MarshalTraceContext(context.Background())
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{}`, string(data))
+	})
+
+	t.Run("invalid trace context is ignored", func(t *testing.T) {
+		for _, data := range []json.RawMessage{nil, json.RawMessage(`{}`), json.RawMessage(`not json`)} {
+			ctx := UnmarshalTraceContext(context.Background(), data)
+			assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+		}
+	})
+}
+
+// recordSpans installs a tracer provider that records every span, restoring the
+// previous provider once the test is complete
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/outbox"
+	"github.com/golden-vcr/broadcasts/internal/tracing"
+	"github.com/golden-vcr/server-common/hmac"
+	"golang.org/x/exp/slog"
+)
+
+// batchSize is the maximum number of due deliveries that we'll attempt in a single
+// transaction
+const batchSize = 20
+
+// pollInterval is how frequently we'll check for due deliveries in the absence of any
+// notifications: this is what drives retries, since a delivery that's failed becomes
+// due again some time after the transaction that scheduled it
+const pollInterval = 5 * time.Second
+
+// maxAttempts is the number of times we'll attempt to deliver an event to a subscriber
+// before giving up on it; a delivery that's been given up on may be replayed by hand
+const maxAttempts = 8
+
+// baseRetryDelay is how long we wait before retrying a failed delivery for the first
+// time; the delay doubles with each subsequent failure, up to maxRetryDelay
+const baseRetryDelay = 30 * time.Second
+
+// maxRetryDelay is the longest we'll ever wait between attempts
+const maxRetryDelay = time.Hour
+
+// DispatchQueries is the subset of queries required to deliver webhooks
+type DispatchQueries interface {
+	GetDueWebhookDeliveries(ctx context.Context, limit int32) ([]queries.GetDueWebhookDeliveriesRow, error)
+	MarkWebhookDeliverySucceeded(ctx context.Context, arg queries.MarkWebhookDeliverySucceededParams) (sql.Result, error)
+	MarkWebhookDeliveryFailed(ctx context.Context, arg queries.MarkWebhookDeliveryFailedParams) (sql.Result, error)
+}
+
+// Dispatcher delivers each event that's scheduled for delivery in the
+// broadcasts.webhook_delivery table, by POSTing the event to the subscriber's URL with
+// an HMAC signature computed from the subscriber's secret, so that the subscriber can
+// verify the request with hmac.Verifier. If an attempt fails, it's retried
+// with exponential backoff, so delivery is at-least-once up to maxAttempts. Retries
+// mean that a subscriber may receive events out of order: each event carries a seq, so
+// subscribers can detect this if they care to.
+type Dispatcher struct {
+	logger   *slog.Logger
+	db       *sql.DB
+	client   *http.Client
+	notifyCh chan struct{}
+}
+
+// NewDispatcher initializes a Dispatcher that will read due deliveries from the given
+// database and send them using the given HTTP client, once Run is called
+func NewDispatcher(logger *slog.Logger, db *sql.DB, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		logger:   logger,
+		db:       db,
+		client:   client,
+		notifyCh: make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the dispatcher so that it will immediately check for due deliveries; it
+// never blocks
+func (d *Dispatcher) Notify() {
+	select {
+	case d.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run blocks until the given context is canceled, delivering webhooks whenever notified
+// and at a regular interval
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	d.flush(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			d.logger.Info("Context canceled; webhook dispatcher shutting down")
+			return
+		case <-d.notifyCh:
+			d.flush(ctx)
+		case <-ticker.C:
+			d.flush(ctx)
+		}
+	}
+}
+
+// flush attempts batches of due deliveries until none remain or an error occurs
+func (d *Dispatcher) flush(ctx context.Context) {
+	for {
+		numAttempted, err := d.dispatchBatch(ctx)
+		if err != nil {
+			d.logger.Error("Failed to dispatch webhooks", "error", err)
+			return
+		}
+		if numAttempted < batchSize {
+			return
+		}
+	}
+}
+
+// dispatchBatch opens a transaction in which a batch of due deliveries is locked,
+// attempted, and updated with the results, returning the number of deliveries that
+// were attempted
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	numAttempted, err := dispatchDeliveries(ctx, d.logger, queries.New(tracing.WrapDB(tx)), d.client, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return numAttempted, nil
+}
+
+// dispatchDeliveries attempts each due delivery concurrently, then records the result
+// of each attempt, scheduling a retry for any delivery that failed. Failure to deliver
+// a webhook is not an error: an error is only returned if we can't query or update the
+// database.
+func dispatchDeliveries(ctx context.Context, logger *slog.Logger, q DispatchQueries, client *http.Client, now time.Time) (int, error) {
+	rows, err := q.GetDueWebhookDeliveries(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]result, len(rows))
+	wg := sync.WaitGroup{}
+	for i := range rows {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = deliver(ctx, client, &rows[i], now)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, row := range rows {
+		if err := recordResult(ctx, q, &row, results[i], now); err != nil {
+			return i, err
+		}
+		if results[i].err != nil {
+			logger.Warn("Failed to deliver webhook",
+				"deliveryId", row.ID,
+				"url", row.Url,
+				"attempt", row.NumAttempts+1,
+				"error", results[i].err,
+			)
+		}
+	}
+	return len(rows), nil
+}
+
+// result describes the outcome of a single delivery attempt
+type result struct {
+	status int
+	err    error
+}
+
+// deliver POSTs the event to the subscriber, signed with the subscriber's secret. A
+// response with a 2xx status indicates success.
+func deliver(ctx context.Context, client *http.Client, row *queries.GetDueWebhookDeliveriesRow, now time.Time) result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, row.Url, bytes.NewReader(row.Payload))
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set(hmac.HeaderRequestTimestamp, now.Format(time.RFC3339))
+	req, err = hmac.NewSigner(row.Secret).Sign(req, row.Payload)
+	if err != nil {
+		return result{err: err}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return result{err: err}
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return result{status: res.StatusCode, err: fmt.Errorf("got response with status %d", res.StatusCode)}
+	}
+	return result{status: res.StatusCode}
+}
+
+// recordResult updates the delivery to reflect the outcome of an attempt: if it failed
+// and we have attempts remaining, it's rescheduled after a delay; otherwise it's marked
+// as failed for good
+func recordResult(ctx context.Context, q DispatchQueries, row *queries.GetDueWebhookDeliveriesRow, r result, now time.Time) error {
+	var dbResult sql.Result
+	var err error
+	if r.err == nil {
+		dbResult, err = q.MarkWebhookDeliverySucceeded(ctx, queries.MarkWebhookDeliverySucceededParams{
+			Status:     int32(r.status),
+			DeliveryID: row.ID,
+		})
+	} else {
+		arg := queries.MarkWebhookDeliveryFailedParams{
+			Status:     sql.NullInt32{Valid: r.status != 0, Int32: int32(r.status)},
+			Error:      r.err.Error(),
+			DeliveryID: row.ID,
+		}
+		numAttempts := int(row.NumAttempts) + 1
+		if numAttempts < maxAttempts {
+			arg.NextAttemptAt = sql.NullTime{Valid: true, Time: now.Add(retryDelay(numAttempts))}
+		}
+		dbResult, err = q.MarkWebhookDeliveryFailed(ctx, arg)
+	}
+	if err != nil {
+		return err
+	}
+	numRowsAffected, err := dbResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numRowsAffected != int64(1) {
+		return fmt.Errorf("failed to record result of webhook delivery %d: expected to affect 1 rows; instead affected %d", row.ID, numRowsAffected)
+	}
+	return nil
+}
+
+// retryDelay returns how long we should wait to retry a delivery that's failed the
+// given number of times
+func retryDelay(numAttempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < numAttempts; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+var _ outbox.Notifier = (*Dispatcher)(nil)
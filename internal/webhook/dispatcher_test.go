@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/server-common/hmac"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+func Test_dispatchDeliveries(t *testing.T) {
+	now := time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)
+	payload := json.RawMessage(`{"type":"broadcast-finished","broadcast":{"id":55},"seq":42}`)
+
+	tests := []struct {
+		name          string
+		status        int
+		numAttempts   int32
+		wantSucceeded []queries.MarkWebhookDeliverySucceededParams
+		wantFailed    []queries.MarkWebhookDeliveryFailedParams
+	}{
+		{
+			"successful delivery is recorded",
+			http.StatusNoContent,
+			0,
+			[]queries.MarkWebhookDeliverySucceededParams{
+				{Status: http.StatusNoContent, DeliveryID: 1},
+			},
+			nil,
+		},
+		{
+			"failed delivery is retried after a delay",
+			http.StatusInternalServerError,
+			0,
+			nil,
+			[]queries.MarkWebhookDeliveryFailedParams{
+				{
+					NextAttemptAt: sql.NullTime{Valid: true, Time: now.Add(30 * time.Second)},
+					Status:        sql.NullInt32{Valid: true, Int32: http.StatusInternalServerError},
+					Error:         "got response with status 500",
+					DeliveryID:    1,
+				},
+			},
+		},
+		{
+			"retry delay increases with each failure",
+			http.StatusBadGateway,
+			3,
+			nil,
+			[]queries.MarkWebhookDeliveryFailedParams{
+				{
+					NextAttemptAt: sql.NullTime{Valid: true, Time: now.Add(4 * time.Minute)},
+					Status:        sql.NullInt32{Valid: true, Int32: http.StatusBadGateway},
+					Error:         "got response with status 502",
+					DeliveryID:    1,
+				},
+			},
+		},
+		{
+			"delivery is given up on after max attempts",
+			http.StatusInternalServerError,
+			maxAttempts - 1,
+			nil,
+			[]queries.MarkWebhookDeliveryFailedParams{
+				{
+					Status:     sql.NullInt32{Valid: true, Int32: http.StatusInternalServerError},
+					Error:      "got response with status 500",
+					DeliveryID: 1,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotReq *http.Request
+			var gotBody []byte
+			s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				gotReq = req
+				gotBody, _ = io.ReadAll(req.Body)
+				res.WriteHeader(tt.status)
+			}))
+			defer s.Close()
+
+			q := &mockDispatchQueries{
+				due: []queries.GetDueWebhookDeliveriesRow{
+					{ID: 1, NumAttempts: tt.numAttempts, Url: s.URL, Secret: "my-secret", Payload: payload},
+				},
+			}
+			numAttempted, err := dispatchDeliveries(context.Background(), slog.Default(), q, s.Client(), now)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, numAttempted)
+			assert.Equal(t, tt.wantSucceeded, q.succeeded)
+			assert.Equal(t, tt.wantFailed, q.failed)
+
+			// The subscriber should have received the payload verbatim, with a valid
+			// signature
+			assert.Equal(t, string(payload), string(gotBody))
+			assert.Equal(t, "application/json", gotReq.Header.Get("content-type"))
+			assert.Equal(t, "1997-09-01T12:00:00Z", gotReq.Header.Get(hmac.HeaderRequestTimestamp))
+			assert.NoError(t, hmac.NewVerifier("my-secret").Verify(gotReq, gotBody))
+			assert.Error(t, hmac.NewVerifier("other-secret").Verify(gotReq, gotBody))
+		})
+	}
+
+	t.Run("unreachable subscriber is recorded as a failure with no status", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+		url := s.URL
+		s.Close()
+
+		q := &mockDispatchQueries{
+			due: []queries.GetDueWebhookDeliveriesRow{
+				{ID: 7, NumAttempts: 0, Url: url, Secret: "my-secret", Payload: payload},
+			},
+		}
+		numAttempted, err := dispatchDeliveries(context.Background(), slog.Default(), q, http.DefaultClient, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, numAttempted)
+		assert.Len(t, q.failed, 1)
+		assert.Equal(t, int64(7), q.failed[0].DeliveryID)
+		assert.False(t, q.failed[0].Status.Valid)
+		assert.NotEmpty(t, q.failed[0].Error)
+		assert.True(t, q.failed[0].NextAttemptAt.Valid)
+	})
+
+	t.Run("all due deliveries are attempted", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/bad" {
+				res.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		defer s.Close()
+
+		q := &mockDispatchQueries{
+			due: []queries.GetDueWebhookDeliveriesRow{
+				{ID: 1, Url: s.URL + "/good", Secret: "a", Payload: payload},
+				{ID: 2, Url: s.URL + "/bad", Secret: "b", Payload: payload},
+				{ID: 3, Url: s.URL + "/good", Secret: "c", Payload: payload},
+			},
+		}
+		numAttempted, err := dispatchDeliveries(context.Background(), slog.Default(), q, s.Client(), now)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, numAttempted)
+		assert.Equal(t, []int64{1, 3}, q.succeededIds())
+		assert.Len(t, q.failed, 1)
+		assert.Equal(t, int64(2), q.failed[0].DeliveryID)
+	})
+
+	t.Run("failure to query due deliveries is an error", func(t *testing.T) {
+		q := &mockDispatchQueries{err: fmt.Errorf("db is down")}
+		_, err := dispatchDeliveries(context.Background(), slog.Default(), q, http.DefaultClient, now)
+		assert.EqualError(t, err, "db is down")
+	})
+}
+
+func Test_retryDelay(t *testing.T) {
+	tests := []struct {
+		numAttempts int
+		want        time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d attempts", tt.numAttempts), func(t *testing.T) {
+			assert.Equal(t, tt.want, retryDelay(tt.numAttempts))
+		})
+	}
+}
+
+type mockDispatchQueries struct {
+	err       error
+	due       []queries.GetDueWebhookDeliveriesRow
+	succeeded []queries.MarkWebhookDeliverySucceededParams
+	failed    []queries.MarkWebhookDeliveryFailedParams
+	mu        sync.Mutex
+}
+
+func (m *mockDispatchQueries) GetDueWebhookDeliveries(ctx context.Context, limit int32) ([]queries.GetDueWebhookDeliveriesRow, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.due, nil
+}
+
+func (m *mockDispatchQueries) MarkWebhookDeliverySucceeded(ctx context.Context, arg queries.MarkWebhookDeliverySucceededParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.succeeded = append(m.succeeded, arg)
+	return driverResult(1), nil
+}
+
+func (m *mockDispatchQueries) MarkWebhookDeliveryFailed(ctx context.Context, arg queries.MarkWebhookDeliveryFailedParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed = append(m.failed, arg)
+	return driverResult(1), nil
+}
+
+func (m *mockDispatchQueries) succeededIds() []int64 {
+	ids := make([]int64, 0, len(m.succeeded))
+	for _, arg := range m.succeeded {
+		ids = append(ids, arg.DeliveryID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) {
+	return 0, fmt.Errorf("not supported")
+}
+
+func (r driverResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/outbox"
+	"github.com/golden-vcr/server-common/entry"
+	"github.com/gorilla/mux"
+)
+
+// secretLength is the number of random bytes in each subscriber's secret
+const secretLength = 32
+
+// Queries is the subset of queries required to manage webhook subscribers
+type Queries interface {
+	CreateWebhookSubscriber(ctx context.Context, arg queries.CreateWebhookSubscriberParams) (queries.CreateWebhookSubscriberRow, error)
+	GetWebhookSubscribers(ctx context.Context) ([]queries.GetWebhookSubscribersRow, error)
+	DeleteWebhookSubscriber(ctx context.Context, subscriberID int32) (sql.Result, error)
+	GetWebhookDeliveries(ctx context.Context, arg queries.GetWebhookDeliveriesParams) ([]queries.GetWebhookDeliveriesRow, error)
+	ReplayFailedWebhookDeliveries(ctx context.Context, subscriberID int32) (sql.Result, error)
+}
+
+type Server struct {
+	q        Queries
+	notifier outbox.Notifier
+}
+
+// NewServer returns a Server that manages webhook subscribers in the given database.
+// The notifier is notified whenever failed deliveries are replayed, so that they can
+// be dispatched without delay.
+func NewServer(q *queries.Queries, notifier outbox.Notifier) *Server {
+	return &Server{
+		q:        q,
+		notifier: notifier,
+	}
+}
+
+func (s *Server) RegisterRoutes(c auth.Client, r *mux.Router) {
+	// Require broadcaster access for all webhook admin routes
+	r.Use(func(next http.Handler) http.Handler {
+		return auth.RequireAccess(c, auth.RoleBroadcaster, next)
+	})
+
+	// GET and POST allow the broadcaster to list and register subscribers, and DELETE
+	// /{id} removes a subscriber so that it receives no further deliveries
+	r.Path("").Methods("GET").HandlerFunc(s.handleGetSubscribers)
+	r.Path("").Methods("POST").HandlerFunc(s.handleCreateSubscriber)
+	r.Path("/{id}").Methods("DELETE").HandlerFunc(s.handleDeleteSubscriber)
+
+	// GET /{id}/deliveries returns the subscriber's delivery log, and POST /{id}/replay
+	// reschedules any deliveries that we've given up on
+	r.Path("/{id}/deliveries").Methods("GET").HandlerFunc(s.handleGetDeliveries)
+	r.Path("/{id}/replay").Methods("POST").HandlerFunc(s.handleReplay)
+}
+
+func (s *Server) handleGetSubscribers(res http.ResponseWriter, req *http.Request) {
+	rows, err := s.q.GetWebhookSubscribers(req.Context())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := SubscriberList{
+		Subscribers: make([]Subscriber, 0, len(rows)),
+	}
+	for _, row := range rows {
+		result.Subscribers = append(result.Subscribers, Subscriber{
+			Id:        int(row.ID),
+			Url:       row.Url,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	if err := json.NewEncoder(res).Encode(result); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleCreateSubscriber(res http.ResponseWriter, req *http.Request) {
+	// Parse the URL of the new subscriber from the request body, requiring an absolute
+	// HTTP(S) URL
+	var payload struct {
+		Url string `json:"url"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		http.Error(res, "invalid request payload", http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(payload.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(res, "'url' must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	// Generate a random secret that the subscriber can use to verify our signatures
+	secret, err := generateSecret()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Register the subscriber, then return its details: this is the only time the
+	// secret is revealed, so the caller must make a note of it
+	row, err := s.q.CreateWebhookSubscriber(req.Context(), queries.CreateWebhookSubscriberParams{
+		Url:    payload.Url,
+		Secret: secret,
+	})
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	subscriber := NewSubscriber{
+		Subscriber: Subscriber{
+			Id:        int(row.ID),
+			Url:       payload.Url,
+			CreatedAt: row.CreatedAt,
+		},
+		Secret: secret,
+	}
+	entry.Log(req).Info("Registered webhook subscriber", "subscriber", subscriber.Subscriber)
+	if err := json.NewEncoder(res).Encode(subscriber); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleDeleteSubscriber(res http.ResponseWriter, req *http.Request) {
+	subscriberId, ok := parseSubscriberId(res, req)
+	if !ok {
+		return
+	}
+
+	result, err := s.q.DeleteWebhookSubscriber(req.Context(), subscriberId)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if numRowsAffected == 0 {
+		http.Error(res, "no such subscriber", http.StatusNotFound)
+		return
+	}
+	entry.Log(req).Info("Deleted webhook subscriber", "subscriberId", subscriberId)
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetDeliveries(res http.ResponseWriter, req *http.Request) {
+	subscriberId, ok := parseSubscriberId(res, req)
+	if !ok {
+		return
+	}
+
+	// Accept 'n' and 'before' query params to scope our request and allow pagination
+	arg := queries.GetWebhookDeliveriesParams{
+		SubscriberID: subscriberId,
+		Limit:        20,
+	}
+	if nStr := req.URL.Query().Get("n"); nStr != "" {
+		if n, err := strconv.Atoi(nStr); err == nil && n > 0 && n <= 100 {
+			arg.Limit = int32(n)
+		}
+	}
+	if beforeStr := req.URL.Query().Get("before"); beforeStr != "" {
+		if before, err := strconv.ParseInt(beforeStr, 10, 64); err == nil {
+			arg.BeforeDeliveryID.Valid = true
+			arg.BeforeDeliveryID.Int64 = before
+		}
+	}
+
+	rows, err := s.q.GetWebhookDeliveries(req.Context(), arg)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := DeliveryLog{
+		Deliveries: make([]Delivery, 0, len(rows)),
+	}
+	for i := range rows {
+		result.Deliveries = append(result.Deliveries, deliveryFromRow(&rows[i]))
+	}
+	if err := json.NewEncoder(res).Encode(result); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleReplay(res http.ResponseWriter, req *http.Request) {
+	subscriberId, ok := parseSubscriberId(res, req)
+	if !ok {
+		return
+	}
+
+	// Reschedule all failed deliveries for immediate delivery, then wake the dispatcher
+	result, err := s.q.ReplayFailedWebhookDeliveries(req.Context(), subscriberId)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	numRowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if numRowsAffected > 0 {
+		s.notifier.Notify()
+	}
+	entry.Log(req).Info("Replayed failed webhook deliveries", "subscriberId", subscriberId, "numReplayed", numRowsAffected)
+	if err := json.NewEncoder(res).Encode(ReplayResult{NumReplayed: int(numRowsAffected)}); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseSubscriberId gets the subscriber ID from the request URL, responding with an
+// error and returning false if it's invalid
+func parseSubscriberId(res http.ResponseWriter, req *http.Request) (int32, bool) {
+	subscriberIdStr, ok := mux.Vars(req)["id"]
+	if !ok || subscriberIdStr == "" {
+		http.Error(res, "failed to parse 'id' from URL", http.StatusInternalServerError)
+		return 0, false
+	}
+	subscriberId, err := strconv.ParseInt(subscriberIdStr, 10, 32)
+	if err != nil {
+		http.Error(res, "subscriber ID must be an integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return int32(subscriberId), true
+}
+
+// generateSecret returns a new random, hex-encoded secret for a subscriber
+func generateSecret() (string, error) {
+	b := make([]byte, secretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
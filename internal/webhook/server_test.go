@@ -0,0 +1,430 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/auth"
+	authmock "github.com/golden-vcr/auth/mock"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server_RegisterRoutes(t *testing.T) {
+	authClient := authmock.NewClient().AllowTwitchUserAccessToken("viewer-token", auth.RoleViewer, auth.UserDetails{
+		Id:          "1234",
+		Login:       "viewer",
+		DisplayName: "Viewer",
+	}).AllowTwitchUserAccessToken("broadcaster-token", auth.RoleBroadcaster, auth.UserDetails{
+		Id:          "5678",
+		Login:       "broadcaster",
+		DisplayName: "Broadcaster",
+	})
+
+	// Mount our routes the same way the server does, alongside other admin routes
+	r := mux.NewRouter()
+	s := &Server{q: &mockQueries{}, notifier: &mockNotifier{}}
+	s.RegisterRoutes(authClient, r.PathPrefix("/admin/webhooks").Subrouter())
+	r.PathPrefix("/admin").Subrouter().Path("/broadcast").Methods("POST").HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"subscribers can be listed", http.MethodGet, "/admin/webhooks", "broadcaster-token", http.StatusOK},
+		{"delivery log can be fetched", http.MethodGet, "/admin/webhooks/3/deliveries", "broadcaster-token", http.StatusOK},
+		{"broadcaster access is required", http.MethodGet, "/admin/webhooks", "viewer-token", http.StatusForbidden},
+		{"other admin routes are unaffected", http.MethodPost, "/admin/broadcast", "", http.StatusTeapot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("authorization", "Bearer "+tt.token)
+			}
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, req)
+			assert.Equal(t, tt.wantStatus, res.Code)
+		})
+	}
+}
+
+func Test_Server_handleGetSubscribers(t *testing.T) {
+	tests := []struct {
+		name       string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"normal usage",
+			&mockQueries{
+				subscribers: []queries.GetWebhookSubscribersRow{
+					{ID: 1, Url: "https://example.com/hook", CreatedAt: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC)},
+				},
+			},
+			http.StatusOK,
+			`{"subscribers":[{"id":1,"url":"https://example.com/hook","createdAt":"1997-09-01T12:00:00Z"}]}`,
+		},
+		{
+			"no subscribers is an empty list",
+			&mockQueries{},
+			http.StatusOK,
+			`{"subscribers":[]}`,
+		},
+		{
+			"database error is a 500",
+			&mockQueries{err: fmt.Errorf("db is down")},
+			http.StatusInternalServerError,
+			"db is down",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{q: tt.q, notifier: &mockNotifier{}}
+			req := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+			res := httptest.NewRecorder()
+			s.handleGetSubscribers(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, readBody(t, res))
+		})
+	}
+}
+
+func Test_Server_handleCreateSubscriber(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+		wantUrl    string
+	}{
+		{
+			"normal usage",
+			`{"url":"https://example.com/hook"}`,
+			&mockQueries{},
+			http.StatusOK,
+			"",
+			"https://example.com/hook",
+		},
+		{
+			"payload must be valid JSON",
+			`not json`,
+			&mockQueries{},
+			http.StatusBadRequest,
+			"invalid request payload",
+			"",
+		},
+		{
+			"url is required",
+			`{}`,
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'url' must be an absolute http or https URL",
+			"",
+		},
+		{
+			"url must be absolute",
+			`{"url":"/hook"}`,
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'url' must be an absolute http or https URL",
+			"",
+		},
+		{
+			"url must use http or https",
+			`{"url":"ftp://example.com/hook"}`,
+			&mockQueries{},
+			http.StatusBadRequest,
+			"'url' must be an absolute http or https URL",
+			"",
+		},
+		{
+			"database error is a 500",
+			`{"url":"https://example.com/hook"}`,
+			&mockQueries{err: fmt.Errorf("db is down")},
+			http.StatusInternalServerError,
+			"db is down",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{q: tt.q, notifier: &mockNotifier{}}
+			req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(tt.body))
+			res := httptest.NewRecorder()
+			s.handleCreateSubscriber(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.Equal(t, tt.wantBody, readBody(t, res))
+				return
+			}
+
+			// The response should reveal a freshly-generated secret, which should match
+			// the secret that was stored for the new subscriber
+			var subscriber NewSubscriber
+			err := json.NewDecoder(res.Body).Decode(&subscriber)
+			assert.NoError(t, err)
+			assert.Equal(t, 9, subscriber.Id)
+			assert.Equal(t, tt.wantUrl, subscriber.Url)
+			assert.Len(t, subscriber.Secret, secretLength*2)
+			assert.Equal(t, []queries.CreateWebhookSubscriberParams{
+				{Url: tt.wantUrl, Secret: subscriber.Secret},
+			}, tt.q.created)
+		})
+	}
+}
+
+func Test_Server_handleDeleteSubscriber(t *testing.T) {
+	tests := []struct {
+		name       string
+		idStr      string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			"normal usage",
+			"3",
+			&mockQueries{numRowsAffected: 1},
+			http.StatusNoContent,
+			"",
+		},
+		{
+			"URL parameter must be a valid subscriber ID",
+			"bad-id",
+			&mockQueries{numRowsAffected: 1},
+			http.StatusBadRequest,
+			"subscriber ID must be an integer",
+		},
+		{
+			"nonexistent subscriber is a 404",
+			"3",
+			&mockQueries{numRowsAffected: 0},
+			http.StatusNotFound,
+			"no such subscriber",
+		},
+		{
+			"database error is a 500",
+			"3",
+			&mockQueries{err: fmt.Errorf("db is down")},
+			http.StatusInternalServerError,
+			"db is down",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{q: tt.q, notifier: &mockNotifier{}}
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/admin/webhooks/%s", tt.idStr), nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.idStr})
+			res := httptest.NewRecorder()
+			s.handleDeleteSubscriber(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, readBody(t, res))
+		})
+	}
+}
+
+func Test_Server_handleGetDeliveries(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		q          *mockQueries
+		wantStatus int
+		wantBody   string
+		wantArg    queries.GetWebhookDeliveriesParams
+	}{
+		{
+			"normal usage",
+			"",
+			&mockQueries{
+				deliveries: []queries.GetWebhookDeliveriesRow{
+					{
+						ID:            12,
+						Seq:           42,
+						EventType:     "broadcast-finished",
+						CreatedAt:     time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
+						NumAttempts:   8,
+						LastAttemptAt: sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC)},
+						LastStatus:    sql.NullInt32{Valid: true, Int32: 500},
+						LastError:     sql.NullString{Valid: true, String: "got response with status 500"},
+						FailedAt:      sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 13, 0, 0, 0, time.UTC)},
+					},
+					{
+						ID:            11,
+						Seq:           41,
+						EventType:     "broadcast-started",
+						CreatedAt:     time.Date(1997, 9, 1, 11, 0, 0, 0, time.UTC),
+						NumAttempts:   1,
+						LastAttemptAt: sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 11, 0, 0, 0, time.UTC)},
+						LastStatus:    sql.NullInt32{Valid: true, Int32: 204},
+						DeliveredAt:   sql.NullTime{Valid: true, Time: time.Date(1997, 9, 1, 11, 0, 0, 0, time.UTC)},
+					},
+				},
+			},
+			http.StatusOK,
+			`{"deliveries":[{"id":12,"seq":42,"eventType":"broadcast-finished","createdAt":"1997-09-01T12:00:00Z","numAttempts":8,"nextAttemptAt":null,"lastAttemptAt":"1997-09-01T13:00:00Z","lastStatus":500,"lastError":"got response with status 500","deliveredAt":null,"failedAt":"1997-09-01T13:00:00Z"},{"id":11,"seq":41,"eventType":"broadcast-started","createdAt":"1997-09-01T11:00:00Z","numAttempts":1,"nextAttemptAt":null,"lastAttemptAt":"1997-09-01T11:00:00Z","lastStatus":204,"deliveredAt":"1997-09-01T11:00:00Z","failedAt":null}]}`,
+			queries.GetWebhookDeliveriesParams{SubscriberID: 3, Limit: 20},
+		},
+		{
+			"pagination parameters are respected",
+			"?n=5&before=12",
+			&mockQueries{},
+			http.StatusOK,
+			`{"deliveries":[]}`,
+			queries.GetWebhookDeliveriesParams{
+				SubscriberID:     3,
+				BeforeDeliveryID: sql.NullInt64{Valid: true, Int64: 12},
+				Limit:            5,
+			},
+		},
+		{
+			"database error is a 500",
+			"",
+			&mockQueries{err: fmt.Errorf("db is down")},
+			http.StatusInternalServerError,
+			"db is down",
+			queries.GetWebhookDeliveriesParams{SubscriberID: 3, Limit: 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{q: tt.q, notifier: &mockNotifier{}}
+			req := httptest.NewRequest(http.MethodGet, "/admin/webhooks/3/deliveries"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "3"})
+			res := httptest.NewRecorder()
+			s.handleGetDeliveries(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, readBody(t, res))
+			assert.Equal(t, tt.wantArg, tt.q.deliveriesArg)
+		})
+	}
+}
+
+func Test_Server_handleReplay(t *testing.T) {
+	tests := []struct {
+		name         string
+		q            *mockQueries
+		wantStatus   int
+		wantBody     string
+		wantNotified bool
+	}{
+		{
+			"failed deliveries are replayed and the dispatcher is notified",
+			&mockQueries{numRowsAffected: 2},
+			http.StatusOK,
+			`{"numReplayed":2}`,
+			true,
+		},
+		{
+			"dispatcher is not notified if nothing needs replaying",
+			&mockQueries{numRowsAffected: 0},
+			http.StatusOK,
+			`{"numReplayed":0}`,
+			false,
+		},
+		{
+			"database error is a 500",
+			&mockQueries{err: fmt.Errorf("db is down")},
+			http.StatusInternalServerError,
+			"db is down",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &mockNotifier{}
+			s := &Server{q: tt.q, notifier: notifier}
+			req := httptest.NewRequest(http.MethodPost, "/admin/webhooks/3/replay", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "3"})
+			res := httptest.NewRecorder()
+			s.handleReplay(res, req)
+
+			assert.Equal(t, tt.wantStatus, res.Code)
+			assert.Equal(t, tt.wantBody, readBody(t, res))
+			assert.Equal(t, tt.wantNotified, notifier.notified)
+		})
+	}
+}
+
+func readBody(t *testing.T, res *httptest.ResponseRecorder) string {
+	b, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+type mockQueries struct {
+	err             error
+	subscribers     []queries.GetWebhookSubscribersRow
+	deliveries      []queries.GetWebhookDeliveriesRow
+	numRowsAffected int64
+	created         []queries.CreateWebhookSubscriberParams
+	deliveriesArg   queries.GetWebhookDeliveriesParams
+}
+
+func (m *mockQueries) CreateWebhookSubscriber(ctx context.Context, arg queries.CreateWebhookSubscriberParams) (queries.CreateWebhookSubscriberRow, error) {
+	if m.err != nil {
+		return queries.CreateWebhookSubscriberRow{}, m.err
+	}
+	m.created = append(m.created, arg)
+	return queries.CreateWebhookSubscriberRow{
+		ID:        9,
+		CreatedAt: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func (m *mockQueries) GetWebhookSubscribers(ctx context.Context) ([]queries.GetWebhookSubscribersRow, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.subscribers, nil
+}
+
+func (m *mockQueries) DeleteWebhookSubscriber(ctx context.Context, subscriberID int32) (sql.Result, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return driverResult(m.numRowsAffected), nil
+}
+
+func (m *mockQueries) GetWebhookDeliveries(ctx context.Context, arg queries.GetWebhookDeliveriesParams) ([]queries.GetWebhookDeliveriesRow, error) {
+	m.deliveriesArg = arg
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.deliveries, nil
+}
+
+func (m *mockQueries) ReplayFailedWebhookDeliveries(ctx context.Context, subscriberID int32) (sql.Result, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return driverResult(m.numRowsAffected), nil
+}
+
+type mockNotifier struct {
+	notified bool
+}
+
+func (m *mockNotifier) Notify() {
+	m.notified = true
+}
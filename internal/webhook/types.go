@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/golden-vcr/broadcasts/gen/queries"
+)
+
+// Subscriber describes an endpoint that's registered to receive webhooks
+type Subscriber struct {
+	Id        int       `json:"id"`
+	Url       string    `json:"url"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewSubscriber is the result of registering a new subscriber: it's the only time the
+// subscriber's secret is revealed
+type NewSubscriber struct {
+	Subscriber
+	Secret string `json:"secret"`
+}
+
+// SubscriberList is the result of listing all active subscribers
+type SubscriberList struct {
+	Subscribers []Subscriber `json:"subscribers"`
+}
+
+// Delivery describes the delivery of a single event to a subscriber
+type Delivery struct {
+	Id            int64      `json:"id"`
+	Seq           int64      `json:"seq"`
+	EventType     string     `json:"eventType"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	NumAttempts   int        `json:"numAttempts"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt"`
+	LastStatus    *int       `json:"lastStatus"`
+	LastError     string     `json:"lastError,omitempty"`
+	DeliveredAt   *time.Time `json:"deliveredAt"`
+	FailedAt      *time.Time `json:"failedAt"`
+}
+
+// DeliveryLog is the result of listing a subscriber's deliveries, most recent first
+type DeliveryLog struct {
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// ReplayResult is the result of replaying a subscriber's failed deliveries
+type ReplayResult struct {
+	NumReplayed int `json:"numReplayed"`
+}
+
+// deliveryFromRow converts a row from the delivery log to its JSON representation
+func deliveryFromRow(row *queries.GetWebhookDeliveriesRow) Delivery {
+	d := Delivery{
+		Id:          row.ID,
+		Seq:         row.Seq,
+		EventType:   row.EventType,
+		CreatedAt:   row.CreatedAt,
+		NumAttempts: int(row.NumAttempts),
+		LastError:   row.LastError.String,
+	}
+	if row.NextAttemptAt.Valid {
+		d.NextAttemptAt = &row.NextAttemptAt.Time
+	}
+	if row.LastAttemptAt.Valid {
+		d.LastAttemptAt = &row.LastAttemptAt.Time
+	}
+	if row.LastStatus.Valid {
+		status := int(row.LastStatus.Int32)
+		d.LastStatus = &status
+	}
+	if row.DeliveredAt.Valid {
+		d.DeliveredAt = &row.DeliveredAt.Time
+	}
+	if row.FailedAt.Valid {
+		d.FailedAt = &row.FailedAt.Time
+	}
+	return d
+}
@@ -0,0 +1,251 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/gorilla/websocket"
+	"golang.org/x/exp/slog"
+)
+
+// maxCommandSize is the largest message we'll accept from a client
+const maxCommandSize = 4096
+
+// sendBufferSize is the number of messages that may be queued for a client: if a
+// client falls this far behind, we assume it's unresponsive and close the connection
+const sendBufferSize = 32
+
+// pingInterval is how frequently we'll ping the client to keep the connection alive
+const pingInterval = 30 * time.Second
+
+// pongWait is how long we'll wait to hear from the client before we assume that the
+// connection has been lost; it must be longer than pingInterval
+const pongWait = 60 * time.Second
+
+// writeWait is how long we'll wait for a single message to be written to the client
+const writeWait = 10 * time.Second
+
+// conn represents a single client's WebSocket connection. Messages are only ever
+// written by writeLoop, which reads them from the send channel, and commands are only
+// ever read (and handled) by readLoop.
+type conn struct {
+	s      *Server
+	ws     *websocket.Conn
+	logger *slog.Logger
+
+	// token is the access token with which the client has authenticated, if any, and
+	// claims identifies the user to whom it belongs; they're only accessed from readLoop
+	token  string
+	claims *auth.AccessClaims
+
+	// sourceIp is the address of the client, recorded in the audit log along with the
+	// user's identity whenever they change broadcast state
+	sourceIp string
+
+	send      chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// lastVersion is the version of the last state that was sent to the client, so
+	// that we never send an older state after a newer one
+	lastVersion int64
+	versionMu   sync.Mutex
+}
+
+func newConn(s *Server, ws *websocket.Conn, logger *slog.Logger, token string, claims *auth.AccessClaims, sourceIp string) *conn {
+	return &conn{
+		s:        s,
+		ws:       ws,
+		logger:   logger,
+		token:    token,
+		claims:   claims,
+		sourceIp: sourceIp,
+		send:     make(chan Message, sendBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// close signals writeLoop to close the connection, which will in turn cause readLoop
+// to exit; it's safe to call more than once
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// enqueue queues a message to be sent to the client, closing the connection if the
+// client has fallen too far behind
+func (c *conn) enqueue(m Message) {
+	select {
+	case <-c.done:
+	case c.send <- m:
+	default:
+		c.logger.Warn("WebSocket client is not keeping up; closing connection")
+		c.close()
+	}
+}
+
+// sendState queues a state message, unless the client has already been sent the same
+// state or a newer one
+func (c *conn) sendState(st broadcasts.State) {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+
+	if c.lastVersion != 0 && st.Version <= c.lastVersion {
+		return
+	}
+	c.lastVersion = st.Version
+	c.enqueue(Message{
+		Type:  MessageTypeState,
+		State: &st,
+	})
+}
+
+// writeLoop writes queued messages to the client, pinging it periodically, until the
+// connection is closed
+func (c *conn) writeLoop() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer c.ws.Close()
+
+	for {
+		select {
+		case <-c.done:
+			deadline := time.Now().Add(writeWait)
+			c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+			return
+		case m := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteJSON(m); err != nil {
+				c.logger.Error("Failed to write WebSocket message", "error", err)
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(writeWait)
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// readLoop reads commands from the client and handles each one in turn, queueing a
+// reply for each, until the connection is closed
+func (c *conn) readLoop(ctx context.Context) {
+	defer c.close()
+
+	c.ws.SetReadLimit(maxCommandSize)
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Error("Failed to read WebSocket message", "error", err)
+			}
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		var cmd Command
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			c.enqueue(errorFor(&cmd, ErrorCodeBadRequest, fmt.Sprintf("failed to parse command: %v", err)))
+			continue
+		}
+		c.enqueue(c.handleCommand(ctx, &cmd))
+	}
+}
+
+// reauthenticate checks the client's access token again, so that a long-lived
+// connection can't be used to modify state once its token has expired or been revoked.
+// If the token is no longer valid, the client must authenticate again.
+func (c *conn) reauthenticate(ctx context.Context) error {
+	claims, err := c.s.authClient.CheckAccess(ctx, c.token)
+	if err != nil {
+		if errors.Is(err, auth.ErrUnauthorized) {
+			c.token = ""
+			c.claims = nil
+		}
+		return err
+	}
+	c.claims = claims
+	return nil
+}
+
+// handleCommand carries out a single command, returning the message that should be
+// sent to the client in reply
+func (c *conn) handleCommand(ctx context.Context, cmd *Command) Message {
+	switch cmd.Type {
+	case CommandTypeAuthenticate:
+		if cmd.Token == "" {
+			return errorFor(cmd, ErrorCodeBadRequest, "token is required")
+		}
+		claims, err := c.s.authClient.CheckAccess(ctx, cmd.Token)
+		if err != nil {
+			return errorFor(cmd, errorCodeFor(err), err.Error())
+		}
+		c.token = cmd.Token
+		c.claims = claims
+		return replyTo(cmd)
+	case CommandTypeGetState:
+		st, err := c.s.q.GetCurrentStateEx(ctx)
+		if err != nil {
+			return errorFor(cmd, ErrorCodeInternal, err.Error())
+		}
+		reply := replyTo(cmd)
+		reply.State = &st
+		return reply
+	}
+
+	// All other commands modify broadcast state, so they require broadcaster access
+	if c.token == "" {
+		return errorFor(cmd, ErrorCodeUnauthorized, "an access token must be supplied to modify broadcast state")
+	}
+	if err := c.reauthenticate(ctx); err != nil {
+		return errorFor(cmd, errorCodeFor(err), err.Error())
+	}
+	if c.claims.Role != auth.RoleBroadcaster {
+		return errorFor(cmd, ErrorCodeForbidden, fmt.Sprintf("insufficient access: requires %s; you are %s", auth.RoleBroadcaster, c.claims.Role))
+	}
+
+	ctx = audit.WithActor(ctx, audit.NewUserActor(ctx, c.claims, c.sourceIp))
+	reply := replyTo(cmd)
+	var err error
+	switch cmd.Type {
+	case CommandTypeStartBroadcast:
+		reply.Broadcast, err = c.s.w.StartBroadcast(ctx)
+	case CommandTypeEndBroadcast:
+		reply.Broadcast, err = c.s.w.EndCurrentBroadcast(ctx)
+	case CommandTypeResumeBroadcast:
+		reply.Broadcast, err = c.s.w.ResumeBroadcast(ctx, cmd.BroadcastId)
+	case CommandTypeScreenTape:
+		reply.Screening, err = c.s.w.StartScreening(ctx, cmd.TapeId)
+	case CommandTypeClearTape:
+		// As with DELETE /admin/tape, clearing the tape succeeds so long as there's no
+		// screening in progress once we're done
+		err = c.s.w.EndCurrentScreening(ctx)
+		if errors.Is(err, state.ErrNoScreeningInProgress) {
+			err = nil
+		}
+	default:
+		return errorFor(cmd, ErrorCodeBadRequest, fmt.Sprintf("unrecognized command type '%s'", cmd.Type))
+	}
+	if err != nil {
+		return errorFor(cmd, errorCodeFor(err), err.Error())
+	}
+	c.logger.Info("Handled WebSocket command", "command", cmd.Type, "requestId", cmd.RequestId)
+	return reply
+}
@@ -0,0 +1,132 @@
+package ws
+
+import (
+	"errors"
+
+	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/internal/state"
+)
+
+// CommandType identifies a kind of Command sent by the client
+type CommandType string
+
+const (
+	// CommandTypeAuthenticate supplies an access token, so that subsequent commands on
+	// the same connection are made with that user's level of access
+	CommandTypeAuthenticate CommandType = "authenticate"
+	// CommandTypeGetState requests the current broadcast state; anyone may send it
+	CommandTypeGetState CommandType = "get-state"
+	// CommandTypeStartBroadcast starts a new broadcast; requires broadcaster access
+	CommandTypeStartBroadcast CommandType = "start-broadcast"
+	// CommandTypeEndBroadcast ends the current broadcast; requires broadcaster access
+	CommandTypeEndBroadcast CommandType = "end-broadcast"
+	// CommandTypeResumeBroadcast resumes the broadcast identified by broadcastId;
+	// requires broadcaster access
+	CommandTypeResumeBroadcast CommandType = "resume-broadcast"
+	// CommandTypeScreenTape starts screening the tape identified by tapeId in the
+	// current broadcast; requires broadcaster access
+	CommandTypeScreenTape CommandType = "screen-tape"
+	// CommandTypeClearTape ends the current screening, if any; requires broadcaster
+	// access
+	CommandTypeClearTape CommandType = "clear-tape"
+)
+
+// Command is a JSON message sent from the client to the server. The client may supply
+// any requestId it likes, and the server's reply to the command will carry the same ID.
+type Command struct {
+	RequestId   string      `json:"requestId"`
+	Type        CommandType `json:"type"`
+	Token       string      `json:"token,omitempty"`
+	TapeId      int         `json:"tapeId,omitempty"`
+	BroadcastId int         `json:"broadcastId,omitempty"`
+}
+
+// MessageType identifies a kind of Message sent by the server
+type MessageType string
+
+const (
+	// MessageTypeState carries the current broadcast state: it's sent upon connecting
+	// and whenever broadcast state changes
+	MessageTypeState MessageType = "state"
+	// MessageTypeReply indicates that a command completed successfully, carrying any
+	// data that resulted from that command
+	MessageTypeReply MessageType = "reply"
+	// MessageTypeError indicates that a command failed
+	MessageTypeError MessageType = "error"
+)
+
+// Message is a JSON message sent from the server to the client
+type Message struct {
+	Type      MessageType           `json:"type"`
+	RequestId string                `json:"requestId,omitempty"`
+	State     *broadcasts.State     `json:"state,omitempty"`
+	Broadcast *broadcasts.Broadcast `json:"broadcast,omitempty"`
+	Screening *broadcasts.Screening `json:"screening,omitempty"`
+	Error     *Error                `json:"error,omitempty"`
+}
+
+// Error describes why a command failed
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// ErrorCode allows clients to distinguish between different kinds of errors: where a
+// command fails because broadcast state doesn't allow it, the code corresponds to one
+// of the state.Err* sentinel errors
+type ErrorCode string
+
+const (
+	ErrorCodeBadRequest            ErrorCode = "bad-request"
+	ErrorCodeUnauthorized          ErrorCode = "unauthorized"
+	ErrorCodeForbidden             ErrorCode = "forbidden"
+	ErrorCodeInternal              ErrorCode = "internal"
+	ErrorCodeBroadcastInProgress   ErrorCode = "broadcast-in-progress"
+	ErrorCodeNoBroadcastInProgress ErrorCode = "no-broadcast-in-progress"
+	ErrorCodeScreeningInProgress   ErrorCode = "screening-in-progress"
+	ErrorCodeNoScreeningInProgress ErrorCode = "no-screening-in-progress"
+	ErrorCodeNoSuchBroadcast       ErrorCode = "no-such-broadcast"
+	ErrorCodeBroadcastNotResumable ErrorCode = "broadcast-not-resumable"
+)
+
+// errorCodeFor returns the ErrorCode that identifies the given error
+func errorCodeFor(err error) ErrorCode {
+	switch {
+	case errors.Is(err, state.ErrBroadcastInProgress):
+		return ErrorCodeBroadcastInProgress
+	case errors.Is(err, state.ErrNoBroadcastInProgress):
+		return ErrorCodeNoBroadcastInProgress
+	case errors.Is(err, state.ErrScreeningInProgress):
+		return ErrorCodeScreeningInProgress
+	case errors.Is(err, state.ErrNoScreeningInProgress):
+		return ErrorCodeNoScreeningInProgress
+	case errors.Is(err, state.ErrNoSuchBroadcast):
+		return ErrorCodeNoSuchBroadcast
+	case errors.Is(err, state.ErrBroadcastNotResumable):
+		return ErrorCodeBroadcastNotResumable
+	case errors.Is(err, auth.ErrUnauthorized):
+		return ErrorCodeUnauthorized
+	}
+	return ErrorCodeInternal
+}
+
+// replyTo returns a Message indicating that the given command succeeded
+func replyTo(cmd *Command) Message {
+	return Message{
+		Type:      MessageTypeReply,
+		RequestId: cmd.RequestId,
+	}
+}
+
+// errorFor returns a Message indicating that the given command failed
+func errorFor(cmd *Command, code ErrorCode, message string) Message {
+	return Message{
+		Type:      MessageTypeError,
+		RequestId: cmd.RequestId,
+		Error: &Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
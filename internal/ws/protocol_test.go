@@ -0,0 +1,32 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_errorCodeFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{state.ErrBroadcastInProgress, ErrorCodeBroadcastInProgress},
+		{state.ErrNoBroadcastInProgress, ErrorCodeNoBroadcastInProgress},
+		{state.ErrScreeningInProgress, ErrorCodeScreeningInProgress},
+		{state.ErrNoScreeningInProgress, ErrorCodeNoScreeningInProgress},
+		{state.ErrNoSuchBroadcast, ErrorCodeNoSuchBroadcast},
+		{state.ErrBroadcastNotResumable, ErrorCodeBroadcastNotResumable},
+		{fmt.Errorf("wrapped: %w", state.ErrNoBroadcastInProgress), ErrorCodeNoBroadcastInProgress},
+		{auth.ErrUnauthorized, ErrorCodeUnauthorized},
+		{fmt.Errorf("oh no"), ErrorCodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, errorCodeFor(tt.err))
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/golden-vcr/auth"
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/gen/queries"
+	"github.com/golden-vcr/broadcasts/internal/audit"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/golden-vcr/server-common/entry"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+type Queries interface {
+	GetCurrentStateEx(ctx context.Context) (broadcasts.State, error)
+}
+
+// Server accepts WebSocket connections over which clients receive the current
+// broadcast state as it changes, and over which the broadcaster can send commands to
+// modify that state
+type Server struct {
+	authClient auth.Client
+	q          Queries
+	w          state.Writer
+	proxies    audit.TrustedProxies
+	upgrader   websocket.Upgrader
+
+	conns map[*conn]struct{}
+	mu    sync.Mutex
+}
+
+// NewServer initializes a Server that will push each new state received from the
+// states channel to all connected clients, until the given context is canceled.
+// Browsers may only connect from the given origins. When identifying the source of a
+// connection for the audit log, X-Forwarded-For is only believed if the connection was
+// made by one of the given proxies.
+func NewServer(ctx context.Context, authClient auth.Client, q *queries.Queries, w state.Writer, allowedOrigins []string, proxies audit.TrustedProxies, states <-chan broadcasts.State) *Server {
+	s := newServer(authClient, q, w, allowedOrigins, proxies)
+	go s.run(ctx, states)
+	return s
+}
+
+func newServer(authClient auth.Client, q Queries, w state.Writer, allowedOrigins []string, proxies audit.TrustedProxies) *Server {
+	return &Server{
+		authClient: authClient,
+		q:          q,
+		w:          w,
+		proxies:    proxies,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin(allowedOrigins),
+		},
+		conns: make(map[*conn]struct{}),
+	}
+}
+
+func (s *Server) RegisterRoutes(r *mux.Router) {
+	r.Path("/ws").Methods("GET").HandlerFunc(s.handleConnect)
+}
+
+// run fans out each new state to all connected clients, then closes all connections
+// once the context is canceled
+func (s *Server) run(ctx context.Context, states <-chan broadcasts.State) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			for c := range s.conns {
+				c.close()
+			}
+			s.mu.Unlock()
+			return
+		case st := <-states:
+			s.mu.Lock()
+			for c := range s.conns {
+				c.sendState(st)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) handleConnect(res http.ResponseWriter, req *http.Request) {
+	logger := entry.Log(req)
+
+	// If an access token is supplied in the Authorization header, verify it before we
+	// open the connection, so that the client may send commands right away; otherwise
+	// the client can authenticate later with an 'authenticate' command
+	var claims *auth.AccessClaims
+	accessToken := auth.GetToken(req)
+	if accessToken != "" {
+		var err error
+		claims, err = s.authClient.CheckAccess(req.Context(), accessToken)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, auth.ErrUnauthorized) {
+				status = http.StatusUnauthorized
+			}
+			http.Error(res, err.Error(), status)
+			return
+		}
+	}
+
+	// Upgrade to a WebSocket connection: if this fails, the upgrader has already
+	// responded with an error
+	wsConn, err := s.upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		logger.Error("Failed to upgrade to WebSocket connection", "error", err)
+		return
+	}
+	c := newConn(s, wsConn, logger, accessToken, claims, s.proxies.SourceIp(req))
+	go c.writeLoop()
+
+	// Register the connection so that it will receive all subsequent changes in state,
+	// then send the current state as a starting point
+	s.register(c)
+	defer s.unregister(c)
+	if st, err := s.q.GetCurrentStateEx(req.Context()); err != nil {
+		logger.Error("Failed to get broadcast state for new WebSocket connection", "error", err)
+	} else {
+		c.sendState(st)
+	}
+
+	// Handle commands from the client until the connection is closed
+	logger.Info("Opened WebSocket connection", "remoteAddr", req.RemoteAddr)
+	c.readLoop(req.Context())
+	logger.Info("Closed WebSocket connection", "remoteAddr", req.RemoteAddr)
+}
+
+func (s *Server) register(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conns[c] = struct{}{}
+}
+
+func (s *Server) unregister(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, c)
+}
+
+// checkOrigin returns a function that accepts a WebSocket handshake only if it was
+// initiated by a page served from one of the given origins, so that other sites can't
+// open connections from a visitor's browser. Requests that carry no Origin header
+// don't come from a browser, so they're accepted.
+func checkOrigin(allowedOrigins []string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		origin := req.Header.Get("origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowedOrigin := range allowedOrigins {
+			if strings.EqualFold(origin, strings.TrimSuffix(allowedOrigin, "/")) {
+				return true
+			}
+		}
+		return false
+	}
+}
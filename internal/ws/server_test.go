@@ -0,0 +1,322 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/auth"
+	authmock "github.com/golden-vcr/auth/mock"
+	"github.com/golden-vcr/broadcasts"
+	"github.com/golden-vcr/broadcasts/internal/state"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server(t *testing.T) {
+	authClient := authmock.NewClient().AllowTwitchUserAccessToken("viewer-token", auth.RoleViewer, auth.UserDetails{
+		Id:          "1234",
+		Login:       "viewer",
+		DisplayName: "Viewer",
+	}).AllowTwitchUserAccessToken("broadcaster-token", auth.RoleBroadcaster, auth.UserDetails{
+		Id:          "5678",
+		Login:       "broadcaster",
+		DisplayName: "Broadcaster",
+	})
+	q := &mockQueries{
+		state: broadcasts.State{
+			State:   core.State{BroadcastId: 12},
+			Version: 40,
+		},
+	}
+
+	t.Run("client receives current state upon connecting, then any changes in state", func(t *testing.T) {
+		states := make(chan broadcasts.State)
+		conn := connect(t, newTestServer(t, authClient, q, &mockWriter{}, states), "")
+
+		m := recv(t, conn)
+		assert.Equal(t, MessageTypeState, m.Type)
+		assert.Equal(t, int64(40), m.State.Version)
+		assert.Equal(t, 12, m.State.BroadcastId)
+
+		// A stale state should never be sent after a newer one
+		states <- broadcasts.State{Version: 39}
+		states <- broadcasts.State{Version: 41, State: core.State{BroadcastId: 12, TapeId: 50}}
+		m = recv(t, conn)
+		assert.Equal(t, MessageTypeState, m.Type)
+		assert.Equal(t, int64(41), m.State.Version)
+		assert.Equal(t, 50, m.State.TapeId)
+	})
+
+	t.Run("anyone can request current state", func(t *testing.T) {
+		conn := connect(t, newTestServer(t, authClient, q, &mockWriter{}, nil), "")
+		recv(t, conn)
+
+		m := roundTrip(t, conn, Command{RequestId: "r1", Type: CommandTypeGetState})
+		assert.Equal(t, MessageTypeReply, m.Type)
+		assert.Equal(t, "r1", m.RequestId)
+		assert.Equal(t, int64(40), m.State.Version)
+	})
+
+	t.Run("commands that modify state require an access token", func(t *testing.T) {
+		conn := connect(t, newTestServer(t, authClient, q, &mockWriter{}, nil), "")
+		recv(t, conn)
+
+		m := roundTrip(t, conn, Command{RequestId: "r1", Type: CommandTypeScreenTape, TapeId: 50})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, "r1", m.RequestId)
+		assert.Equal(t, ErrorCodeUnauthorized, m.Error.Code)
+	})
+
+	t.Run("commands that modify state require broadcaster access", func(t *testing.T) {
+		conn := connect(t, newTestServer(t, authClient, q, &mockWriter{}, nil), "viewer-token")
+		recv(t, conn)
+
+		m := roundTrip(t, conn, Command{RequestId: "r1", Type: CommandTypeScreenTape, TapeId: 50})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, ErrorCodeForbidden, m.Error.Code)
+	})
+
+	t.Run("invalid access token in header is rejected", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(newTestServer(t, authClient, q, &mockWriter{}, nil).URL, "http")
+		_, res, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"Bearer bad-token"}})
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("broadcaster can authenticate and then send commands", func(t *testing.T) {
+		conn := connect(t, newTestServer(t, authClient, q, &mockWriter{}, nil), "")
+		recv(t, conn)
+
+		m := roundTrip(t, conn, Command{RequestId: "r1", Type: CommandTypeAuthenticate, Token: "bad-token"})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, ErrorCodeUnauthorized, m.Error.Code)
+
+		m = roundTrip(t, conn, Command{RequestId: "r2", Type: CommandTypeAuthenticate, Token: "broadcaster-token"})
+		assert.Equal(t, MessageTypeReply, m.Type)
+		assert.Equal(t, "r2", m.RequestId)
+
+		m = roundTrip(t, conn, Command{RequestId: "r3", Type: CommandTypeScreenTape, TapeId: 50})
+		assert.Equal(t, MessageTypeReply, m.Type)
+		assert.Equal(t, "r3", m.RequestId)
+		assert.Equal(t, 50, m.Screening.TapeId)
+
+		m = roundTrip(t, conn, Command{RequestId: "r4", Type: CommandTypeClearTape})
+		assert.Equal(t, MessageTypeReply, m.Type)
+		assert.Equal(t, "r4", m.RequestId)
+	})
+
+	t.Run("access is re-checked before each command that modifies state", func(t *testing.T) {
+		revocable := &revocableAuthClient{Client: authClient}
+		conn := connect(t, newTestServer(t, revocable, q, &mockWriter{}, nil), "broadcaster-token")
+		recv(t, conn)
+
+		m := roundTrip(t, conn, Command{RequestId: "r1", Type: CommandTypeScreenTape, TapeId: 50})
+		assert.Equal(t, MessageTypeReply, m.Type)
+
+		// Once the token is revoked, the connection should no longer be able to modify
+		// state, even though it was authenticated when it was opened
+		revocable.revoke("broadcaster-token")
+		m = roundTrip(t, conn, Command{RequestId: "r2", Type: CommandTypeScreenTape, TapeId: 50})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, ErrorCodeUnauthorized, m.Error.Code)
+
+		// Observing state should still work
+		m = roundTrip(t, conn, Command{RequestId: "r3", Type: CommandTypeGetState})
+		assert.Equal(t, MessageTypeReply, m.Type)
+
+		// The client must authenticate again with a valid token
+		m = roundTrip(t, conn, Command{RequestId: "r4", Type: CommandTypeClearTape})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, ErrorCodeUnauthorized, m.Error.Code)
+		assert.Equal(t, "an access token must be supplied to modify broadcast state", m.Error.Message)
+	})
+
+	t.Run("browsers may only connect from allowed origins", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(newTestServer(t, authClient, q, &mockWriter{}, nil).URL, "http")
+		_, res, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": []string{"https://evil.example.com"}})
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": []string{"https://goldenvcr.com"}})
+		assert.NoError(t, err)
+		conn.Close()
+	})
+
+	t.Run("state errors are reported with corresponding error codes", func(t *testing.T) {
+		w := &mockWriter{err: state.ErrNoBroadcastInProgress}
+		conn := connect(t, newTestServer(t, authClient, q, w, nil), "broadcaster-token")
+		recv(t, conn)
+
+		m := roundTrip(t, conn, Command{RequestId: "r1", Type: CommandTypeScreenTape, TapeId: 50})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, "r1", m.RequestId)
+		assert.Equal(t, ErrorCodeNoBroadcastInProgress, m.Error.Code)
+		assert.Equal(t, "no broadcast is currently in progress", m.Error.Message)
+	})
+
+	t.Run("malformed and unrecognized commands are rejected", func(t *testing.T) {
+		conn := connect(t, newTestServer(t, authClient, q, &mockWriter{}, nil), "broadcaster-token")
+		recv(t, conn)
+
+		err := conn.WriteMessage(websocket.TextMessage, []byte("not-json"))
+		assert.NoError(t, err)
+		m := recv(t, conn)
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, ErrorCodeBadRequest, m.Error.Code)
+
+		m = roundTrip(t, conn, Command{RequestId: "r1", Type: "do-a-barrel-roll"})
+		assert.Equal(t, MessageTypeError, m.Type)
+		assert.Equal(t, "r1", m.RequestId)
+		assert.Equal(t, ErrorCodeBadRequest, m.Error.Code)
+	})
+}
+
+func Test_checkOrigin(t *testing.T) {
+	check := checkOrigin([]string{"https://goldenvcr.com", "http://localhost:5173/"})
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://goldenvcr.com", true},
+		{"https://GoldenVCR.com", true},
+		{"http://localhost:5173", true},
+		{"http://goldenvcr.com", false},
+		{"https://goldenvcr.com.evil.example.com", false},
+		{"https://evil.example.com", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("origin", tt.origin)
+			}
+			assert.Equal(t, tt.want, check(req))
+		})
+	}
+}
+
+func newTestServer(t *testing.T, authClient auth.Client, q Queries, w state.Writer, states <-chan broadcasts.State) *httptest.Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newServer(authClient, q, w, []string{"https://goldenvcr.com"}, nil)
+	if states != nil {
+		go s.run(ctx, states)
+	}
+	server := httptest.NewServer(http.HandlerFunc(s.handleConnect))
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	return server
+}
+
+func connect(t *testing.T, server *httptest.Server, accessToken string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	header := http.Header{}
+	if accessToken != "" {
+		header.Set("Authorization", "Bearer "+accessToken)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func recv(t *testing.T, conn *websocket.Conn) Message {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var m Message
+	if err := conn.ReadJSON(&m); err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return m
+}
+
+func roundTrip(t *testing.T, conn *websocket.Conn, cmd Command) Message {
+	if err := conn.WriteJSON(cmd); err != nil {
+		t.Fatalf("failed to write command: %v", err)
+	}
+	return recv(t, conn)
+}
+
+// revocableAuthClient accepts the same tokens as the client it wraps, until they're
+// revoked
+type revocableAuthClient struct {
+	auth.Client
+	revoked map[string]bool
+	mu      sync.Mutex
+}
+
+func (c *revocableAuthClient) revoke(accessToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.revoked == nil {
+		c.revoked = make(map[string]bool)
+	}
+	c.revoked[accessToken] = true
+}
+
+func (c *revocableAuthClient) CheckAccess(ctx context.Context, accessToken string) (*auth.AccessClaims, error) {
+	c.mu.Lock()
+	revoked := c.revoked[accessToken]
+	c.mu.Unlock()
+	if revoked {
+		return nil, auth.ErrUnauthorized
+	}
+	return c.Client.CheckAccess(ctx, accessToken)
+}
+
+type mockQueries struct {
+	state broadcasts.State
+}
+
+func (m *mockQueries) GetCurrentStateEx(ctx context.Context) (broadcasts.State, error) {
+	return m.state, nil
+}
+
+type mockWriter struct {
+	err error
+}
+
+func (m *mockWriter) StartBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) EndCurrentBroadcast(ctx context.Context) (*broadcasts.Broadcast, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) ResumeBroadcast(ctx context.Context, broadcastId int) (*broadcasts.Broadcast, error) {
+	return nil, fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) EndStaleBroadcast(ctx context.Context, broadcastId int, endedAt time.Time) error {
+	return fmt.Errorf("not mocked")
+}
+
+func (m *mockWriter) StartScreening(ctx context.Context, tapeId int) (*broadcasts.Screening, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &broadcasts.Screening{
+		Id:        uuid.MustParse("df0d9c53-8a7a-4788-9d20-dc718cf4a7b3"),
+		TapeId:    tapeId,
+		StartedAt: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
+	}, nil
+}
+
+func (m *mockWriter) EndCurrentScreening(ctx context.Context) error {
+	return m.err
+}
+
+var _ state.Writer = (*mockWriter)(nil)
@@ -0,0 +1,144 @@
+package broadcasts
+
+import (
+	"errors"
+	"fmt"
+
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+)
+
+// TransitionType identifies the kind of change that a Transition makes to broadcast
+// state
+type TransitionType string
+
+const (
+	TransitionStartBroadcast TransitionType = "start-broadcast"
+	TransitionEndBroadcast   TransitionType = "end-broadcast"
+	TransitionStartScreening TransitionType = "start-screening"
+	TransitionEndScreening   TransitionType = "end-screening"
+)
+
+// Transition describes a single change in broadcast state. The broadcast state machine
+// has two kinds of state: offline (the zero core.State), and live with a BroadcastId,
+// in which case a ScreeningId and TapeId may also be set if a tape is being screened.
+// The valid transitions between those states are:
+//
+//   - TransitionStartBroadcast: offline -> live, with the given BroadcastId (which may
+//     be the ID of a previous broadcast that's being resumed)
+//   - TransitionEndBroadcast: live -> offline, implicitly ending any screening
+//   - TransitionStartScreening: live -> live, screening the given tape and implicitly
+//     ending any screening already in progress, unless it's of the same tape
+//   - TransitionEndScreening: live (screening) -> live (not screening)
+//
+// For all transitions except TransitionStartBroadcast, BroadcastId may be left zero to
+// indicate that the transition applies to whichever broadcast is current; if nonzero,
+// it must match the current broadcast.
+type Transition struct {
+	Type        TransitionType
+	BroadcastId int
+	ScreeningId uuid.UUID
+	TapeId      int
+}
+
+// Validate returns nil if the transition may be applied to the given state, or else the
+// error that explains why it may not: ErrBroadcastInProgress, ErrNoBroadcastInProgress,
+// ErrScreeningInProgress, or ErrNoScreeningInProgress. Only the fields that are
+// relevant to the validity of the change are checked, so (for example) a writer may
+// validate TransitionStartBroadcast before it knows the ID of the new broadcast.
+func (t Transition) Validate(s core.State) error {
+	switch t.Type {
+	case TransitionStartBroadcast:
+		if s.BroadcastId != 0 {
+			return ErrBroadcastInProgress
+		}
+		return nil
+	case TransitionEndBroadcast:
+		return t.requireBroadcast(s)
+	case TransitionStartScreening:
+		if err := t.requireBroadcast(s); err != nil {
+			return err
+		}
+		if s.ScreeningId != uuid.Nil && s.TapeId == t.TapeId {
+			return ErrScreeningInProgress
+		}
+		return nil
+	case TransitionEndScreening:
+		if err := t.requireBroadcast(s); err != nil {
+			return err
+		}
+		if s.ScreeningId == uuid.Nil {
+			return ErrNoScreeningInProgress
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown type '%s'", ErrInvalidTransition, t.Type)
+}
+
+// Apply validates the transition against the given state, then returns the state that
+// results from applying it. The input state is never modified.
+func (t Transition) Apply(s core.State) (core.State, error) {
+	if err := t.Validate(s); err != nil {
+		return s, err
+	}
+	switch t.Type {
+	case TransitionStartBroadcast:
+		if t.BroadcastId == 0 {
+			return s, fmt.Errorf("%w: broadcast ID is required to start a broadcast", ErrInvalidTransition)
+		}
+		return core.State{BroadcastId: t.BroadcastId}, nil
+	case TransitionEndBroadcast:
+		return core.State{}, nil
+	case TransitionStartScreening:
+		if t.ScreeningId == uuid.Nil || t.TapeId == 0 {
+			return s, fmt.Errorf("%w: screening and tape IDs are required to start a screening", ErrInvalidTransition)
+		}
+		return core.State{BroadcastId: s.BroadcastId, ScreeningId: t.ScreeningId, TapeId: t.TapeId}, nil
+	default:
+		return core.State{BroadcastId: s.BroadcastId}, nil
+	}
+}
+
+// requireBroadcast checks that a broadcast is live in the given state, and that it's
+// the broadcast this transition refers to
+func (t Transition) requireBroadcast(s core.State) error {
+	if s.BroadcastId == 0 || (t.BroadcastId != 0 && t.BroadcastId != s.BroadcastId) {
+		return ErrNoBroadcastInProgress
+	}
+	return nil
+}
+
+// TransitionFromEvent returns the Transition that's described by the given
+// broadcast-events message
+func TransitionFromEvent(ev *ebroadcast.Event) (Transition, error) {
+	switch ev.Type {
+	case ebroadcast.EventTypeBroadcastStarted:
+		return Transition{Type: TransitionStartBroadcast, BroadcastId: ev.Broadcast.Id}, nil
+	case ebroadcast.EventTypeBroadcastFinished:
+		return Transition{Type: TransitionEndBroadcast, BroadcastId: ev.Broadcast.Id}, nil
+	case ebroadcast.EventTypeScreeningStarted:
+		if ev.Screening == nil {
+			return Transition{}, fmt.Errorf("%w: screening-started event has no screening", ErrInvalidTransition)
+		}
+		return Transition{
+			Type:        TransitionStartScreening,
+			BroadcastId: ev.Broadcast.Id,
+			ScreeningId: ev.Screening.Id,
+			TapeId:      ev.Screening.TapeId,
+		}, nil
+	case ebroadcast.EventTypeScreeningFinished:
+		return Transition{Type: TransitionEndScreening, BroadcastId: ev.Broadcast.Id}, nil
+	}
+	return Transition{}, fmt.Errorf("%w: unknown event type '%s'", ErrInvalidTransition, ev.Type)
+}
+
+// IsTransitionError returns true if err indicates that a change in broadcast state was
+// refused because it's not a valid transition from the current state
+func IsTransitionError(err error) bool {
+	return errors.Is(err, ErrBroadcastInProgress) ||
+		errors.Is(err, ErrNoBroadcastInProgress) ||
+		errors.Is(err, ErrScreeningInProgress) ||
+		errors.Is(err, ErrNoScreeningInProgress) ||
+		errors.Is(err, ErrInvalidTransition)
+}
@@ -0,0 +1,437 @@
+package broadcasts
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testScreeningId      = uuid.MustParse("f29a4ffe-cb9f-43ba-9f91-a3b1fa350472")
+	testOtherScreeningId = uuid.MustParse("0cd4f0a5-8b2b-4c4c-a9ab-8b9b1c5bdbd1")
+)
+
+func Test_Transition_Apply(t *testing.T) {
+	offline := core.State{}
+	live := core.State{BroadcastId: 55}
+	screening := core.State{BroadcastId: 55, ScreeningId: testScreeningId, TapeId: 109}
+
+	tests := []struct {
+		name    string
+		state   core.State
+		t       Transition
+		want    core.State
+		wantErr error
+	}{
+		// Starting a broadcast
+		{
+			"broadcast can be started when offline",
+			offline,
+			Transition{Type: TransitionStartBroadcast, BroadcastId: 55},
+			live,
+			nil,
+		},
+		{
+			"broadcast cannot be started while live",
+			live,
+			Transition{Type: TransitionStartBroadcast, BroadcastId: 56},
+			live,
+			ErrBroadcastInProgress,
+		},
+		{
+			"broadcast cannot be restarted while live",
+			live,
+			Transition{Type: TransitionStartBroadcast, BroadcastId: 55},
+			live,
+			ErrBroadcastInProgress,
+		},
+		{
+			"broadcast cannot be started while screening",
+			screening,
+			Transition{Type: TransitionStartBroadcast, BroadcastId: 56},
+			screening,
+			ErrBroadcastInProgress,
+		},
+		{
+			"starting a broadcast requires an ID",
+			offline,
+			Transition{Type: TransitionStartBroadcast},
+			offline,
+			ErrInvalidTransition,
+		},
+
+		// Ending a broadcast
+		{
+			"broadcast cannot be ended when offline",
+			offline,
+			Transition{Type: TransitionEndBroadcast},
+			offline,
+			ErrNoBroadcastInProgress,
+		},
+		{
+			"current broadcast can be ended while live",
+			live,
+			Transition{Type: TransitionEndBroadcast},
+			offline,
+			nil,
+		},
+		{
+			"broadcast can be ended by ID while live",
+			live,
+			Transition{Type: TransitionEndBroadcast, BroadcastId: 55},
+			offline,
+			nil,
+		},
+		{
+			"broadcast other than the current one cannot be ended",
+			live,
+			Transition{Type: TransitionEndBroadcast, BroadcastId: 54},
+			live,
+			ErrNoBroadcastInProgress,
+		},
+		{
+			"ending a broadcast also ends its screening",
+			screening,
+			Transition{Type: TransitionEndBroadcast},
+			offline,
+			nil,
+		},
+
+		// Starting a screening
+		{
+			"screening cannot be started when offline",
+			offline,
+			Transition{Type: TransitionStartScreening, ScreeningId: testScreeningId, TapeId: 109},
+			offline,
+			ErrNoBroadcastInProgress,
+		},
+		{
+			"screening can be started while live",
+			live,
+			Transition{Type: TransitionStartScreening, ScreeningId: testScreeningId, TapeId: 109},
+			screening,
+			nil,
+		},
+		{
+			"screening cannot be started in a broadcast other than the current one",
+			live,
+			Transition{Type: TransitionStartScreening, BroadcastId: 54, ScreeningId: testScreeningId, TapeId: 109},
+			live,
+			ErrNoBroadcastInProgress,
+		},
+		{
+			"screening a different tape replaces the current screening",
+			screening,
+			Transition{Type: TransitionStartScreening, ScreeningId: testOtherScreeningId, TapeId: 110},
+			core.State{BroadcastId: 55, ScreeningId: testOtherScreeningId, TapeId: 110},
+			nil,
+		},
+		{
+			"screening the same tape twice in a row is refused",
+			screening,
+			Transition{Type: TransitionStartScreening, ScreeningId: testOtherScreeningId, TapeId: 109},
+			screening,
+			ErrScreeningInProgress,
+		},
+		{
+			"starting a screening requires an ID",
+			live,
+			Transition{Type: TransitionStartScreening, TapeId: 109},
+			live,
+			ErrInvalidTransition,
+		},
+
+		// Ending a screening
+		{
+			"screening cannot be ended when offline",
+			offline,
+			Transition{Type: TransitionEndScreening},
+			offline,
+			ErrNoBroadcastInProgress,
+		},
+		{
+			"screening cannot be ended when not screening",
+			live,
+			Transition{Type: TransitionEndScreening},
+			live,
+			ErrNoScreeningInProgress,
+		},
+		{
+			"screening can be ended while screening",
+			screening,
+			Transition{Type: TransitionEndScreening},
+			live,
+			nil,
+		},
+		{
+			"screening cannot be ended in a broadcast other than the current one",
+			screening,
+			Transition{Type: TransitionEndScreening, BroadcastId: 54},
+			screening,
+			ErrNoBroadcastInProgress,
+		},
+
+		// Unknown transitions
+		{
+			"unknown transition is invalid",
+			live,
+			Transition{Type: "explode"},
+			live,
+			ErrInvalidTransition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.t.Apply(tt.state)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.True(t, IsTransitionError(err))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_TransitionFromEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		ev      ebroadcast.Event
+		want    Transition
+		wantErr error
+	}{
+		{
+			"broadcast started",
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeBroadcastStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			},
+			Transition{Type: TransitionStartBroadcast, BroadcastId: 55},
+			nil,
+		},
+		{
+			"broadcast finished",
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeBroadcastFinished,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+				Screening: &ebroadcast.ScreeningData{Id: testScreeningId, TapeId: 109},
+			},
+			Transition{Type: TransitionEndBroadcast, BroadcastId: 55},
+			nil,
+		},
+		{
+			"screening started",
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeScreeningStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+				Screening: &ebroadcast.ScreeningData{Id: testScreeningId, TapeId: 109},
+			},
+			Transition{Type: TransitionStartScreening, BroadcastId: 55, ScreeningId: testScreeningId, TapeId: 109},
+			nil,
+		},
+		{
+			"screening started without screening data",
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeScreeningStarted,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			},
+			Transition{},
+			ErrInvalidTransition,
+		},
+		{
+			"screening finished",
+			ebroadcast.Event{
+				Type:      ebroadcast.EventTypeScreeningFinished,
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			},
+			Transition{Type: TransitionEndScreening, BroadcastId: 55},
+			nil,
+		},
+		{
+			"unknown event type",
+			ebroadcast.Event{
+				Type:      "broadcast-exploded",
+				Broadcast: ebroadcast.BroadcastData{Id: 55},
+			},
+			Transition{},
+			ErrInvalidTransition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TransitionFromEvent(&tt.ev)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Every state reachable from offline via valid transitions should be well-formed: a
+// screening can only be in progress during a broadcast, and always has a tape
+func Test_Transition_reachableStatesAreWellFormed(t *testing.T) {
+	f := func(seq transitionSequence) bool {
+		s := core.State{}
+		for _, tr := range seq {
+			next, err := tr.Apply(s)
+			if err != nil {
+				continue
+			}
+			s = next
+			if !isWellFormed(s) {
+				t.Logf("transition %+v produced malformed state %+v", tr, s)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+// Apply should fail exactly when Validate does, and a failed transition should leave
+// state unchanged
+func Test_Transition_ApplyAgreesWithValidate(t *testing.T) {
+	f := func(s randomState, tr randomTransition) bool {
+		validateErr := Transition(tr).Validate(core.State(s))
+		next, err := Transition(tr).Apply(core.State(s))
+		if validateErr != nil {
+			return err == validateErr && next == core.State(s)
+		}
+		if err != nil {
+			return errors.Is(err, ErrInvalidTransition) && next == core.State(s)
+		}
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+// Every valid transition should have the same effect as the corresponding
+// broadcast-events message, so that consumers of those events arrive at the same state
+// as the writer that produced them
+func Test_Transition_ApplyAgreesWithEvents(t *testing.T) {
+	f := func(s randomState, tr randomTransition) bool {
+		next, err := Transition(tr).Apply(core.State(s))
+		if err != nil {
+			return true
+		}
+		ev := toEvent(Transition(tr), core.State(s))
+		fromEvent, err := TransitionFromEvent(&ev)
+		if err != nil {
+			return false
+		}
+		nextFromEvent, err := fromEvent.Apply(core.State(s))
+		return err == nil && nextFromEvent == next && ev.ToState(core.State(s)) == next
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func isWellFormed(s core.State) bool {
+	if s.BroadcastId == 0 {
+		return s == core.State{}
+	}
+	return (s.ScreeningId == uuid.Nil) == (s.TapeId == 0)
+}
+
+// toEvent returns the broadcast-events message that a writer would produce upon
+// applying the given transition to the given state
+func toEvent(tr Transition, s core.State) ebroadcast.Event {
+	broadcastId := tr.BroadcastId
+	if broadcastId == 0 {
+		broadcastId = s.BroadcastId
+	}
+	ev := ebroadcast.Event{Broadcast: ebroadcast.BroadcastData{Id: broadcastId}}
+	switch tr.Type {
+	case TransitionStartBroadcast:
+		ev.Type = ebroadcast.EventTypeBroadcastStarted
+	case TransitionEndBroadcast:
+		ev.Type = ebroadcast.EventTypeBroadcastFinished
+	case TransitionStartScreening:
+		ev.Type = ebroadcast.EventTypeScreeningStarted
+		ev.Screening = &ebroadcast.ScreeningData{Id: tr.ScreeningId, TapeId: tr.TapeId}
+	case TransitionEndScreening:
+		ev.Type = ebroadcast.EventTypeScreeningFinished
+	}
+	return ev
+}
+
+// Random values are drawn from small domains, so that generated transitions frequently
+// refer to the same broadcasts and tapes as the states they're applied to
+var (
+	randomBroadcastIds = []int{0, 1, 2, 3}
+	randomScreeningIds = []uuid.UUID{uuid.Nil, testScreeningId, testOtherScreeningId}
+	randomTapeIds      = []int{0, 1, 2, 3}
+	randomTypes        = []TransitionType{
+		TransitionStartBroadcast,
+		TransitionEndBroadcast,
+		TransitionStartScreening,
+		TransitionEndScreening,
+	}
+)
+
+type randomState core.State
+
+func (randomState) Generate(r *rand.Rand, size int) reflect.Value {
+	s := core.State{}
+	if id := randomBroadcastIds[r.Intn(len(randomBroadcastIds))]; id != 0 {
+		s.BroadcastId = id
+		if screeningId := randomScreeningIds[r.Intn(len(randomScreeningIds))]; screeningId != uuid.Nil {
+			s.ScreeningId = screeningId
+			s.TapeId = 1 + r.Intn(len(randomTapeIds)-1)
+		}
+	}
+	return reflect.ValueOf(randomState(s))
+}
+
+type randomTransition Transition
+
+func (randomTransition) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(randomTransition{
+		Type:        randomTypes[r.Intn(len(randomTypes))],
+		BroadcastId: randomBroadcastIds[r.Intn(len(randomBroadcastIds))],
+		ScreeningId: randomScreeningIds[r.Intn(len(randomScreeningIds))],
+		TapeId:      randomTapeIds[r.Intn(len(randomTapeIds))],
+	})
+}
+
+type transitionSequence []Transition
+
+func (transitionSequence) Generate(r *rand.Rand, size int) reflect.Value {
+	seq := make(transitionSequence, r.Intn(size+1))
+	for i := range seq {
+		seq[i] = Transition(randomTransition{}.Generate(r, size).Interface().(randomTransition))
+	}
+	return reflect.ValueOf(seq)
+}
+
+func Test_randomTransition(t *testing.T) {
+	// Sanity-check our generators, so that the property tests above aren't vacuous
+	r := rand.New(rand.NewSource(1))
+	numValid := 0
+	for i := 0; i < 1000; i++ {
+		s := core.State(randomState{}.Generate(r, 10).Interface().(randomState))
+		tr := Transition(randomTransition{}.Generate(r, 10).Interface().(randomTransition))
+		assert.True(t, isWellFormed(s), fmt.Sprintf("%+v", s))
+		if _, err := tr.Apply(s); err == nil {
+			numValid++
+		}
+	}
+	assert.Greater(t, numValid, 100)
+}
@@ -0,0 +1,92 @@
+package broadcasts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// DefaultPollInterval is a reasonable interval at which a polling client may check for
+// changes in broadcast state
+const DefaultPollInterval = 5 * time.Second
+
+// NewPollingClient initializes a broadcasts.Client that keeps track of platform-wide
+// broadcast state without consuming from broadcast-events: instead, it makes a request
+// to the broadcasts service at the given interval. Requests are conditional, so the
+// state is only sent if it's changed since the last request. This is useful for
+// lightweight tools that don't have access to an AMQP server, at the cost of changes
+// being observed up to one interval late.
+//
+// The resulting client is Healthy so long as its most recent request succeeded. The
+// interval must be positive: if it's not, an error is returned. Use DefaultPollInterval
+// if you have no particular interval in mind.
+func NewPollingClient(ctx context.Context, logger *slog.Logger, broadcastsUrl string, interval time.Duration) (Client, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive; got %s", interval)
+	}
+
+	c := &client{
+		logger:      logger,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+	p := &poller{
+		c:             c,
+		broadcastsUrl: broadcastsUrl,
+	}
+
+	// Get our current broadcast state as a starting point, failing if we can't
+	if err := p.poll(ctx); err != nil {
+		return nil, err
+	}
+	c.setHealthy(true)
+
+	// Run a goroutine that will check for changes in state at the desired interval
+	go p.run(ctx, interval)
+	return c, nil
+}
+
+// poller updates a client's state by polling the broadcasts service
+type poller struct {
+	c             *client
+	broadcastsUrl string
+	etag          string
+}
+
+// run polls at the given interval until the context is canceled
+func (p *poller) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.c.logger.Info("Context canceled; broadcast state client shutting down")
+			return
+		case <-ticker.C:
+			if err := p.poll(ctx); err != nil {
+				p.c.logger.Error("Failed to poll for broadcast state", "error", err)
+				p.c.setHealthy(false)
+			} else {
+				p.c.setHealthy(true)
+			}
+		}
+	}
+}
+
+// poll requests the current state, updating the client's state if it's changed since
+// our last request
+func (p *poller) poll(ctx context.Context) error {
+	state, etag, err := fetchState(ctx, p.broadcastsUrl, p.etag)
+	if err != nil {
+		return err
+	}
+	p.etag = etag
+	if state == nil {
+		p.c.markSynced()
+		return nil
+	}
+	p.c.adoptState(state)
+	return nil
+}
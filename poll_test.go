@@ -0,0 +1,131 @@
+package broadcasts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golden-vcr/schemas/core"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+)
+
+func Test_NewPollingClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := &fakeStateServer{
+		state: State{Version: 41},
+	}
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	// We should resolve our initial state immediately
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c, err := NewPollingClient(ctx, logger, s.URL, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, core.State{}, c.GetState())
+	assert.True(t, c.Healthy())
+	r := &recorder{}
+	unsubscribe := c.Subscribe(r.record)
+	defer unsubscribe()
+
+	// While the state remains unchanged, our requests should be conditional, and the
+	// server should not have to send the state again
+	assert.Eventually(t, func() bool { return server.numNotModified() >= 2 }, time.Second, time.Millisecond)
+	syncedAt := c.LastSyncedAt()
+	assert.Eventually(t, func() bool { return c.LastSyncedAt().After(syncedAt) }, time.Second, time.Millisecond)
+
+	// Once state changes, we should pick up the new state and notify subscribers
+	server.setState(State{State: core.State{BroadcastId: 55}, Version: 42})
+	assert.Equal(t, []stateChange{
+		{prev: core.State{}, next: core.State{BroadcastId: 55}},
+	}, r.wait(t, 1))
+
+	// If the server becomes unavailable, we should report that we're unhealthy until
+	// we're able to poll successfully once more
+	server.setFailing(true)
+	assert.Eventually(t, func() bool { return !c.Healthy() }, time.Second, time.Millisecond)
+	assert.Equal(t, core.State{BroadcastId: 55}, c.GetState())
+	server.setFailing(false)
+	assert.Eventually(t, c.Healthy, time.Second, time.Millisecond)
+}
+
+func Test_NewPollingClient_fails_if_initial_state_is_unavailable(t *testing.T) {
+	server := &fakeStateServer{}
+	server.setFailing(true)
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c, err := NewPollingClient(context.Background(), logger, s.URL, time.Millisecond)
+	assert.Error(t, err)
+	assert.Nil(t, c)
+}
+
+func Test_NewPollingClient_fails_if_interval_is_not_positive(t *testing.T) {
+	server := &fakeStateServer{}
+	s := httptest.NewServer(server)
+	defer s.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, interval := range []time.Duration{0, -time.Second} {
+		c, err := NewPollingClient(context.Background(), logger, s.URL, interval)
+		assert.Error(t, err)
+		assert.Nil(t, c)
+	}
+}
+
+// fakeStateServer emulates the broadcasts service's GET /state endpoint
+type fakeStateServer struct {
+	state           State
+	failing         bool
+	notModifiedHits int
+	mu              sync.Mutex
+}
+
+func (s *fakeStateServer) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.URL.Path != "/state" {
+		http.NotFound(res, req)
+		return
+	}
+	if s.failing {
+		http.Error(res, "oh no", http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf(`"%d"`, s.state.Version)
+	res.Header().Set("etag", etag)
+	if req.Header.Get("if-none-match") == etag {
+		s.notModifiedHits++
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+	json.NewEncoder(res).Encode(s.state)
+}
+
+func (s *fakeStateServer) setState(state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+func (s *fakeStateServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *fakeStateServer) numNotModified() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModifiedHits
+}
@@ -0,0 +1,103 @@
+package broadcasts
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golden-vcr/schemas/core"
+	"golang.org/x/exp/slog"
+)
+
+// SubscriberFunc is called with the previous and new broadcast state whenever state
+// changes
+type SubscriberFunc func(prev, next core.State)
+
+// stateChange records a single change in broadcast state
+type stateChange struct {
+	prev core.State
+	next core.State
+}
+
+// subscriberQueueSize is the number of state changes that may be queued for a
+// subscriber: if a subscriber falls this far behind, we assume it's stuck and stop
+// delivering to it
+const subscriberQueueSize = 32
+
+// subscriber delivers state changes to a single SubscriberFunc. Changes are queued
+// without blocking, and a dedicated goroutine calls the function with each change in
+// the order they occurred: a slow subscriber falls behind without holding up anyone
+// else, and a subscriber that panics is isolated from all others. A subscriber that
+// falls more than subscriberQueueSize changes behind is closed.
+type subscriber struct {
+	f      SubscriberFunc
+	logger *slog.Logger
+
+	queue     chan stateChange
+	doneCh    chan struct{}
+	closeOnce sync.Once
+}
+
+func newSubscriber(f SubscriberFunc, logger *slog.Logger) *subscriber {
+	s := &subscriber{
+		f:      f,
+		logger: logger,
+		queue:  make(chan stateChange, subscriberQueueSize),
+		doneCh: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// push queues a change to be delivered to the subscriber, closing the subscriber if
+// it has fallen too far behind; it never blocks. It returns false if the subscriber
+// is closed, in which case it should be discarded.
+func (s *subscriber) push(change stateChange) bool {
+	select {
+	case <-s.doneCh:
+		return false
+	case s.queue <- change:
+		return true
+	default:
+		s.logger.Warn("Broadcast state subscriber is not keeping up; unsubscribing")
+		s.close()
+		return false
+	}
+}
+
+// close stops delivery to the subscriber: no further calls will be made once any
+// in-progress call returns. It's safe to call more than once.
+func (s *subscriber) close() {
+	s.closeOnce.Do(func() {
+		close(s.doneCh)
+	})
+}
+
+// run delivers queued changes in order until the subscriber is closed
+func (s *subscriber) run() {
+	for {
+		select {
+		case <-s.doneCh:
+			return
+		case change := <-s.queue:
+			// If we've been closed while waiting, don't make any further calls, even
+			// if changes are still queued
+			select {
+			case <-s.doneCh:
+				return
+			default:
+			}
+			s.call(change)
+		}
+	}
+}
+
+// call invokes the subscriber's function, recovering from (and logging) any panic so
+// that a misbehaving subscriber can't take down the client
+func (s *subscriber) call(change stateChange) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("Broadcast state subscriber panicked", "error", fmt.Sprintf("%v", r))
+		}
+	}()
+	s.f(change.prev, change.next)
+}
@@ -3,9 +3,38 @@ package broadcasts
 import (
 	"time"
 
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/golden-vcr/schemas/core"
 	"github.com/google/uuid"
 )
 
+// State describes current broadcast state, as reported by the broadcasts service: in
+// addition to the core.State fields, it carries the times at which the current
+// broadcast and screening started, along with a version number that increases with
+// every change in state
+type State struct {
+	core.State
+	Version            int64      `json:"version"`
+	BroadcastStartedAt *time.Time `json:"broadcast_started_at"`
+	ScreeningStartedAt *time.Time `json:"screening_started_at"`
+}
+
+// SequencedEvent is a broadcast-events message as produced by the broadcasts service:
+// in addition to the ebroadcast.Event fields, it carries a sequence number that
+// increases by exactly 1 with each event, so that consumers can detect missed,
+// duplicate, or out-of-order events. Seq is the same value that's reported as the
+// Version of the State that results from the event. A Seq of 0 indicates an event
+// that was produced without a sequence number.
+//
+// A broadcast-started event with Resumed set indicates that a broadcast which had ended
+// is live again, rather than that a new broadcast has started: the event carries the
+// original ID and start time of the resumed broadcast.
+type SequencedEvent struct {
+	ebroadcast.Event
+	Seq     int64 `json:"seq"`
+	Resumed bool  `json:"resumed,omitempty"`
+}
+
 type History struct {
 	Broadcasts []Broadcast `json:"broadcasts"`
 }
@@ -15,10 +44,11 @@ type ScreeningHistory struct {
 }
 
 type Broadcast struct {
-	Id         int         `json:"id"`
-	StartedAt  time.Time   `json:"startedAt"`
-	EndedAt    *time.Time  `json:"endedAt"`
-	Screenings []Screening `json:"screenings"`
+	Id            int            `json:"id"`
+	StartedAt     time.Time      `json:"startedAt"`
+	EndedAt       *time.Time     `json:"endedAt"`
+	Screenings    []Screening    `json:"screenings"`
+	Interruptions []Interruption `json:"interruptions"`
 }
 
 type Screening struct {
@@ -27,3 +57,8 @@ type Screening struct {
 	StartedAt time.Time  `json:"startedAt"`
 	EndedAt   *time.Time `json:"endedAt"`
 }
+
+type Interruption struct {
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+}
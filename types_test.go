@@ -0,0 +1,54 @@
+package broadcasts
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SequencedEvent(t *testing.T) {
+	ev := SequencedEvent{
+		Event: ebroadcast.Event{
+			Type: ebroadcast.EventTypeBroadcastStarted,
+			Broadcast: ebroadcast.BroadcastData{
+				Id:        55,
+				StartedAt: time.Date(1997, 9, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		Seq: 42,
+	}
+	jsonEv := `{"type":"broadcast-started","broadcast":{"id":55,"started_at":"1997-09-01T12:00:00Z"},"seq":42}`
+
+	t.Run("sequence number is serialized alongside event fields", func(t *testing.T) {
+		got, err := json.Marshal(ev)
+		assert.NoError(t, err)
+		assert.Equal(t, jsonEv, string(got))
+	})
+	t.Run("sequenced event can be unmarshaled", func(t *testing.T) {
+		var got SequencedEvent
+		err := json.Unmarshal([]byte(jsonEv), &got)
+		assert.NoError(t, err)
+		assert.Equal(t, ev, got)
+	})
+	t.Run("sequenced event remains compatible with ebroadcast.Event", func(t *testing.T) {
+		var got ebroadcast.Event
+		err := json.Unmarshal([]byte(jsonEv), &got)
+		assert.NoError(t, err)
+		assert.Equal(t, ev.Event, got)
+	})
+	t.Run("resumed flag is serialized only for resumed broadcasts", func(t *testing.T) {
+		resumed := ev
+		resumed.Resumed = true
+		got, err := json.Marshal(resumed)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"type":"broadcast-started","broadcast":{"id":55,"started_at":"1997-09-01T12:00:00Z"},"seq":42,"resumed":true}`, string(got))
+
+		var unmarshaled SequencedEvent
+		err = json.Unmarshal(got, &unmarshaled)
+		assert.NoError(t, err)
+		assert.Equal(t, resumed, unmarshaled)
+	})
+}
@@ -0,0 +1,65 @@
+package broadcasts
+
+import (
+	"context"
+
+	"github.com/golden-vcr/schemas/core"
+)
+
+// IsLive is a predicate for use with Client.WaitFor: it's satisfied when a broadcast
+// is in progress
+func IsLive(state core.State) bool {
+	return state.BroadcastId != 0
+}
+
+// IsOffline is a predicate for use with Client.WaitFor: it's satisfied when no
+// broadcast is in progress
+func IsOffline(state core.State) bool {
+	return state.BroadcastId == 0
+}
+
+// IsScreening returns a predicate for use with Client.WaitFor: it's satisfied when the
+// tape with the given ID is being screened
+func IsScreening(tapeId int) func(core.State) bool {
+	return func(state core.State) bool {
+		return state.BroadcastId != 0 && state.TapeId == tapeId
+	}
+}
+
+// BroadcastChanged returns a predicate for use with Client.WaitFor: it's satisfied
+// once the current broadcast is no longer the one with the given ID, i.e. once that
+// broadcast has ended or a different broadcast has started. A fromId of 0 indicates
+// that no broadcast was in progress.
+func BroadcastChanged(fromId int) func(core.State) bool {
+	return func(state core.State) bool {
+		return state.BroadcastId != fromId
+	}
+}
+
+func (c *client) WaitFor(ctx context.Context, predicate func(core.State) bool) (core.State, error) {
+	// Subscribe before checking our current state, so that we can't miss a change that
+	// occurs in between
+	ch := make(chan core.State, 1)
+	unsubscribe := c.Subscribe(func(prev, next core.State) {
+		if predicate(next) {
+			select {
+			case ch <- next:
+			default:
+			}
+		}
+	})
+	defer unsubscribe()
+
+	// If our current state already satisfies the predicate, there's no need to wait
+	if state := c.GetState(); predicate(state) {
+		return state, nil
+	}
+
+	// Otherwise, block until a change in state satisfies the predicate
+	select {
+	case state := <-ch:
+		return state, nil
+	case <-ctx.Done():
+		return c.GetState(), ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package broadcasts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ebroadcast "github.com/golden-vcr/schemas/broadcast-events"
+	"github.com/golden-vcr/schemas/core"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_predicates(t *testing.T) {
+	offline := core.State{}
+	live := core.State{BroadcastId: 55}
+	screening := core.State{BroadcastId: 55, ScreeningId: uuid.MustParse("f29a4ffe-cb9f-43ba-9f91-a3b1fa350472"), TapeId: 109}
+	tests := []struct {
+		name      string
+		predicate func(core.State) bool
+		state     core.State
+		want      bool
+	}{
+		{"IsLive when offline", IsLive, offline, false},
+		{"IsLive when live", IsLive, live, true},
+		{"IsLive when screening", IsLive, screening, true},
+		{"IsOffline when offline", IsOffline, offline, true},
+		{"IsOffline when live", IsOffline, live, false},
+		{"IsScreening when offline", IsScreening(109), offline, false},
+		{"IsScreening when live with no tape", IsScreening(109), live, false},
+		{"IsScreening when screening the desired tape", IsScreening(109), screening, true},
+		{"IsScreening when screening another tape", IsScreening(110), screening, false},
+		{"BroadcastChanged when still in the same broadcast", BroadcastChanged(55), screening, false},
+		{"BroadcastChanged when the broadcast has ended", BroadcastChanged(55), offline, true},
+		{"BroadcastChanged when a new broadcast has started", BroadcastChanged(54), live, true},
+		{"BroadcastChanged when a broadcast has started from offline", BroadcastChanged(0), live, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.predicate(tt.state))
+		})
+	}
+}
+
+func Test_client_WaitFor(t *testing.T) {
+	t.Run("returns immediately if state already satisfies predicate", func(t *testing.T) {
+		c := newTestClient()
+		c.currentState = core.State{BroadcastId: 55}
+
+		state, err := c.WaitFor(context.Background(), IsLive)
+		assert.NoError(t, err)
+		assert.Equal(t, core.State{BroadcastId: 55}, state)
+	})
+
+	t.Run("waits for a change in state that satisfies predicate", func(t *testing.T) {
+		c := newTestClient()
+		screeningId := uuid.MustParse("f29a4ffe-cb9f-43ba-9f91-a3b1fa350472")
+
+		type result struct {
+			state core.State
+			err   error
+		}
+		resultCh := make(chan result, 1)
+		go func() {
+			state, err := c.WaitFor(context.Background(), IsScreening(109))
+			resultCh <- result{state, err}
+		}()
+
+		// Wait until WaitFor has subscribed, then make changes that only satisfy the
+		// predicate at the end
+		assert.Eventually(t, func() bool {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return len(c.subscribers) == 1
+		}, time.Second, time.Millisecond)
+		c.handleEvent(&SequencedEvent{Event: ebroadcast.Event{
+			Type:      ebroadcast.EventTypeBroadcastStarted,
+			Broadcast: ebroadcast.BroadcastData{Id: 55},
+		}})
+		c.handleEvent(&SequencedEvent{Event: ebroadcast.Event{
+			Type:      ebroadcast.EventTypeScreeningStarted,
+			Broadcast: ebroadcast.BroadcastData{Id: 55},
+			Screening: &ebroadcast.ScreeningData{Id: screeningId, TapeId: 109},
+		}})
+
+		select {
+		case r := <-resultCh:
+			assert.NoError(t, r.err)
+			assert.Equal(t, core.State{BroadcastId: 55, ScreeningId: screeningId, TapeId: 109}, r.state)
+		case <-time.After(time.Second):
+			t.Fatal("WaitFor did not return")
+		}
+
+		// WaitFor should have unsubscribed upon returning
+		c.mu.RLock()
+		assert.Len(t, c.subscribers, 0)
+		c.mu.RUnlock()
+	})
+
+	t.Run("returns an error if context is canceled first", func(t *testing.T) {
+		c := newTestClient()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		state, err := c.WaitFor(ctx, IsLive)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, core.State{}, state)
+	})
+}
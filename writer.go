@@ -0,0 +1,19 @@
+package broadcasts
+
+import (
+	"context"
+	"time"
+)
+
+// Writer modifies broadcast state, failing with one of the Err* sentinel errors defined
+// in this package if the requested change isn't valid in the current state. The
+// broadcasts service implements Writer against its database; broadcaststest.Writer is
+// an in-memory implementation for use in tests.
+type Writer interface {
+	StartBroadcast(ctx context.Context) (*Broadcast, error)
+	EndCurrentBroadcast(ctx context.Context) (*Broadcast, error)
+	ResumeBroadcast(ctx context.Context, broadcastId int) (*Broadcast, error)
+	EndStaleBroadcast(ctx context.Context, broadcastId int, endedAt time.Time) error
+	StartScreening(ctx context.Context, tapeId int) (*Screening, error)
+	EndCurrentScreening(ctx context.Context) error
+}